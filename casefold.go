@@ -0,0 +1,33 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"slices"
+	"strings"
+)
+
+// equalName returns true if the names are the same, regardless of case when IgnoreCase is set
+func (f *FileWalker) equalName(a string, b string) bool {
+	if f.cfg.IgnoreCase {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// indexName returns the index of the first of the names which is the same as name, as
+// compared by equalName, or -1 if there is none
+func (f *FileWalker) indexName(names []string, name string) int {
+	return slices.IndexFunc(names, func(n string) bool {
+		return f.equalName(n, name)
+	})
+}
+
+// isSuffixDir returns true if the directory ends with the suffix as isSuffixDir does,
+// regardless of case when IgnoreCase is set
+func (f *FileWalker) isSuffixDir(base string, suffix string) bool {
+	if f.cfg.IgnoreCase {
+		return isSuffixDir(strings.ToLower(base), strings.ToLower(suffix))
+	}
+	return isSuffixDir(base, suffix)
+}
@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"slices"
+	"testing"
+)
+
+var caseFS = mapFS(map[string]string{
+	"Main.GO":             "package main",
+	"app.js":              "app",
+	"README.md":           "readme",
+	"Makefile":            "all:",
+	"Vendor/lib.js":       "lib",
+	"Build/Output/a.js":   "a",
+	"src/Debug.LOG":       "log",
+	"src/.gitignore":      "*.log\n",
+	"src/Components/b.JS": "b",
+})
+
+func TestIgnoreCase(t *testing.T) {
+	cases := []struct {
+		name      string
+		opts      []Option
+		sensitive []string
+		folded    []string
+	}{
+		{
+			"allow list extensions",
+			[]Option{WithAllowListExtensions("JS")},
+			nil,
+			[]string{"Build/Output/a.js", "Vendor/lib.js", "app.js", "src/Components/b.JS"},
+		},
+		{
+			"exclude list extensions",
+			[]Option{WithExcludeListExtensions("JS", "Md", "go", "log"), WithExcludeDirectory("src")},
+			[]string{"Build/Output/a.js", "Makefile", "README.md", "Vendor/lib.js", "app.js"},
+			[]string{"Makefile"},
+		},
+		{
+			"filenames",
+			[]Option{func(f *FileWalker) {
+				f.IncludeFilename = []string{"makefile", "readme.md", "main.go"}
+				f.ExcludeFilename = []string{"MAIN.GO"}
+			}},
+			nil,
+			[]string{"Makefile", "README.md"},
+		},
+		{
+			"exclude directory",
+			[]Option{WithExcludeDirectory("vendor", "build/output", "SRC")},
+			[]string{"Build/Output/a.js", "Main.GO", "Makefile", "README.md", "Vendor/lib.js", "app.js", "src/Components/b.JS", "src/Debug.LOG"},
+			[]string{"Main.GO", "Makefile", "README.md", "app.js"},
+		},
+		{
+			"include directory",
+			[]Option{func(f *FileWalker) { f.IncludeDirectory = []string{"vendor"} }},
+			[]string{"Main.GO", "Makefile", "README.md", "app.js"},
+			[]string{"Main.GO", "Makefile", "README.md", "Vendor/lib.js", "app.js"},
+		},
+		{
+			"globs",
+			[]Option{WithIncludeGlobs("**/*.js"), WithExcludeGlobs("build/**")},
+			[]string{"Build/Output/a.js", "Vendor/lib.js", "app.js"},
+			[]string{"Vendor/lib.js", "app.js", "src/Components/b.JS"},
+		},
+		{
+			"ignore patterns",
+			[]Option{func(f *FileWalker) { f.CustomIgnorePatterns = []string{"makefile", "/VENDOR/"} }},
+			[]string{"Build/Output/a.js", "Main.GO", "Makefile", "README.md", "Vendor/lib.js", "app.js", "src/Components/b.JS", "src/Debug.LOG"},
+			[]string{"Build/Output/a.js", "Main.GO", "README.md", "app.js", "src/Components/b.JS"},
+		},
+	}
+
+	for _, tc := range cases {
+		for _, ignoreCase := range []bool{false, true} {
+			expected := tc.sensitive
+			if ignoreCase {
+				expected = tc.folded
+			}
+
+			files, _ := walkFS(t, caseFS, append([]Option{func(f *FileWalker) { f.IgnoreCase = ignoreCase }}, tc.opts...)...)
+			if !slices.Equal(locations(files), expected) {
+				t.Errorf("%s ignoring case %v: expected %v got %v", tc.name, ignoreCase, expected, locations(files))
+			}
+		}
+	}
+}
+
+func TestIgnoreCaseGitignore(t *testing.T) {
+	for _, ignoreCase := range []bool{false, true} {
+		sink := &SliceSink{}
+		walker := NewFileWalkerFS(caseFS, "src", nil, WithSink(sink), func(f *FileWalker) { f.IgnoreCase = ignoreCase })
+		if err := walker.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		found := slices.ContainsFunc(sink.Files, func(f *File) bool { return f.Filename == "Debug.LOG" })
+		if found == ignoreCase {
+			t.Errorf("expected Debug.LOG returned %v when ignoring case is %v", !ignoreCase, ignoreCase)
+		}
+	}
+}
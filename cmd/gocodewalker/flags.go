@@ -0,0 +1,318 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/user"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/boyter/gocodewalker"
+)
+
+// options are the settings which control the output rather than what is walked
+type options struct {
+	print0  bool
+	count   bool
+	explain bool
+	quiet   bool
+	stats   bool
+	head    int
+	config  string
+	format  string
+}
+
+// stringList is a flag which can be repeated or given a comma separated list, appending to the list each time
+type stringList struct {
+	values *[]string
+}
+
+func (s stringList) String() string {
+	if s.values == nil {
+		return ""
+	}
+	return strings.Join(*s.values, ",")
+}
+
+func (s stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*s.values = append(*s.values, v)
+		}
+	}
+	return nil
+}
+
+// globList is a flag which can be repeated, appending the glob each time. Unlike stringList it is
+// not split on commas as they separate the alternatives within braces
+type globList struct {
+	values *[]string
+}
+
+func (g globList) String() string {
+	if g.values == nil {
+		return ""
+	}
+	return strings.Join(*g.values, " ")
+}
+
+func (g globList) Set(value string) error {
+	*g.values = append(*g.values, value)
+	return nil
+}
+
+// regexList is a flag which can be repeated, appending the compiled regular expression each time
+type regexList struct {
+	values *[]*regexp.Regexp
+}
+
+func (r regexList) String() string {
+	if r.values == nil {
+		return ""
+	}
+	s := make([]string, 0, len(*r.values))
+	for _, v := range *r.values {
+		s = append(s, v.String())
+	}
+	return strings.Join(s, " ")
+}
+
+func (r regexList) Set(value string) error {
+	re, err := regexp.Compile(value)
+	if err != nil {
+		return err
+	}
+	*r.values = append(*r.values, re)
+	return nil
+}
+
+// fileTypesFlag is a flag which replaces the types of file returned with the comma separated list
+type fileTypesFlag struct {
+	policy *gocodewalker.FileTypePolicy
+}
+
+func (f fileTypesFlag) String() string {
+	if f.policy == nil {
+		return ""
+	}
+	return f.policy.String()
+}
+
+func (f fileTypesFlag) Set(value string) error {
+	policy, ok := gocodewalker.ParseFileTypePolicy(value)
+	if !ok {
+		return fmt.Errorf("unknown file type in %q, expected regular, symlink, fifo, socket, device or irregular", value)
+	}
+	*f.policy = policy
+	return nil
+}
+
+// sizeFlag is a flag for a number of bytes with an optional k, m or g suffix for kibibytes, mebibytes or gibibytes
+type sizeFlag struct {
+	size *int64
+}
+
+func (s sizeFlag) String() string {
+	if s.size == nil {
+		return ""
+	}
+	return strconv.FormatInt(*s.size, 10)
+}
+
+func (s sizeFlag) Set(value string) error {
+	multiplier := int64(1)
+	for suffix, m := range map[string]int64{"k": 1 << 10, "m": 1 << 20, "g": 1 << 30} {
+		if v, ok := strings.CutSuffix(strings.ToLower(value), suffix); ok {
+			value, multiplier = v, m
+		}
+	}
+
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return err
+	}
+	*s.size = size * multiplier
+	return nil
+}
+
+// timeFlag is a flag for a time either as RFC 3339, a date, or a duration before now such as 24h
+type timeFlag struct {
+	time *time.Time
+}
+
+func (t timeFlag) String() string {
+	if t.time == nil || t.time.IsZero() {
+		return ""
+	}
+	return t.time.Format(time.RFC3339)
+}
+
+func (t timeFlag) Set(value string) error {
+	if d, err := time.ParseDuration(value); err == nil {
+		*t.time = time.Now().Add(-d)
+		return nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateTime, time.DateOnly} {
+		if parsed, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			*t.time = parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("expected a duration, date or RFC 3339 time got %q", value)
+}
+
+// permFlag is a flag for permission bits in octal such as 755
+type permFlag struct {
+	perm *fs.FileMode
+}
+
+func (p permFlag) String() string {
+	if p.perm == nil || *p.perm == 0 {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*p.perm), 8)
+}
+
+func (p permFlag) Set(value string) error {
+	perm, err := strconv.ParseUint(value, 8, 32)
+	if err != nil {
+		return err
+	}
+	*p.perm = fs.FileMode(perm)
+	return nil
+}
+
+// ownerList is a flag which can be repeated or given a comma separated list of user names or uids
+type ownerList struct {
+	uids *[]int
+}
+
+func (o ownerList) String() string {
+	if o.uids == nil {
+		return ""
+	}
+	s := make([]string, 0, len(*o.uids))
+	for _, uid := range *o.uids {
+		s = append(s, strconv.Itoa(uid))
+	}
+	return strings.Join(s, ",")
+}
+
+func (o ownerList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		uid, err := strconv.Atoi(v)
+		if err != nil {
+			u, lerr := user.Lookup(v)
+			if lerr != nil {
+				return lerr
+			}
+			if uid, err = strconv.Atoi(u.Uid); err != nil {
+				return fmt.Errorf("user %s has no numeric uid", v)
+			}
+		}
+		*o.uids = append(*o.uids, uid)
+	}
+	return nil
+}
+
+// newFlagSet returns the flags for every setting, which are set directly on the supplied config
+func newFlagSet(c *gocodewalker.WalkerConfig, output io.Writer) (*flag.FlagSet, *options) {
+	opts := &options{}
+	flags := flag.NewFlagSet("gocodewalker", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.Usage = func() {
+		fmt.Fprintln(output, "Usage: gocodewalker [flags] [directory ...]")
+		fmt.Fprintln(output)
+		fmt.Fprintln(output, "Prints every file in the directories, or . if none, which is not ignored by .gitignore, .ignore")
+		fmt.Fprintln(output, "or the flags. Exits 0 if any files were found, 1 if none were and 2 on any error.")
+		fmt.Fprintln(output)
+		flags.PrintDefaults()
+	}
+
+	aliased := func(value flag.Value, usage string, names ...string) {
+		for _, name := range names {
+			flags.Var(value, name, usage)
+		}
+	}
+	aliasedBool := func(p *bool, usage string, names ...string) {
+		for _, name := range names {
+			flags.BoolVar(p, name, *p, usage)
+		}
+	}
+
+	// filters
+	aliased(stringList{&c.AllowListExtensions}, "only return files with these extensions, comma separated or repeated", "e", "extension")
+	aliased(stringList{&c.ExcludeListExtensions}, "skip files with these extensions", "E", "exclude-extension")
+	aliased(stringList{&c.IncludeDirectory}, "only walk directories with these names", "include-dir")
+	aliased(stringList{&c.ExcludeDirectory}, "skip directories with these names or path suffixes", "x", "exclude-dir")
+	aliased(stringList{&c.IncludeFilename}, "only return files with these names", "include-file")
+	aliased(stringList{&c.ExcludeFilename}, "skip files with these names", "exclude-file")
+	aliased(regexList{&c.IncludeDirectoryRegex}, "only walk directories whose name matches the regex, may be repeated", "include-dir-regex")
+	aliased(regexList{&c.ExcludeDirectoryRegex}, "skip directories whose name matches the regex", "exclude-dir-regex")
+	aliased(regexList{&c.IncludeFilenameRegex}, "only return files whose name matches the regex", "include-file-regex")
+	aliased(regexList{&c.ExcludeFilenameRegex}, "skip files whose name matches the regex", "exclude-file-regex")
+	aliased(stringList{&c.LocationExcludePattern}, "skip any path containing these strings", "exclude-pattern")
+	aliased(stringList{&c.IncludeLanguages}, "only return files detected as these languages, such as Go or Rust, comma separated or repeated", "l", "language")
+	aliasedBool(&c.DetectLanguages, "detect the language of every file, written with a json format", "detect-languages")
+	aliased(globList{&c.IncludeGlobs}, "only return files whose path relative to the directory walked matches the glob, such as src/**/*.{ts,tsx}, may be repeated", "g", "glob")
+	aliased(globList{&c.ExcludeGlobs}, "skip files and directories whose path relative to the directory walked matches the glob", "exclude-glob")
+	aliasedBool(&c.IgnoreCase, "match extensions, names, globs and ignore files regardless of case", "i", "ignore-case")
+	aliasedBool(&c.IncludeHidden, "include hidden files and directories", "H", "hidden")
+	flags.IntVar(&c.MaxDepth, "max-depth", c.MaxDepth, "how many directories deep to walk, -1 for no limit")
+	flags.IntVar(&c.MaxDepth, "d", c.MaxDepth, "how many directories deep to walk, -1 for no limit")
+	aliasedBool(&c.IgnoreBinaryFiles, "skip binary files", "skip-binary")
+	flags.IntVar(&c.IgnoreBinaryFileBytes, "binary-bytes", c.IgnoreBinaryFileBytes, "how many bytes to check when skipping binary files")
+	aliased(fileTypesFlag{&c.FileTypes}, "only return these types of file, any of regular, symlink, fifo, socket, device and irregular", "t", "file-types")
+	aliased(sizeFlag{&c.MinSize}, "skip files smaller than this many bytes, with an optional k, m or g suffix", "min-size")
+	aliased(sizeFlag{&c.MaxSize}, "skip files larger than this many bytes, with an optional k, m or g suffix", "max-size")
+	aliased(timeFlag{&c.ModifiedAfter}, "skip files not modified after this date, time or duration ago such as 24h", "newer")
+	aliased(timeFlag{&c.ModifiedBefore}, "skip files not modified before this date, time or duration ago", "older")
+	aliased(permFlag{&c.IncludePermissions}, "skip files without all of these octal permission bits such as 100", "perm")
+	aliased(permFlag{&c.ExcludePermissions}, "skip files with any of these octal permission bits such as 002", "exclude-perm")
+	aliased(ownerList{&c.OwnerUIDs}, "skip files not owned by these users, as names or uids", "owner")
+
+	// ignore files
+	aliasedBool(&c.IgnoreGitIgnore, "do not respect .gitignore files", "no-gitignore")
+	aliasedBool(&c.IgnoreIgnoreFile, "do not respect .ignore files", "no-ignore-file")
+	aliasedBool(&c.IgnoreGitModules, "do not skip git submodules", "no-gitmodules")
+	flags.BoolFunc("no-ignore", "do not respect .gitignore, .ignore or .gitmodules files", func(string) error {
+		c.IgnoreGitIgnore, c.IgnoreIgnoreFile, c.IgnoreGitModules = true, true, true
+		return nil
+	})
+	aliasedBool(&c.RespectGlobalGitIgnore, "respect the global git excludes file", "global-gitignore")
+	aliasedBool(&c.RespectProjectConfig, "respect .gocodewalker.json files", "project-config")
+	aliased(stringList{&c.CustomIgnore}, "also respect ignore files with these names", "custom-ignore")
+	aliased(stringList{&c.CustomIgnoreFiles}, "ignore files to apply from the root of each directory walked", "ignore-file")
+	aliased(stringList{&c.CustomIgnorePatterns}, "gitignore patterns to apply in every directory", "ignore-pattern")
+
+	// walking
+	aliasedBool(&c.FollowSymlinks, "walk symlinks to directories", "L", "follow")
+	aliasedBool(&c.ConfineSymlinks, "skip symlinks which resolve outside the directory walked", "confine-symlinks")
+	aliasedBool(&c.Sorted, "return files in the same depth-first order every time", "s", "sorted")
+	flags.IntVar(&c.Concurrency, "concurrency", c.Concurrency, "how many directories to walk concurrently")
+	aliasedBool(&c.EmitDirectories, "also print directories which are not ignored", "directories")
+	aliasedBool(&c.EmitLeaveDirectories, "with --directories and a json format also write a leave_directory object with the file count once everything below a directory has been written", "leave-directories")
+	flags.StringVar(&opts.config, "config", "", "JSON file of settings, which flags are applied on top of")
+
+	// output
+	aliasedBool(&opts.print0, "separate files with a null byte rather than a newline", "0", "print0")
+	aliasedBool(&opts.count, "print the number of files rather than the files", "c", "count")
+	aliasedBool(&opts.explain, "print why each skipped file or directory was skipped to stderr", "explain")
+	aliasedBool(&opts.quiet, "do not print errors encountered while walking, the exit code is still 2", "q", "quiet")
+	flags.IntVar(&opts.head, "head", 0, "print this many bytes of each file after its path")
+	flags.StringVar(&opts.format, "format", formatText, "text, or ndjson for a JSON object per file, skip and error followed by a summary, or json for the same as an array")
+	flags.BoolFunc("json", "the same as --format ndjson", func(string) error {
+		opts.format = formatNDJSON
+		return nil
+	})
+	aliasedBool(&opts.stats, "print a summary of the files, skips and errors to stderr", "stats")
+
+	return flags, opts
+}
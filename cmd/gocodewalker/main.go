@@ -1,50 +1,130 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
-	"strings"
+	"path/filepath"
+	"slices"
 
 	"github.com/boyter/gocodewalker"
 )
 
-// Proper test designed to confirm that .gitignores work as expected with globs
-// Designed to work against https://github.com/svent/gitignore-test
-// If you compile and run this it should produce the same output as the following tools
+// Exit codes follow grep and rg so scripts can tell no files apart from failure
+const (
+	exitFound   = 0 // at least one file was found and there were no errors
+	exitNone    = 1 // no files were found and there were no errors
+	exitFailure = 2 // the arguments were invalid or there was an error while walking
+)
+
+// Walks the supplied directories, or "." if none, printing every file which is not ignored
+// similar to fd or rg --files. Run with -h for the flags.
+//
+// It can also be used to confirm that .gitignores work as expected with globs
+// against https://github.com/svent/gitignore-test
+// If you compile and run it with --head 10 it should produce the same output as the following tools
 // when run from the directory you check it out into
 //
 // rg ^foo: | sort
 // git grep ^foo: | sort
-// gocodewalker | sort
+// gocodewalker --head 10 | sort
 func main() {
-	fileListQueue := make(chan *gocodewalker.File, 10_000)
-	fileWalker := gocodewalker.NewParallelFileWalker([]string{"."}, fileListQueue)
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
 
-	// handle the errors by printing them out and then ignore
-	errorHandler := func(e error) bool {
-		fmt.Println("ERR", e.Error())
-		return true
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	config := gocodewalker.DefaultConfig()
+	flags, opts := newFlagSet(&config, stderr)
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitFound
+		}
+		return exitFailure
 	}
-	fileWalker.SetErrorHandler(errorHandler)
 
-	// skipHandler
-	skipHandler := func(path string, name string, isDir bool, reason gocodewalker.SkipReason) {
-		fmt.Println("SKIPPED", path, name, isDir, reason)
+	if opts.config != "" {
+		var err error
+		config, err = loadConfig(opts.config)
+		if err != nil {
+			fmt.Fprintln(stderr, "gocodewalker:", err)
+			return exitFailure
+		}
+
+		// parsed again on top of the config file so that the command line takes precedence
+		flags, opts = newFlagSet(&config, stderr)
+		if err := flags.Parse(args); err != nil {
+			return exitFailure
+		}
+	}
+
+	roots := flags.Args()
+	if len(roots) == 0 {
+		roots = []string{"."}
 	}
-	fileWalker.SetSkipHandler(skipHandler)
 
+	if !slices.Contains([]string{formatText, formatNDJSON, formatJSON}, opts.format) {
+		fmt.Fprintf(stderr, "gocodewalker: unknown format %q, expected text, ndjson or json\n", opts.format)
+		return exitFailure
+	}
+
+	fileListQueue := make(chan *gocodewalker.File, 10_000)
+	fileWalker := gocodewalker.NewParallelFileWalker(roots, fileListQueue, gocodewalker.WithConfig(config))
+
+	r := newReporter(stdout, stderr, opts)
+	failed := false
+	fileWalker.SetErrorHandler(func(e error) bool {
+		r.error(e)
+		return true
+	})
+	if opts.explain {
+		fileWalker.SetSkipDetailHandler(func(explanation *gocodewalker.Explanation) {
+			r.skip(explanation.Path, filepath.Base(explanation.Path), explanation.IsDir, explanation.Reason, explanation)
+		})
+	} else {
+		fileWalker.SetSkipHandler(func(path string, name string, isDir bool, reason gocodewalker.SkipReason) {
+			r.skip(path, name, isDir, reason, nil)
+		})
+	}
+
+	errs := make(chan error, 1)
 	go func() {
-		err := fileWalker.Start()
-		if err != nil {
-			fmt.Println("ERR", err.Error())
-		}
+		errs <- fileWalker.Start()
 	}()
 
 	for f := range fileListQueue {
-		contents, _ := os.ReadFile(f.Location)
-		if len(contents) > 10 {
-			contents = contents[:10]
-		}
-		fmt.Printf("%v:%v\n", f.Location, strings.TrimSpace(string(contents)))
+		r.file(f)
+	}
+
+	if err := <-errs; err != nil {
+		r.error(err)
+	}
+	if err := r.finish(); err != nil {
+		fmt.Fprintln(stderr, "gocodewalker:", err)
+		failed = true
+	}
+
+	switch {
+	case failed || r.summary.Errors != 0:
+		return exitFailure
+	case r.summary.Files == 0:
+		return exitNone
+	}
+	return exitFound
+}
+
+// loadConfig reads a WalkerConfig from the JSON file, with anything not in it left as the default
+func loadConfig(location string) (gocodewalker.WalkerConfig, error) {
+	config := gocodewalker.DefaultConfig()
+
+	c, err := os.ReadFile(location)
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(c, &config); err != nil {
+		return config, fmt.Errorf("invalid config %s: %w", location, err)
 	}
+	return config, nil
 }
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/boyter/gocodewalker"
+)
+
+// makeTree creates the files, given as slash separated paths, under a new temporary directory
+func makeTree(t *testing.T, files ...string) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, file := range files {
+		location := filepath.Join(root, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(location), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(location, []byte(file), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// runCLI runs with the arguments returning the exit code, the sorted files printed relative to root and stderr
+func runCLI(t *testing.T, root string, args ...string) (int, []string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(append(args, root), &stdout, &stderr)
+
+	files := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		if line != "" {
+			rel, _ := filepath.Rel(root, line)
+			files = append(files, filepath.ToSlash(rel))
+		}
+	}
+	sort.Strings(files)
+	return code, files, stderr.String()
+}
+
+func TestRunFilters(t *testing.T) {
+	root := makeTree(t, "main.go", "README.md", "vendor/lib.go", ".hidden/secret.go", "pkg/pkg.go", "pkg/pkg_test.go")
+
+	cases := []struct {
+		args     []string
+		expected []string
+	}{
+		{nil, []string{"README.md", "main.go", "pkg/pkg.go", "pkg/pkg_test.go", "vendor/lib.go"}},
+		{[]string{"-e", "go", "-x", "vendor"}, []string{"main.go", "pkg/pkg.go", "pkg/pkg_test.go"}},
+		{[]string{"--extension=go,md", "--max-depth", "1"}, []string{"README.md", "main.go"}},
+		{[]string{"-H", "-e", "go", "--exclude-file-regex", "_test"}, []string{".hidden/secret.go", "main.go", "pkg/pkg.go", "vendor/lib.go"}},
+		{[]string{"--ignore-pattern", "pkg/", "--exclude-extension", "md"}, []string{"main.go", "vendor/lib.go"}},
+		{[]string{"-l", "go", "--language", "Markdown", "-x", "vendor,pkg"}, []string{"README.md", "main.go"}},
+		{[]string{"-i", "-e", "GO", "-x", "VENDOR"}, []string{"main.go", "pkg/pkg.go", "pkg/pkg_test.go"}},
+		{[]string{"-g", "**/*.{go,md}", "--exclude-glob", "vendor", "--exclude-glob", "**/*_test.go"}, []string{"README.md", "main.go", "pkg/pkg.go"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			code, files, stderr := runCLI(t, root, tc.args...)
+			if code != exitFound {
+				t.Errorf("expected exit %d got %d %s", exitFound, code, stderr)
+			}
+			if !slices.Equal(files, tc.expected) {
+				t.Errorf("expected %v got %v", tc.expected, files)
+			}
+		})
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	root := makeTree(t, "main.go")
+
+	if code, _, _ := runCLI(t, root, "-e", "rs"); code != exitNone {
+		t.Errorf("expected exit %d for no files got %d", exitNone, code)
+	}
+	if code, _, stderr := runCLI(t, root, "--include-dir-regex", "("); code != exitFailure || stderr == "" {
+		t.Errorf("expected exit %d with an error for an invalid regex got %d", exitFailure, code)
+	}
+	if code, _, _ := runCLI(t, root, "--file-types", "symlink"); code != exitNone {
+		t.Errorf("expected exit %d for no symlinks got %d", exitNone, code)
+	}
+	if code, _, stderr := runCLI(t, root, "--file-types", "door"); code != exitFailure || stderr == "" {
+		t.Errorf("expected exit %d with an error for an unknown file type got %d", exitFailure, code)
+	}
+	if code, _, _ := runCLI(t, root, "--min-size", "1k"); code != exitNone {
+		t.Errorf("expected exit %d for no files of at least 1k got %d", exitNone, code)
+	}
+	if code, _, _ := runCLI(t, root, "--newer", "2000-01-01", "--older", "2999-01-01"); code != exitFound {
+		t.Errorf("expected exit %d for a file modified since 2000 got %d", exitFound, code)
+	}
+	if code, _, stderr := runCLI(t, root, "--max-size", "big"); code != exitFailure || stderr == "" {
+		t.Errorf("expected exit %d with an error for an invalid size got %d", exitFailure, code)
+	}
+	if code, _, _ := runCLI(t, root, "--max-depth", "-3"); code != exitFailure {
+		t.Errorf("expected exit %d for an invalid config got %d", exitFailure, code)
+	}
+	if code, _, stderr := runCLI(t, filepath.Join(root, "missing")); code != exitFailure || !strings.Contains(stderr, "missing") {
+		t.Errorf("expected exit %d with an error for a missing directory got %d %q", exitFailure, code, stderr)
+	}
+	if code, _, stderr := runCLI(t, filepath.Join(root, "missing"), "-q"); code != exitFailure || strings.Contains(stderr, "missing") {
+		t.Errorf("expected exit %d without the error printed when quiet got %d %q", exitFailure, code, stderr)
+	}
+}
+
+func TestRunOutput(t *testing.T) {
+	root := makeTree(t, "a.go", "b/c.go")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-0", "--sorted", root}, &stdout, &stderr); code != exitFound {
+		t.Fatalf("expected exit %d got %d %s", exitFound, code, stderr.String())
+	}
+	expected := filepath.Join(root, "a.go") + "\x00" + filepath.Join(root, "b", "c.go") + "\x00"
+	if got := filepath.FromSlash(stdout.String()); got != expected {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"--count", root, root}, &stdout, &stderr); code != exitFound {
+		t.Fatalf("expected exit %d got %d", exitFound, code)
+	}
+	if got := stdout.String(); got != "4\n" {
+		t.Errorf("expected a count of 4 over both roots got %q", got)
+	}
+}
+
+func TestRunExplain(t *testing.T) {
+	root := makeTree(t, "main.go", "notes.md")
+
+	code, files, stderr := runCLI(t, root, "--explain", "-e", "go")
+	if code != exitFound || !slices.Equal(files, []string{"main.go"}) {
+		t.Errorf("expected only main.go got %d %v", code, files)
+	}
+	if !strings.Contains(stderr, "notes.md ignored (allow_list_extension)") {
+		t.Errorf("expected explanation for notes.md got %q", stderr)
+	}
+}
+
+func TestRunConfigFile(t *testing.T) {
+	root := makeTree(t, "main.go", "README.md", "script.sh")
+	config := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(config, []byte(`{"allow_list_extensions": ["go"]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, files, _ := runCLI(t, root, "--config", config, "-e", "md")
+	if code != exitFound || !slices.Equal(files, []string{"README.md", "main.go"}) {
+		t.Errorf("expected flags to add to the config file got %d %v", code, files)
+	}
+
+	if err := os.WriteFile(config, []byte(`{"include_filename_regex": ["("]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code, _, stderr := runCLI(t, root, "--config", config); code != exitFailure || !strings.Contains(stderr, "invalid config") {
+		t.Errorf("expected exit %d for an invalid config file got %d %q", exitFailure, code, stderr)
+	}
+}
+
+func TestRunNDJSON(t *testing.T) {
+	root := makeTree(t, "main.go", "notes.md", "Makefile")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--json", "-e", "go,makefile", root}, &stdout, &stderr); code != exitFound {
+		t.Fatalf("expected exit %d got %d %s", exitFound, code, stderr.String())
+	}
+
+	var files []fileRecord
+	var skips []skipRecord
+	var summary summaryRecord
+	for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		var record struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid json %q %v", line, err)
+		}
+
+		switch record.Type {
+		case "file":
+			var f fileRecord
+			_ = json.Unmarshal([]byte(line), &f)
+			files = append(files, f)
+		case "skip":
+			var s skipRecord
+			_ = json.Unmarshal([]byte(line), &s)
+			skips = append(skips, s)
+		case "summary":
+			_ = json.Unmarshal([]byte(line), &summary)
+		default:
+			t.Errorf("unexpected record %q", line)
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Filename < files[j].Filename })
+	if len(files) != 2 || files[0].Extension != "makefile" || files[1].Extension != "go" || files[1].Size != int64(len("main.go")) {
+		t.Errorf("expected Makefile and main.go with extension and size got %+v", files)
+	}
+	if len(skips) != 1 || skips[0].Name != "notes.md" || skips[0].Reason != gocodewalker.SkipReasonAllowListExtension {
+		t.Errorf("expected notes.md skipped got %+v", skips)
+	}
+	if summary.Files != 2 || summary.Skipped != 1 || summary.SkippedReasons[gocodewalker.SkipReasonAllowListExtension] != 1 || summary.Errors != 0 {
+		t.Errorf("expected summary of 2 files and 1 skip got %+v", summary)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(stdout.String()), "}") || !strings.Contains(stdout.String(), `"type":"summary"`) {
+		t.Errorf("expected summary to be the last record got %q", stdout.String())
+	}
+}
+
+func TestRunJSONArray(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	root := makeTree(t, "main.go")
+	code := run([]string{"--format", "json", "--explain", root, filepath.Join(root, "missing")}, &stdout, &stderr)
+	if code != exitFailure {
+		t.Errorf("expected exit %d for the missing directory got %d", exitFailure, code)
+	}
+
+	var records []map[string]any
+	if err := json.Unmarshal(stdout.Bytes(), &records); err != nil {
+		t.Fatalf("expected a json array got %q %v", stdout.String(), err)
+	}
+
+	types := []string{}
+	for _, record := range records {
+		types = append(types, record["type"].(string))
+	}
+	sort.Strings(types)
+	if !slices.Equal(types, []string{"error", "file", "summary"}) {
+		t.Errorf("expected an error, file and summary got %v", types)
+	}
+	if summary := records[len(records)-1]; summary["type"] != "summary" || summary["errors"] != float64(1) {
+		t.Errorf("expected the summary last with 1 error got %v", summary)
+	}
+}
+
+func TestRunDirectories(t *testing.T) {
+	root := makeTree(t, "main.go", "pkg/pkg.go", "pkg/sub/sub.go", "vendor/lib.go")
+
+	code, files, stderr := runCLI(t, root, "--directories", "-x", "vendor")
+	if code != exitFound {
+		t.Fatalf("expected exit %d got %d %s", exitFound, code, stderr)
+	}
+	if !slices.Equal(files, []string{"main.go", "pkg", "pkg/pkg.go", "pkg/sub", "pkg/sub/sub.go"}) {
+		t.Errorf("expected the files and directories other than vendor got %v", files)
+	}
+
+	var stdout, stderrBuffer bytes.Buffer
+	if code := run([]string{"--json", "--directories", "--leave-directories", "-x", "vendor", root}, &stdout, &stderrBuffer); code != exitFound {
+		t.Fatalf("expected exit %d got %d %s", exitFound, code, stderrBuffer.String())
+	}
+
+	counts := map[string]int64{}
+	for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		var f fileRecord
+		if err := json.Unmarshal([]byte(line), &f); err != nil {
+			t.Fatalf("invalid json %q %v", line, err)
+		}
+		if f.Type == string(gocodewalker.FileTypeLeaveDirectory) {
+			counts[f.Filename] = f.FileCount
+		}
+	}
+	if counts["pkg"] != 2 || counts["sub"] != 1 || len(counts) != 2 {
+		t.Errorf("expected pkg to contain 2 files and sub 1 got %v", counts)
+	}
+}
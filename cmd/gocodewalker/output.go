@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boyter/gocodewalker"
+)
+
+const (
+	formatText   = "text"
+	formatNDJSON = "ndjson"
+	formatJSON   = "json"
+)
+
+// fileRecord is written for every file, and directory with --directories, found when the format is ndjson or json
+type fileRecord struct {
+	Type      string                `json:"type"`
+	Location  string                `json:"location"`
+	Filename  string                `json:"filename"`
+	Root      string                `json:"root"`
+	Extension string                `json:"extension"`
+	Size      int64                 `json:"size"`
+	Depth     int                   `json:"depth"`
+	Content   *gocodewalker.Content `json:"content,omitempty"`
+	Language  string                `json:"language,omitempty"`   // Only set with --language or --detect-languages
+	FileCount int64                 `json:"file_count,omitempty"` // Only set for leave_directory
+}
+
+// skipRecord is written for every file or directory skipped when the format is ndjson or json
+type skipRecord struct {
+	Type        string                    `json:"type"`
+	Path        string                    `json:"path"`
+	Name        string                    `json:"name"`
+	IsDir       bool                      `json:"is_dir"`
+	Reason      gocodewalker.SkipReason   `json:"reason"`
+	Explanation *gocodewalker.Explanation `json:"explanation,omitempty"`
+}
+
+// errorRecord is written for every error encountered when the format is ndjson or json
+type errorRecord struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// summaryRecord is written last when the format is ndjson or json, and to stderr with --stats
+type summaryRecord struct {
+	Type           string                          `json:"type"`
+	Files          int                             `json:"files"`
+	Directories    int                             `json:"directories,omitempty"`
+	Bytes          int64                           `json:"bytes"`
+	Skipped        int                             `json:"skipped"`
+	SkippedReasons map[gocodewalker.SkipReason]int `json:"skipped_reasons"`
+	Errors         int                             `json:"errors"`
+	ElapsedSeconds float64                         `json:"elapsed_seconds"`
+}
+
+// reporter writes everything found while walking in the chosen format. Skips and errors are
+// reported from the goroutines walking so every method is safe to call concurrently.
+type reporter struct {
+	mutex   sync.Mutex
+	out     *bufio.Writer
+	stderr  io.Writer
+	opts    *options
+	started time.Time
+	written int
+	summary summaryRecord
+	err     error
+}
+
+func newReporter(stdout io.Writer, stderr io.Writer, opts *options) *reporter {
+	return &reporter{
+		out:     bufio.NewWriter(stdout),
+		stderr:  stderr,
+		opts:    opts,
+		started: time.Now(),
+		summary: summaryRecord{
+			Type:           "summary",
+			SkippedReasons: map[gocodewalker.SkipReason]int{},
+		},
+	}
+}
+
+func (r *reporter) structured() bool {
+	return r.opts.format == formatNDJSON || r.opts.format == formatJSON
+}
+
+// record writes a single record, keeping the first error so it can be returned by finish
+func (r *reporter) record(v any) {
+	if r.opts.format == formatJSON {
+		separator := ",\n"
+		if r.written == 0 {
+			separator = "[\n"
+		}
+		if _, err := r.out.WriteString(separator); err != nil && r.err == nil {
+			r.err = err
+		}
+	}
+	r.written++
+
+	b, err := json.Marshal(v)
+	if err == nil {
+		_, err = r.out.Write(b)
+	}
+	if r.opts.format == formatNDJSON && err == nil {
+		err = r.out.WriteByte('\n')
+	}
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *reporter) file(f *gocodewalker.File) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	size := int64(0)
+	switch f.Type {
+	case gocodewalker.FileTypeDirectory:
+		r.summary.Directories++
+	case gocodewalker.FileTypeLeaveDirectory:
+	default:
+		r.summary.Files++
+		if r.structured() || r.opts.stats {
+			size = f.Size()
+			r.summary.Bytes += size
+		}
+	}
+
+	switch {
+	case r.opts.count:
+	case r.structured():
+		record := fileRecord{
+			Type:      string(f.Type),
+			Location:  f.Location,
+			Filename:  f.Filename,
+			Root:      f.Root,
+			Size:      size,
+			Depth:     f.Depth,
+			Content:   f.Content,
+			Language:  f.Language,
+			FileCount: f.FileCount,
+		}
+		if f.Type == gocodewalker.FileTypeFile {
+			record.Extension = gocodewalker.GetExtension(f.Filename)
+		}
+		r.record(record)
+	case f.Type == gocodewalker.FileTypeLeaveDirectory:
+	default:
+		_, _ = r.out.WriteString(f.Location)
+		if r.opts.head > 0 {
+			_, _ = r.out.WriteString(":" + head(f.Location, r.opts.head))
+		}
+		if r.opts.print0 {
+			_ = r.out.WriteByte(0)
+		} else {
+			_ = r.out.WriteByte('\n')
+		}
+	}
+}
+
+func (r *reporter) skip(path string, name string, isDir bool, reason gocodewalker.SkipReason, explanation *gocodewalker.Explanation) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.summary.Skipped++
+	r.summary.SkippedReasons[reason]++
+
+	switch {
+	case r.structured():
+		r.record(skipRecord{Type: "skip", Path: path, Name: name, IsDir: isDir, Reason: reason, Explanation: explanation})
+	case explanation != nil:
+		fmt.Fprint(r.stderr, explanation)
+	}
+}
+
+func (r *reporter) error(e error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.summary.Errors++
+
+	switch {
+	case r.structured():
+		r.record(errorRecord{Type: "error", Message: e.Error()})
+	case !r.opts.quiet:
+		fmt.Fprintln(r.stderr, "gocodewalker:", e)
+	}
+}
+
+// finish writes the count or summary and flushes the output,
+// returning the first error encountered writing anything
+func (r *reporter) finish() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.summary.ElapsedSeconds = time.Since(r.started).Seconds()
+
+	switch {
+	case r.structured():
+		r.record(r.summary)
+		if r.opts.format == formatJSON {
+			_, _ = r.out.WriteString("\n]\n")
+		}
+	case r.opts.count:
+		_, _ = fmt.Fprintln(r.out, r.summary.Files)
+	}
+
+	if r.opts.stats && !r.structured() {
+		fmt.Fprintf(r.stderr, "files %d, bytes %d, skipped %d, errors %d, elapsed %s\n",
+			r.summary.Files, r.summary.Bytes, r.summary.Skipped, r.summary.Errors,
+			time.Duration(r.summary.ElapsedSeconds*float64(time.Second)).Round(time.Millisecond))
+		for _, reason := range slices.Sorted(maps.Keys(r.summary.SkippedReasons)) {
+			fmt.Fprintf(r.stderr, "  %s %d\n", reason, r.summary.SkippedReasons[reason])
+		}
+	}
+
+	if err := r.out.Flush(); r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// head returns the first n bytes of the file with surrounding whitespace removed
+func head(location string, n int) string {
+	file, err := os.Open(location)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	buffer := make([]byte, n)
+	read, _ := io.ReadFull(file, buffer)
+	return strings.TrimSpace(string(buffer[:read]))
+}
@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: MIT
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/boyter/gocodewalker"
+)
+
+// makeTree creates width directories below each directory depth times, each with files files in it,
+// with everything inside top directories at the root
+func makeTree(b *testing.B, top int, width int, depth int, files int) string {
+	b.Helper()
+	root := b.TempDir()
+
+	var create func(directory string, level int)
+	create = func(directory string, level int) {
+		if err := os.MkdirAll(directory, 0o755); err != nil {
+			b.Fatal(err)
+		}
+		for i := 0; i < files; i++ {
+			if err := os.WriteFile(filepath.Join(directory, fmt.Sprintf("file%d.go", i)), nil, 0o644); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if level == depth {
+			return
+		}
+		for i := 0; i < width; i++ {
+			create(filepath.Join(directory, fmt.Sprintf("dir%d", i)), level+1)
+		}
+	}
+
+	for i := 0; i < top; i++ {
+		create(filepath.Join(root, fmt.Sprintf("src%d", i)), 0)
+	}
+	return root
+}
+
+func benchmarkWalk(b *testing.B, root string) {
+	for _, concurrency := range []int{1, 2, 4, 8, 16} {
+		b.Run(fmt.Sprintf("concurrency-%d", concurrency), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				fileListQueue := make(chan *gocodewalker.File, 1000)
+				fileWalker := gocodewalker.NewFileWalker(root, fileListQueue, gocodewalker.WithConcurrency(concurrency))
+
+				go func() {
+					_ = fileWalker.Start()
+				}()
+				for range fileListQueue {
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkWalkSingleDirectory walks a tree with everything below a single directory such as src,
+// which before directories were shared between workers at any depth was walked by one goroutine
+func BenchmarkWalkSingleDirectory(b *testing.B) {
+	benchmarkWalk(b, makeTree(b, 1, 8, 3, 10))
+}
+
+// BenchmarkWalkWideDirectory walks a tree with many directories at the root
+func BenchmarkWalkWideDirectory(b *testing.B) {
+	benchmarkWalk(b, makeTree(b, 32, 4, 2, 10))
+}
@@ -0,0 +1,450 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/boyter/gocodewalker/language"
+)
+
+// ErrInvalidConfig is wrapped by every error returned from WalkerConfig.Validate
+var ErrInvalidConfig = errors.New("invalid config")
+
+// WalkerConfig is every setting which changes what a FileWalker returns and how it walks.
+// It is embedded in FileWalker so the settings can be changed directly before walking,
+// and can be marshalled to and from JSON with regular expressions as strings.
+// Once walking starts the settings are copied, so changing them has no effect until the
+// next walk. Use DefaultConfig as the starting point when unmarshalling so that anything
+// not supplied keeps its default.
+type WalkerConfig struct {
+	LocationExcludePattern []string         `json:"location_exclude_pattern,omitempty"` // Case-sensitive patterns which exclude directory/file matches
+	IncludeDirectory       []string         `json:"include_directory,omitempty"`
+	ExcludeDirectory       []string         `json:"exclude_directory,omitempty"` // Paths to always ignore such as .git,.svn and .hg
+	IncludeFilename        []string         `json:"include_filename,omitempty"`
+	ExcludeFilename        []string         `json:"exclude_filename,omitempty"`
+	IncludeDirectoryRegex  []*regexp.Regexp `json:"include_directory_regex,omitempty"` // Must match regex as logical OR IE can match any of them
+	ExcludeDirectoryRegex  []*regexp.Regexp `json:"exclude_directory_regex,omitempty"`
+	IncludeFilenameRegex   []*regexp.Regexp `json:"include_filename_regex,omitempty"`
+	ExcludeFilenameRegex   []*regexp.Regexp `json:"exclude_filename_regex,omitempty"`
+	IncludeGlobs           []string         `json:"include_globs,omitempty"`           // Files must match one of these globs, such as src/**/*.{ts,tsx}, against their path relative to the root walked
+	ExcludeGlobs           []string         `json:"exclude_globs,omitempty"`           // Files and directories matching any of these globs are skipped, with everything below a directory
+	AllowListExtensions    []string         `json:"allow_list_extensions,omitempty"`   // Which extensions should be allowed, compared with the lowercased extension of each file so must be lowercase unless IgnoreCase is set
+	ExcludeListExtensions  []string         `json:"exclude_list_extensions,omitempty"` // Which extensions should be excluded, compared the same as AllowListExtensions
+	IncludeLanguages       []string         `json:"include_languages,omitempty"`       // Only files detected as one of these languages, such as Go or Rust, are returned, see the language package for the names
+	DetectLanguages        bool             `json:"detect_languages"`                  // Should the language of every file be detected and set on File.Language? Always the case when IncludeLanguages is set
+	IgnoreCase             bool             `json:"ignore_case"`                       // Should extensions, file and directory names, globs and ignore files match regardless of case, as git does with core.ignoreCase?
+	IgnoreIgnoreFile       bool             `json:"ignore_ignore_file"`                // Should .ignore files be respected?
+	IgnoreGitIgnore        bool             `json:"ignore_git_ignore"`                 // Should .gitignore files be respected?
+	IgnoreGitModules       bool             `json:"ignore_git_modules"`                // Should .gitmodules files be respected?
+	RespectGlobalGitIgnore bool             `json:"respect_global_git_ignore"`         // Should the users global git excludes file (core.excludesFile) be respected? Off by default and only applies to operating system walks
+	RespectProjectConfig   bool             `json:"respect_project_config"`            // Should .gocodewalker.json files change the settings for their directory and below?
+	CustomIgnore           []string         `json:"custom_ignore,omitempty"`           // Custom ignore filenames discovered while walking
+	CustomIgnorePatterns   []string         `json:"custom_ignore_patterns,omitempty"`  // Custom ignore patterns re-anchored at every directory
+	CustomIgnoreFiles      []string         `json:"custom_ignore_files,omitempty"`     // Paths to ignore files read once and anchored at the walk root (only the global git excludes are lower priority; any discovered ignore file overrides them)
+	IncludeHidden          bool             `json:"include_hidden"`                    // Should hidden files and directories be included/walked
+	MaxDepth               int              `json:"max_depth"`                         // How many directories deep to walk where -1 is no limit
+	IgnoreBinaryFiles      bool             `json:"ignore_binary_files"`               // Should we open the file and try to determine if it is binary?
+	IgnoreBinaryFileBytes  int              `json:"ignore_binary_file_bytes"`          // How many bytes should be used
+	StatFiles              bool             `json:"stat_files"`                        // Should File info be fetched while walking? Otherwise it is fetched when File.Info is first called
+	FollowSymlinks         bool             `json:"follow_symlinks"`                   // Should symlinks to directories be walked? Only applies to operating system walks
+	ConfineSymlinks        bool             `json:"confine_symlinks"`                  // When following symlinks should those which resolve outside the root being walked be skipped?
+	WatchPolling           bool             `json:"watch_polling"`                     // Should Watch poll for changes rather than use inotify? Always the case on other platforms and for fs.FS walks
+	WatchPollInterval      time.Duration    `json:"watch_poll_interval"`               // How often Watch polls for changes, defaulting to WatchPollInterval
+	Sorted                 bool             `json:"sorted"`                            // Should files be returned in the same depth-first order every walk? Entries are ordered by name unless a comparator is set
+	SnapshotPath           string           `json:"snapshot_path,omitempty"`           // File to record a snapshot of the walk to, which is used to skip reading unchanged directories on the next walk
+	Concurrency            int              `json:"concurrency"`                       // How many directories are walked concurrently, see SetConcurrency
+	ProgressInterval       time.Duration    `json:"progress_interval"`                 // How often the progress handler is called while walking, defaulting to ProgressInterval
+	BatchSize              int              `json:"batch_size"`                        // How many files at most are passed to a Sink at once where 0 is every file found in a directory
+	EmitDirectories        bool             `json:"emit_directories"`                  // Should directories which pass every directory filter be returned along with files? Their Type is FileTypeDirectory
+	EmitLeaveDirectories   bool             `json:"emit_leave_directories"`            // Should a FileTypeLeaveDirectory be returned once everything below a directory has been? Requires EmitDirectories
+	FileTypes              FileTypePolicy   `json:"file_types"`                        // Which types of file are returned, by default regular files and symlinks, see FileTypePolicy
+	MinSize                int64            `json:"min_size"`                          // Files smaller than this many bytes are skipped where 0 is no limit
+	MaxSize                int64            `json:"max_size"`                          // Files larger than this many bytes are skipped where 0 is no limit
+	ModifiedAfter          time.Time        `json:"modified_after"`                    // Files not modified after this are skipped unless it is the zero time
+	ModifiedBefore         time.Time        `json:"modified_before"`                   // Files not modified before this are skipped unless it is the zero time
+	IncludePermissions     fs.FileMode      `json:"include_permissions"`               // Files without every one of these permission bits, such as 0o100 for executable by the owner, are skipped
+	ExcludePermissions     fs.FileMode      `json:"exclude_permissions"`               // Files with any of these permission bits, such as 0o002 for writable by anyone, are skipped
+	OwnerUIDs              []int            `json:"owner_uids,omitempty"`              // Files not owned by one of these users are skipped, including every file where the owner is not known such as on windows
+}
+
+// DefaultConfig returns the settings a FileWalker is constructed with
+func DefaultConfig() WalkerConfig {
+	return WalkerConfig{
+		MaxDepth:              -1,
+		IgnoreBinaryFileBytes: IgnoreBinaryFileBytes,
+		WatchPollInterval:     WatchPollInterval,
+		Concurrency:           semaphoreCount,
+		ProgressInterval:      ProgressInterval,
+		FileTypes:             DefaultFileTypePolicy(),
+	}
+}
+
+// Validate checks the settings make sense, returning every problem found joined
+// together with each wrapping ErrInvalidConfig, or nil if there are none
+func (c WalkerConfig) Validate() error {
+	var errs []error
+	invalid := func(format string, a ...any) {
+		errs = append(errs, fmt.Errorf("%w: %s", ErrInvalidConfig, fmt.Sprintf(format, a...)))
+	}
+
+	regexps := []struct {
+		name    string
+		regexps []*regexp.Regexp
+	}{
+		{"IncludeDirectoryRegex", c.IncludeDirectoryRegex},
+		{"ExcludeDirectoryRegex", c.ExcludeDirectoryRegex},
+		{"IncludeFilenameRegex", c.IncludeFilenameRegex},
+		{"ExcludeFilenameRegex", c.ExcludeFilenameRegex},
+	}
+	for _, r := range regexps {
+		if slices.Contains(r.regexps, nil) {
+			invalid("%s contains a nil regular expression", r.name)
+		}
+	}
+
+	globs := []struct {
+		name  string
+		globs []string
+	}{
+		{"IncludeGlobs", c.IncludeGlobs},
+		{"ExcludeGlobs", c.ExcludeGlobs},
+	}
+	for _, g := range globs {
+		for _, pattern := range g.globs {
+			if _, err := compileGlob(pattern, c.IgnoreCase); err != nil {
+				invalid("%s contains an invalid %s", g.name, err)
+			}
+		}
+	}
+
+	conflicts := []struct {
+		include string
+		exclude string
+		a       []string
+		b       []string
+	}{
+		{"IncludeDirectory", "ExcludeDirectory", c.IncludeDirectory, c.ExcludeDirectory},
+		{"IncludeFilename", "ExcludeFilename", c.IncludeFilename, c.ExcludeFilename},
+		{"AllowListExtensions", "ExcludeListExtensions", c.AllowListExtensions, c.ExcludeListExtensions},
+		{"IncludeDirectoryRegex", "ExcludeDirectoryRegex", regexpSources(c.IncludeDirectoryRegex), regexpSources(c.ExcludeDirectoryRegex)},
+		{"IncludeFilenameRegex", "ExcludeFilenameRegex", regexpSources(c.IncludeFilenameRegex), regexpSources(c.ExcludeFilenameRegex)},
+		{"IncludeGlobs", "ExcludeGlobs", c.IncludeGlobs, c.ExcludeGlobs},
+	}
+	for _, conflict := range conflicts {
+		for _, value := range conflict.a {
+			if slices.Contains(conflict.b, value) {
+				invalid("%q is in both %s and %s", value, conflict.include, conflict.exclude)
+			}
+		}
+	}
+
+	if c.MaxDepth < -1 {
+		invalid("MaxDepth %d must be -1 for no limit or 0 and above", c.MaxDepth)
+	}
+	if c.FileTypes == (FileTypePolicy{}) {
+		invalid("FileTypes allows no type of file, DefaultFileTypePolicy allows regular files and symlinks")
+	}
+	if c.IgnoreBinaryFiles && c.IgnoreBinaryFileBytes <= 0 {
+		invalid("IgnoreBinaryFileBytes %d must be above 0 when IgnoreBinaryFiles is set", c.IgnoreBinaryFileBytes)
+	}
+	if c.WatchPollInterval < 0 {
+		invalid("WatchPollInterval %s must not be negative", c.WatchPollInterval)
+	}
+	if c.Concurrency < 0 {
+		invalid("Concurrency %d must not be negative", c.Concurrency)
+	}
+	if c.ProgressInterval < 0 {
+		invalid("ProgressInterval %s must not be negative", c.ProgressInterval)
+	}
+	if c.BatchSize < 0 {
+		invalid("BatchSize %d must not be negative", c.BatchSize)
+	}
+	if c.MinSize < 0 {
+		invalid("MinSize %d must not be negative", c.MinSize)
+	}
+	if c.MaxSize < 0 {
+		invalid("MaxSize %d must not be negative", c.MaxSize)
+	}
+	if c.MaxSize != 0 && c.MaxSize < c.MinSize {
+		invalid("MaxSize %d must not be below MinSize %d", c.MaxSize, c.MinSize)
+	}
+	if !c.ModifiedAfter.IsZero() && !c.ModifiedBefore.IsZero() && !c.ModifiedAfter.Before(c.ModifiedBefore) {
+		invalid("ModifiedAfter %s must be before ModifiedBefore %s", c.ModifiedAfter, c.ModifiedBefore)
+	}
+	if c.IncludePermissions&^fs.ModePerm != 0 || c.ExcludePermissions&^fs.ModePerm != 0 {
+		invalid("IncludePermissions and ExcludePermissions must only contain permission bits")
+	}
+	if c.IncludePermissions&c.ExcludePermissions != 0 {
+		invalid("%s is in both IncludePermissions and ExcludePermissions", c.IncludePermissions&c.ExcludePermissions)
+	}
+	for _, name := range c.IncludeLanguages {
+		if _, ok := language.Lookup(name); !ok {
+			invalid("IncludeLanguages contains %q which is not a known language", name)
+		}
+	}
+	if c.EmitLeaveDirectories && !c.EmitDirectories {
+		invalid("EmitLeaveDirectories requires EmitDirectories")
+	}
+
+	return errors.Join(errs...)
+}
+
+// regexpSources returns the source of each regular expression, skipping any which are nil
+func regexpSources(regexps []*regexp.Regexp) []string {
+	sources := make([]string, 0, len(regexps))
+	for _, r := range regexps {
+		if r != nil {
+			sources = append(sources, r.String())
+		}
+	}
+	return sources
+}
+
+// clone returns a copy of the settings which shares nothing that can be changed with the original
+func (c WalkerConfig) clone() WalkerConfig {
+	c.LocationExcludePattern = slices.Clone(c.LocationExcludePattern)
+	c.IncludeDirectory = slices.Clone(c.IncludeDirectory)
+	c.ExcludeDirectory = slices.Clone(c.ExcludeDirectory)
+	c.IncludeFilename = slices.Clone(c.IncludeFilename)
+	c.ExcludeFilename = slices.Clone(c.ExcludeFilename)
+	c.IncludeDirectoryRegex = slices.Clone(c.IncludeDirectoryRegex)
+	c.ExcludeDirectoryRegex = slices.Clone(c.ExcludeDirectoryRegex)
+	c.IncludeFilenameRegex = slices.Clone(c.IncludeFilenameRegex)
+	c.ExcludeFilenameRegex = slices.Clone(c.ExcludeFilenameRegex)
+	c.IncludeGlobs = slices.Clone(c.IncludeGlobs)
+	c.ExcludeGlobs = slices.Clone(c.ExcludeGlobs)
+	c.AllowListExtensions = slices.Clone(c.AllowListExtensions)
+	c.ExcludeListExtensions = slices.Clone(c.ExcludeListExtensions)
+	c.IncludeLanguages = slices.Clone(c.IncludeLanguages)
+	c.CustomIgnore = slices.Clone(c.CustomIgnore)
+	c.CustomIgnorePatterns = slices.Clone(c.CustomIgnorePatterns)
+	c.CustomIgnoreFiles = slices.Clone(c.CustomIgnoreFiles)
+	c.OwnerUIDs = slices.Clone(c.OwnerUIDs)
+	return c
+}
+
+// Config returns a copy of the current settings
+func (f *FileWalker) Config() WalkerConfig {
+	f.walkMutex.Lock()
+	defer f.walkMutex.Unlock()
+	return f.WalkerConfig.clone()
+}
+
+// ApplyConfig replaces the current settings with a copy of the supplied ones after
+// validating them. Returns an error without changing anything if they are invalid or
+// the walker is walking.
+func (f *FileWalker) ApplyConfig(c WalkerConfig) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	f.walkMutex.Lock()
+	defer f.walkMutex.Unlock()
+	if f.isWalking {
+		return fmt.Errorf("%w: cannot be changed while walking", ErrInvalidConfig)
+	}
+	f.WalkerConfig = c.clone()
+	return nil
+}
+
+// freezeConfig validates the settings and copies them for use while walking, so that
+// changing them once walking has started has no effect until the next walk. Globs are
+// compiled here so it is done once per walk. Must be called with the walkMutex held.
+func (f *FileWalker) freezeConfig() error {
+	c, include, exclude, err := f.frozenConfig()
+	if err != nil {
+		return err
+	}
+
+	f.cfg = c
+	f.includeGlobs, f.excludeGlobs = include, exclude
+	return nil
+}
+
+// frozenConfig validates the settings returning a copy of them along with the compiled
+// include and exclude globs, without changing the walker. Must be called with the walkMutex held.
+func (f *FileWalker) frozenConfig() (*WalkerConfig, []*glob, []*glob, error) {
+	if err := f.WalkerConfig.Validate(); err != nil {
+		return nil, nil, nil, err
+	}
+	c := f.WalkerConfig.clone()
+
+	// Validate has checked they compile
+	include, _ := compileGlobs(c.IncludeGlobs, c.IgnoreCase)
+	exclude, _ := compileGlobs(c.ExcludeGlobs, c.IgnoreCase)
+	return &c, include, exclude, nil
+}
+
+// WithConfig replaces every setting with a copy of the supplied ones,
+// which are validated along with any other changes when walking starts
+func WithConfig(c WalkerConfig) Option {
+	return func(f *FileWalker) {
+		f.WalkerConfig = c.clone()
+	}
+}
+
+// WithErrorHandler sets the error handler, see SetErrorHandler
+func WithErrorHandler(handler func(error) bool) Option {
+	return func(f *FileWalker) {
+		f.SetErrorHandler(handler)
+	}
+}
+
+// WithSkipHandler sets the skip handler, see SetSkipHandler
+func WithSkipHandler(handler func(path string, name string, isDir bool, reason SkipReason)) Option {
+	return func(f *FileWalker) {
+		f.SetSkipHandler(handler)
+	}
+}
+
+// WithSkipDetailHandler sets the skip detail handler, see SetSkipDetailHandler
+func WithSkipDetailHandler(handler func(explanation *Explanation)) Option {
+	return func(f *FileWalker) {
+		f.SetSkipDetailHandler(handler)
+	}
+}
+
+// WithContentDetector sets the ContentDetector, see SetContentDetector
+func WithContentDetector(detector ContentDetector) Option {
+	return func(f *FileWalker) {
+		f.SetContentDetector(detector)
+	}
+}
+
+// WithComparator sets Sorted along with the comparator used to order entries, see SetComparator
+func WithComparator(compare func(a fs.DirEntry, b fs.DirEntry) int) Option {
+	return func(f *FileWalker) {
+		f.Sorted = true
+		f.SetComparator(compare)
+	}
+}
+
+// WithConcurrency sets how many directories are walked concurrently, see SetConcurrency
+func WithConcurrency(i int) Option {
+	return func(f *FileWalker) {
+		f.SetConcurrency(i)
+	}
+}
+
+// WithBatchSize sets how many files at most are passed to a Sink at once, see BatchSize
+func WithBatchSize(size int) Option {
+	return func(f *FileWalker) {
+		f.BatchSize = size
+	}
+}
+
+// WithEmitDirectories sets if directories are returned along with files, and if
+// a leave directory event is returned once everything below each has been
+func WithEmitDirectories(emit bool, leave bool) Option {
+	return func(f *FileWalker) {
+		f.EmitDirectories = emit
+		f.EmitLeaveDirectories = leave
+	}
+}
+
+// WithFileTypes sets which types of file are returned, see FileTypePolicy
+func WithFileTypes(policy FileTypePolicy) Option {
+	return func(f *FileWalker) {
+		f.FileTypes = policy
+	}
+}
+
+// WithSizeRange skips files smaller than min or larger than max bytes, where 0 is no limit
+func WithSizeRange(min int64, max int64) Option {
+	return func(f *FileWalker) {
+		f.MinSize = min
+		f.MaxSize = max
+	}
+}
+
+// WithModifiedRange skips files not modified after the first time or before the second,
+// where the zero time is no limit
+func WithModifiedRange(after time.Time, before time.Time) Option {
+	return func(f *FileWalker) {
+		f.ModifiedAfter = after
+		f.ModifiedBefore = before
+	}
+}
+
+// WithOwners skips files not owned by one of the supplied users, see OwnerUIDs
+func WithOwners(uids ...int) Option {
+	return func(f *FileWalker) {
+		f.OwnerUIDs = append(f.OwnerUIDs, uids...)
+	}
+}
+
+// WithMaxDepth sets how many directories deep to walk where -1 is no limit
+func WithMaxDepth(depth int) Option {
+	return func(f *FileWalker) {
+		f.MaxDepth = depth
+	}
+}
+
+// WithAllowListExtensions restricts the files returned to those with the supplied extensions
+func WithAllowListExtensions(extensions ...string) Option {
+	return func(f *FileWalker) {
+		f.AllowListExtensions = append(f.AllowListExtensions, extensions...)
+	}
+}
+
+// WithExcludeListExtensions skips files with the supplied extensions
+func WithExcludeListExtensions(extensions ...string) Option {
+	return func(f *FileWalker) {
+		f.ExcludeListExtensions = append(f.ExcludeListExtensions, extensions...)
+	}
+}
+
+// WithExcludeDirectory skips directories with the supplied names
+func WithExcludeDirectory(names ...string) Option {
+	return func(f *FileWalker) {
+		f.ExcludeDirectory = append(f.ExcludeDirectory, names...)
+	}
+}
+
+// WithIncludeGlobs adds globs files must match one of, see IncludeGlobs
+func WithIncludeGlobs(globs ...string) Option {
+	return func(f *FileWalker) {
+		f.IncludeGlobs = append(f.IncludeGlobs, globs...)
+	}
+}
+
+// WithExcludeGlobs adds globs files and directories are skipped for matching, see ExcludeGlobs
+func WithExcludeGlobs(globs ...string) Option {
+	return func(f *FileWalker) {
+		f.ExcludeGlobs = append(f.ExcludeGlobs, globs...)
+	}
+}
+
+// WithIncludeLanguages adds languages files must be detected as one of, see IncludeLanguages
+func WithIncludeLanguages(languages ...string) Option {
+	return func(f *FileWalker) {
+		f.IncludeLanguages = append(f.IncludeLanguages, languages...)
+	}
+}
+
+// WithIncludeHidden sets if hidden files and directories are returned and walked
+func WithIncludeHidden(include bool) Option {
+	return func(f *FileWalker) {
+		f.IncludeHidden = include
+	}
+}
+
+// WithIgnoreBinaryFiles skips binary files, see IgnoreBinaryFiles
+func WithIgnoreBinaryFiles(ignore bool) Option {
+	return func(f *FileWalker) {
+		f.IgnoreBinaryFiles = ignore
+	}
+}
+
+// WithSorted sets if files are returned in the same order every walk, see Sorted
+func WithSorted(sorted bool) Option {
+	return func(f *FileWalker) {
+		f.Sorted = sorted
+	}
+}
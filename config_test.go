@@ -0,0 +1,230 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestNewFileWalkerDefaultConfig(t *testing.T) {
+	single := NewFileWalker(".", make(chan *File))
+	parallel := NewParallelFileWalker([]string{"."}, make(chan *File))
+
+	if !reflect.DeepEqual(single.Config(), DefaultConfig()) {
+		t.Errorf("expected default config got %+v", single.Config())
+	}
+	if !reflect.DeepEqual(parallel.Config(), DefaultConfig()) {
+		t.Errorf("expected default config got %+v", parallel.Config())
+	}
+}
+
+func TestNewFileWalkerOptions(t *testing.T) {
+	walker := NewFileWalker(".", make(chan *File),
+		WithMaxDepth(2),
+		WithAllowListExtensions("go", "md"),
+		WithExcludeDirectory("vendor"),
+		WithIncludeHidden(true),
+		WithConcurrency(3),
+	)
+
+	if walker.MaxDepth != 2 {
+		t.Errorf("expected MaxDepth 2 got %d", walker.MaxDepth)
+	}
+	if !reflect.DeepEqual(walker.AllowListExtensions, []string{"go", "md"}) {
+		t.Errorf("expected go and md got %v", walker.AllowListExtensions)
+	}
+	if !reflect.DeepEqual(walker.ExcludeDirectory, []string{"vendor"}) {
+		t.Errorf("expected vendor got %v", walker.ExcludeDirectory)
+	}
+	if !walker.IncludeHidden {
+		t.Error("expected IncludeHidden")
+	}
+	if walker.Concurrency != 3 {
+		t.Errorf("expected Concurrency 3 got %d", walker.Concurrency)
+	}
+}
+
+func TestWalkerConfigJSONRoundTrip(t *testing.T) {
+	c := DefaultConfig()
+	c.ExcludeDirectory = []string{"vendor"}
+	c.IncludeFilenameRegex = []*regexp.Regexp{regexp.MustCompile(`\.go$`)}
+	c.IgnoreBinaryFiles = true
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := DefaultConfig()
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(decoded.ExcludeDirectory, c.ExcludeDirectory) {
+		t.Errorf("expected %v got %v", c.ExcludeDirectory, decoded.ExcludeDirectory)
+	}
+	if len(decoded.IncludeFilenameRegex) != 1 || decoded.IncludeFilenameRegex[0].String() != `\.go$` {
+		t.Errorf("expected \\.go$ got %v", decoded.IncludeFilenameRegex)
+	}
+	if !decoded.IgnoreBinaryFiles || decoded.MaxDepth != -1 {
+		t.Errorf("expected settings to survive round trip got %+v", decoded)
+	}
+}
+
+func TestWalkerConfigJSONInvalidRegex(t *testing.T) {
+	decoded := DefaultConfig()
+	err := json.Unmarshal([]byte(`{"include_filename_regex": ["("]}`), &decoded)
+	if err == nil {
+		t.Error("expected error for invalid regular expression")
+	}
+}
+
+func TestWalkerConfigValidate(t *testing.T) {
+	cases := []struct {
+		name   string
+		change func(c *WalkerConfig)
+		valid  bool
+	}{
+		{"default", func(c *WalkerConfig) {}, true},
+		{"max depth zero", func(c *WalkerConfig) { c.MaxDepth = 0 }, true},
+		{"max depth negative", func(c *WalkerConfig) { c.MaxDepth = -2 }, false},
+		{"binary bytes zero", func(c *WalkerConfig) { c.IgnoreBinaryFiles = true; c.IgnoreBinaryFileBytes = 0 }, false},
+		{"binary bytes unused", func(c *WalkerConfig) { c.IgnoreBinaryFileBytes = 0 }, true},
+		{"no file types", func(c *WalkerConfig) { c.FileTypes = FileTypePolicy{} }, false},
+		{"only fifos", func(c *WalkerConfig) { c.FileTypes = FileTypePolicy{FIFO: true} }, true},
+		{"negative concurrency", func(c *WalkerConfig) { c.Concurrency = -1 }, false},
+		{"negative poll interval", func(c *WalkerConfig) { c.WatchPollInterval = -1 }, false},
+		{"negative progress interval", func(c *WalkerConfig) { c.ProgressInterval = -1 }, false},
+		{"negative batch size", func(c *WalkerConfig) { c.BatchSize = -1 }, false},
+		{"negative min size", func(c *WalkerConfig) { c.MinSize = -1 }, false},
+		{"max size below min size", func(c *WalkerConfig) { c.MinSize = 10; c.MaxSize = 5 }, false},
+		{"max size unset", func(c *WalkerConfig) { c.MinSize = 10 }, true},
+		{"empty modified range", func(c *WalkerConfig) {
+			c.ModifiedAfter = time.Now()
+			c.ModifiedBefore = c.ModifiedAfter.Add(-time.Hour)
+		}, false},
+		{"conflicting permissions", func(c *WalkerConfig) { c.IncludePermissions = 0o100; c.ExcludePermissions = 0o111 }, false},
+		{"permissions with type bits", func(c *WalkerConfig) { c.IncludePermissions = fs.ModeDir }, false},
+		{"nil regex", func(c *WalkerConfig) { c.ExcludeFilenameRegex = []*regexp.Regexp{nil} }, false},
+		{"unknown language", func(c *WalkerConfig) { c.IncludeLanguages = []string{"Go", "Klingon"} }, false},
+		{"known language", func(c *WalkerConfig) { c.IncludeLanguages = []string{"go", "C++"} }, true},
+		{"invalid glob", func(c *WalkerConfig) { c.IncludeGlobs = []string{"src/[a-"} }, false},
+		{"valid globs", func(c *WalkerConfig) {
+			c.IncludeGlobs = []string{"src/**/*.{ts,tsx}"}
+			c.ExcludeGlobs = []string{"**/[!a-z]*"}
+		}, true},
+		{"conflicting glob", func(c *WalkerConfig) { c.IncludeGlobs = []string{"**/*.go"}; c.ExcludeGlobs = []string{"**/*.go"} }, false},
+		{"conflicting extension", func(c *WalkerConfig) {
+			c.AllowListExtensions = []string{"go"}
+			c.ExcludeListExtensions = []string{"go"}
+		}, false},
+		{"conflicting directory", func(c *WalkerConfig) {
+			c.IncludeDirectory = []string{"src"}
+			c.ExcludeDirectory = []string{"src"}
+		}, false},
+		{"conflicting regex", func(c *WalkerConfig) {
+			c.IncludeDirectoryRegex = []*regexp.Regexp{regexp.MustCompile("src")}
+			c.ExcludeDirectoryRegex = []*regexp.Regexp{regexp.MustCompile("src")}
+		}, false},
+		{"different include and exclude", func(c *WalkerConfig) {
+			c.AllowListExtensions = []string{"go"}
+			c.ExcludeListExtensions = []string{"md"}
+		}, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := DefaultConfig()
+			tc.change(&c)
+			err := c.Validate()
+			if tc.valid && err != nil {
+				t.Errorf("expected valid got %v", err)
+			}
+			if !tc.valid && !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("expected ErrInvalidConfig got %v", err)
+			}
+		})
+	}
+}
+
+func TestStartInvalidConfig(t *testing.T) {
+	fileListQueue := make(chan *File, 10)
+	walker := NewFileWalker(makeContextTree(t), fileListQueue, WithMaxDepth(-5))
+
+	err := walker.Start()
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig got %v", err)
+	}
+	if _, ok := <-fileListQueue; ok {
+		t.Error("expected queue to be closed without any files")
+	}
+}
+
+func TestStartConfigWithoutDefaults(t *testing.T) {
+	// a config not built from DefaultConfig has no file types so would otherwise return nothing
+	walker := NewFileWalkerFS(mapFS(map[string]string{"main.go": ""}), ".", nil, WithConfig(WalkerConfig{MaxDepth: -1}))
+	if err := walker.Start(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig got %v", err)
+	}
+}
+
+func TestConfigFrozenWhileWalking(t *testing.T) {
+	fileListQueue := make(chan *File)
+	walker := NewFileWalker(makeContextTree(t), fileListQueue)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- walker.Start()
+	}()
+
+	count := 0
+	for range fileListQueue {
+		if count == 0 {
+			// neither of these should change what the walk already started returns
+			walker.AllowListExtensions = append(walker.AllowListExtensions, "md")
+			if err := walker.ApplyConfig(WalkerConfig{MaxDepth: 0}); !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("expected ErrInvalidConfig applying config while walking got %v", err)
+			}
+		}
+		count++
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if count != 100 {
+		t.Errorf("expected 100 files got %d", count)
+	}
+	if walker.MaxDepth != -1 {
+		t.Errorf("expected MaxDepth to be unchanged got %d", walker.MaxDepth)
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	walker := NewFileWalker(".", make(chan *File))
+
+	c := DefaultConfig()
+	c.AllowListExtensions = []string{"go"}
+	if err := walker.ApplyConfig(c); err != nil {
+		t.Fatal(err)
+	}
+
+	c.AllowListExtensions[0] = "md"
+	if walker.AllowListExtensions[0] != "go" {
+		t.Error("expected ApplyConfig to copy the settings")
+	}
+
+	c.MaxDepth = -2
+	if err := walker.ApplyConfig(c); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig got %v", err)
+	}
+	if walker.MaxDepth != -1 {
+		t.Errorf("expected invalid config to not be applied got MaxDepth %d", walker.MaxDepth)
+	}
+}
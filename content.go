@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// Content is what was detected about the content of a file from its first IgnoreBinaryFileBytes bytes
+type Content struct {
+	Binary   bool   `json:"binary"`
+	Type     string `json:"type,omitempty"`     // The type of binary file such as png or zip, empty if unknown
+	Encoding string `json:"encoding,omitempty"` // The encoding of a text file such as utf-8 or utf-16le, empty if unknown
+}
+
+// String returns the type for binary files and the encoding for text files
+func (c Content) String() string {
+	if c.Binary {
+		if c.Type == "" {
+			return "binary"
+		}
+		return c.Type
+	}
+	if c.Encoding == "" {
+		return "text"
+	}
+	return c.Encoding
+}
+
+// ContentDetector decides if a file is binary when IgnoreBinaryFiles is set,
+// given the first IgnoreBinaryFileBytes bytes of it, or all of it if shorter
+type ContentDetector interface {
+	Detect(head []byte) Content
+}
+
+// ContentDetectorFunc allows a function to be used as a ContentDetector
+type ContentDetectorFunc func(head []byte) Content
+
+func (d ContentDetectorFunc) Detect(head []byte) Content {
+	return d(head)
+}
+
+// NullByteDetector treats any file containing a null byte as binary, which is
+// fast and mostly accurate. This was the only check before ContentDetector was added
+var NullByteDetector = ContentDetectorFunc(func(head []byte) Content {
+	return Content{Binary: bytes.IndexByte(head, 0) != -1}
+})
+
+// DefaultNonPrintableRatio is the proportion of control characters above
+// which DefaultContentDetector considers a file without a null byte binary
+var DefaultNonPrintableRatio = 0.3
+
+// DefaultContentDetector is the ContentDetector used unless another is set. It checks for a
+// byte order mark, so that UTF-16 and UTF-32 text which is full of null bytes is not considered
+// binary, then for the signature of common binary formats, so that those without a null byte
+// in the bytes read are, then for a null byte, and finally the proportion of control characters
+type DefaultContentDetector struct {
+	NonPrintableRatio float64 // Defaults to DefaultNonPrintableRatio if 0
+}
+
+// byteOrderMarks are checked in order, so UTF-32LE must come before UTF-16LE which it starts with
+var byteOrderMarks = []struct {
+	encoding string
+	mark     []byte
+}{
+	{"utf-32le", []byte{0xFF, 0xFE, 0x00, 0x00}},
+	{"utf-32be", []byte{0x00, 0x00, 0xFE, 0xFF}},
+	{"utf-8", []byte{0xEF, 0xBB, 0xBF}},
+	{"utf-16le", []byte{0xFF, 0xFE}},
+	{"utf-16be", []byte{0xFE, 0xFF}},
+}
+
+// signatures are the magic numbers of common binary formats found at the offset into the file.
+// Signatures short enough to be the start of a text file, such as MZ or BM, are left to the null byte check
+var signatures = []struct {
+	kind   string
+	offset int
+	magic  []byte
+}{
+	{"png", 0, []byte("\x89PNG\r\n\x1a\n")},
+	{"jpeg", 0, []byte{0xFF, 0xD8, 0xFF}},
+	{"gif", 0, []byte("GIF87a")},
+	{"gif", 0, []byte("GIF89a")},
+	{"webp", 8, []byte("WEBP")},
+	{"tiff", 0, []byte("II*\x00")},
+	{"tiff", 0, []byte("MM\x00*")},
+	{"ico", 0, []byte{0x00, 0x00, 0x01, 0x00}},
+	{"psd", 0, []byte("8BPS")},
+	{"pdf", 0, []byte("%PDF-")},
+	{"zip", 0, []byte("PK\x03\x04")},
+	{"zip", 0, []byte("PK\x05\x06")},
+	{"zip", 0, []byte("PK\x07\x08")},
+	{"gzip", 0, []byte{0x1F, 0x8B}},
+	{"bzip2", 0, []byte("BZh")},
+	{"xz", 0, []byte{0xFD, '7', 'z', 'X', 'Z', 0x00}},
+	{"zstd", 0, []byte{0x28, 0xB5, 0x2F, 0xFD}},
+	{"lz4", 0, []byte{0x04, 0x22, 0x4D, 0x18}},
+	{"7z", 0, []byte{'7', 'z', 0xBC, 0xAF, 0x27, 0x1C}},
+	{"rar", 0, []byte("Rar!\x1a\x07")},
+	{"tar", 257, []byte("ustar")},
+	{"elf", 0, []byte("\x7fELF")},
+	{"mach-o", 0, []byte{0xFE, 0xED, 0xFA, 0xCE}},
+	{"mach-o", 0, []byte{0xFE, 0xED, 0xFA, 0xCF}},
+	{"mach-o", 0, []byte{0xCE, 0xFA, 0xED, 0xFE}},
+	{"mach-o", 0, []byte{0xCF, 0xFA, 0xED, 0xFE}},
+	{"class", 0, []byte{0xCA, 0xFE, 0xBA, 0xBE}},
+	{"wasm", 0, []byte("\x00asm")},
+	{"sqlite", 0, []byte("SQLite format 3\x00")},
+	{"ogg", 0, []byte("OggS")},
+	{"flac", 0, []byte("fLaC")},
+	{"mp3", 0, []byte("ID3")},
+	{"mp4", 4, []byte("ftyp")},
+	{"wav", 8, []byte("WAVE")},
+	{"woff", 0, []byte("wOFF")},
+	{"woff2", 0, []byte("wOF2")},
+	{"otf", 0, []byte("OTTO")},
+	{"ttf", 0, []byte{0x00, 0x01, 0x00, 0x00, 0x00}},
+}
+
+// Detect implements ContentDetector
+func (d DefaultContentDetector) Detect(head []byte) Content {
+	for _, bom := range byteOrderMarks {
+		if bytes.HasPrefix(head, bom.mark) {
+			return Content{Encoding: bom.encoding}
+		}
+	}
+
+	for _, signature := range signatures {
+		if len(head) >= signature.offset+len(signature.magic) &&
+			bytes.Equal(head[signature.offset:signature.offset+len(signature.magic)], signature.magic) {
+			return Content{Binary: true, Type: signature.kind}
+		}
+	}
+
+	if bytes.IndexByte(head, 0) != -1 {
+		return Content{Binary: true}
+	}
+
+	ratio := d.NonPrintableRatio
+	if ratio == 0 {
+		ratio = DefaultNonPrintableRatio
+	}
+
+	nonPrintable := 0
+	for _, b := range head {
+		if (b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != '\f' && b != '\v' && b != 0x1B) || b == 0x7F {
+			nonPrintable++
+		}
+	}
+	if len(head) != 0 && float64(nonPrintable)/float64(len(head)) > ratio {
+		return Content{Binary: true}
+	}
+
+	if validUTF8Prefix(head) {
+		return Content{Encoding: "utf-8"}
+	}
+	return Content{}
+}
+
+// validUTF8Prefix returns true if the bytes are valid UTF-8, allowing for the
+// last character to have been cut short because only the start of the file was read
+func validUTF8Prefix(head []byte) bool {
+	for i := 0; i < utf8.UTFMax && i < len(head); i++ {
+		start := len(head) - 1 - i
+		if utf8.RuneStart(head[start]) {
+			if !utf8.FullRune(head[start:]) {
+				head = head[:start]
+			}
+			break
+		}
+	}
+	return utf8.Valid(head)
+}
+
+// SetContentDetector sets the ContentDetector used to decide if files are binary
+// when IgnoreBinaryFiles is set, which by default is DefaultContentDetector
+func (f *FileWalker) SetContentDetector(detector ContentDetector) {
+	f.walkMutex.Lock()
+	defer f.walkMutex.Unlock()
+	f.contentDetector = detector
+}
@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestDefaultContentDetector(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Head     []byte
+		Expected Content
+	}{
+		{"empty", []byte{}, Content{Encoding: "utf-8"}},
+		{"ascii", []byte("package main\n\nfunc main() {}\n"), Content{Encoding: "utf-8"}},
+		{"utf-8", []byte("héllo wörld"), Content{Encoding: "utf-8"}},
+		{"utf-8 cut short", []byte("héllo wörld \xe2\x82"), Content{Encoding: "utf-8"}},
+		{"utf-8 bom", []byte("\xef\xbb\xbfhello"), Content{Encoding: "utf-8"}},
+		{"latin-1", []byte("caf\xe9 cr\xe8me"), Content{}},
+		{"utf-16le bom", []byte("\xff\xfeh\x00i\x00"), Content{Encoding: "utf-16le"}},
+		{"utf-16be bom", []byte("\xfe\xff\x00h\x00i"), Content{Encoding: "utf-16be"}},
+		{"utf-32le bom", []byte("\xff\xfe\x00\x00h\x00\x00\x00"), Content{Encoding: "utf-32le"}},
+		{"utf-32be bom", []byte("\x00\x00\xfe\xff\x00\x00\x00h"), Content{Encoding: "utf-32be"}},
+		{"png", []byte("\x89PNG\r\n\x1a\nIHDR"), Content{Binary: true, Type: "png"}},
+		{"pdf", []byte("%PDF-1.7\n%comment\n"), Content{Binary: true, Type: "pdf"}},
+		{"gzip", []byte{0x1f, 0x8b, 0x08, 0x01}, Content{Binary: true, Type: "gzip"}},
+		{"webp", []byte("RIFF\x10\x20\x30\x40WEBPVP8 "), Content{Binary: true, Type: "webp"}},
+		{"null byte", []byte("a\x00b"), Content{Binary: true}},
+		{"control characters", []byte("\x01\x02\x03\x04abc"), Content{Binary: true}},
+		{"some control characters", []byte("\x1b[31mred\x1b[0m and \x07 text"), Content{Encoding: "utf-8"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			got := DefaultContentDetector{}.Detect(tc.Head)
+			if got != tc.Expected {
+				t.Errorf("expected %+v got %+v", tc.Expected, got)
+			}
+		})
+	}
+}
+
+func TestDefaultContentDetectorRatio(t *testing.T) {
+	head := []byte("\x01\x02abcdefgh")
+
+	if !(DefaultContentDetector{NonPrintableRatio: 0.1}).Detect(head).Binary {
+		t.Error("expected binary with a low ratio")
+	}
+	if (DefaultContentDetector{NonPrintableRatio: 0.5}).Detect(head).Binary {
+		t.Error("expected text with a high ratio")
+	}
+}
+
+func TestNullByteDetector(t *testing.T) {
+	if NullByteDetector.Detect([]byte("\xff\xfeh\x00")) != (Content{Binary: true}) {
+		t.Error("expected null byte to be binary")
+	}
+	if NullByteDetector.Detect([]byte("\x89PNG\r\n\x1a\n")) != (Content{}) {
+		t.Error("expected no null byte to be text")
+	}
+}
+
+func TestContentReportedOnFileAndSkip(t *testing.T) {
+	d := t.TempDir()
+	writeFile(t, filepath.Join(d, "utf16.txt"), "\xff\xfeh\x00i\x00")
+	writeFile(t, filepath.Join(d, "image.png"), "\x89PNG\r\n\x1a\nIHDR")
+
+	fileListQueue := make(chan *File, 10)
+	walker := NewFileWalker(d, fileListQueue)
+	walker.IgnoreBinaryFiles = true
+
+	var explanations []*Explanation
+	walker.SetSkipDetailHandler(func(explanation *Explanation) {
+		explanations = append(explanations, explanation)
+	})
+	go func() {
+		_ = walker.Start()
+	}()
+
+	var files []*File
+	for f := range fileListQueue {
+		files = append(files, f)
+	}
+
+	if len(files) != 1 || files[0].Filename != "utf16.txt" {
+		t.Fatalf("expected only utf16.txt got %v", files)
+	}
+	if files[0].Content == nil || files[0].Content.Encoding != "utf-16le" {
+		t.Errorf("expected utf-16le content got %v", files[0].Content)
+	}
+
+	if len(explanations) != 1 {
+		t.Fatalf("expected 1 skip got %d", len(explanations))
+	}
+	e := explanations[0]
+	if e.Reason != SkipReasonBinary || e.Content == nil || e.Content.Type != "png" {
+		t.Errorf("expected binary png skip got %v %v", e.Reason, e.Content)
+	}
+	if e.Decisions[len(e.Decisions)-1].Pattern != "png" {
+		t.Errorf("expected decision pattern of png got %v", e.Decisions)
+	}
+}
+
+func TestSetContentDetector(t *testing.T) {
+	d := t.TempDir()
+	writeFile(t, filepath.Join(d, "a.txt"), "keep")
+	writeFile(t, filepath.Join(d, "b.txt"), "SECRET")
+
+	fileListQueue := make(chan *File, 10)
+	walker := NewFileWalker(d, fileListQueue)
+	walker.IgnoreBinaryFiles = true
+	walker.SetContentDetector(ContentDetectorFunc(func(head []byte) Content {
+		return Content{Binary: string(head) == "SECRET", Type: "secret"}
+	}))
+	go func() {
+		_ = walker.Start()
+	}()
+
+	var files []string
+	for f := range fileListQueue {
+		files = append(files, f.Filename)
+	}
+	if len(files) != 1 || files[0] != "a.txt" {
+		t.Errorf("expected only a.txt got %v", files)
+	}
+}
+
+func TestShortFilesJudgedOnWhatIsRead(t *testing.T) {
+	d := t.TempDir()
+	writeFile(t, filepath.Join(d, "short.txt"), "hello")
+	writeFile(t, filepath.Join(d, "short.bin"), "hi\x00there")
+
+	for name, detector := range map[string]ContentDetector{"default": DefaultContentDetector{}, "null byte": NullByteDetector} {
+		t.Run(name, func(t *testing.T) {
+			fileListQueue := make(chan *File, 10)
+			walker := NewFileWalker(d, fileListQueue)
+			walker.IgnoreBinaryFiles = true
+			walker.SetContentDetector(detector)
+
+			var mutex sync.Mutex
+			skipped := map[string]SkipReason{}
+			walker.SetSkipHandler(func(path string, name string, isDir bool, reason SkipReason) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				skipped[name] = reason
+			})
+			go func() {
+				_ = walker.Start()
+			}()
+
+			var files []string
+			for f := range fileListQueue {
+				files = append(files, f.Filename)
+			}
+
+			// files shorter than IgnoreBinaryFileBytes are not padded with the null bytes which would make them binary
+			if len(files) != 1 || files[0] != "short.txt" {
+				t.Errorf("expected only short.txt got %v", files)
+			}
+			if skipped["short.bin"] != SkipReasonBinary {
+				t.Errorf("expected short.bin to be skipped as binary got %v", skipped)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// makeContextTree creates a tree wide enough that the walker spawns iteration 0
+// goroutines and deep enough to have more files than any test consumes.
+func makeContextTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	for i := 0; i < 5; i++ {
+		for j := 0; j < 20; j++ {
+			writeFile(t, filepath.Join(root, fmt.Sprintf("dir%d", i), fmt.Sprintf("file%d.go", j)), "")
+		}
+	}
+	return root
+}
+
+func TestStartContextCancelledBeforeStart(t *testing.T) {
+	root := makeContextTree(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	fileListQueue := make(chan *File, 1000)
+	walker := NewFileWalker(root, fileListQueue)
+	err := walker.StartContext(ctx)
+
+	if !errors.Is(err, ErrTerminateWalk) {
+		t.Errorf("expected ErrTerminateWalk got %v", err)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled got %v", err)
+	}
+
+	count := 0
+	for range fileListQueue {
+		count++
+	}
+	if count != 0 {
+		t.Errorf("expected no files got %d", count)
+	}
+}
+
+func TestStartContextCancelUnblocksSend(t *testing.T) {
+	root := makeContextTree(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fileListQueue := make(chan *File) // unbuffered so every send blocks on the consumer
+	walker := NewFileWalker(root, fileListQueue)
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- walker.StartContext(ctx)
+	}()
+
+	// read a single result then stop consuming as a TUI or HTTP handler would
+	<-fileListQueue
+	cancel()
+
+	select {
+	case err := <-errChan:
+		if !errors.Is(err, ErrTerminateWalk) || !errors.Is(err, context.Canceled) {
+			t.Errorf("expected wrapped ErrTerminateWalk and context.Canceled got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("walker did not return after context was cancelled")
+	}
+
+	if walker.Walking() {
+		t.Error("expected walker to have stopped walking")
+	}
+}
+
+func TestStartContextDeadline(t *testing.T) {
+	root := makeContextTree(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	fileListQueue := make(chan *File) // never read from
+	walker := NewParallelFileWalker([]string{root, root}, fileListQueue)
+
+	err := walker.StartContext(ctx)
+	if !errors.Is(err, ErrTerminateWalk) {
+		t.Errorf("expected ErrTerminateWalk got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded got %v", err)
+	}
+}
+
+func TestStartContextCompletes(t *testing.T) {
+	root := makeContextTree(t)
+
+	fileListQueue := make(chan *File, 1000)
+	walker := NewFileWalker(root, fileListQueue)
+	if err := walker.StartContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	count := 0
+	for range fileListQueue {
+		count++
+	}
+	if count != 100 {
+		t.Errorf("expected 100 files got %d", count)
+	}
+}
+
+func TestTerminateUnblocksSend(t *testing.T) {
+	root := makeContextTree(t)
+
+	fileListQueue := make(chan *File)
+	walker := NewFileWalker(root, fileListQueue)
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- walker.Start()
+	}()
+
+	<-fileListQueue
+	walker.Terminate()
+
+	select {
+	case err := <-errChan:
+		if err != ErrTerminateWalk {
+			t.Errorf("expected ErrTerminateWalk got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("walker did not return after Terminate")
+	}
+}
+
+func TestStartContextNoLeakedGoroutines(t *testing.T) {
+	root := makeContextTree(t)
+	if _, err := os.Stat(root); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		fileListQueue := make(chan *File)
+		walker := NewFileWalker(root, fileListQueue)
+
+		done := make(chan struct{})
+		go func() {
+			_ = walker.StartContext(ctx)
+			close(done)
+		}()
+
+		<-fileListQueue
+		cancel()
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("walker did not return after context was cancelled")
+		}
+	}
+}
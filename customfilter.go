@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"io/fs"
+	"strconv"
+)
+
+// FilterDecision is what a FileFilter or DirFilter decided about a path
+type FilterDecision int
+
+const (
+	FilterDefer  FilterDecision = iota // Leave the path as decided by the filters before it
+	FilterAccept                       // Include the path even if a filter before it ignored it
+	FilterReject                       // Ignore the path, which for a directory means nothing below it is walked
+)
+
+// FileFilter is a custom filter for files which is passed the slash separated path, the entry
+// read from the directory and the depth below the root being walked, which is the same as
+// File.Depth. When rejecting it can return the SkipReason reported for the skip, with an empty
+// reason reported as SkipReasonCustomFilter. It is called from every goroutine walking so must
+// be safe for concurrent use.
+type FileFilter func(path string, entry fs.DirEntry, depth int) (FilterDecision, SkipReason)
+
+// DirFilter is a custom filter for directories, the same as FileFilter, where rejecting a
+// directory prunes it so nothing below it is read
+type DirFilter func(path string, entry fs.DirEntry, depth int) (FilterDecision, SkipReason)
+
+// AddFileFilter adds a custom filter run against every file. Custom filters run after the ignore
+// files and the name, regex, hidden, extension and location filters, so can override them, and
+// before the file type, metadata, binary and symlink checks, which can still ignore the file.
+// Filters run in the order added with the last to accept or reject deciding the outcome.
+func (f *FileWalker) AddFileFilter(filter FileFilter) {
+	f.walkMutex.Lock()
+	defer f.walkMutex.Unlock()
+	if filter != nil {
+		f.fileFilters = append(f.fileFilters, filter)
+	}
+}
+
+// AddDirFilter adds a custom filter run against every directory. Custom filters run after the
+// ignore files and the name, regex, hidden and location filters, so can override them, and before
+// the symlink checks. Filters run in the order added with the last to accept or reject deciding.
+func (f *FileWalker) AddDirFilter(filter DirFilter) {
+	f.walkMutex.Lock()
+	defer f.walkMutex.Unlock()
+	if filter != nil {
+		f.dirFilters = append(f.dirFilters, filter)
+	}
+}
+
+// WithFileFilter adds a custom filter run against every file, see AddFileFilter
+func WithFileFilter(filter FileFilter) Option {
+	return func(f *FileWalker) {
+		f.AddFileFilter(filter)
+	}
+}
+
+// WithDirFilter adds a custom filter run against every directory, see AddDirFilter
+func WithDirFilter(filter DirFilter) Option {
+	return func(f *FileWalker) {
+		f.AddDirFilter(filter)
+	}
+}
+
+// applyCustomFilters runs each of the filters against the path in order, recording every
+// decision which was not deferred. The pattern recorded is the index of the filter
+func applyCustomFilters[T ~func(string, fs.DirEntry, int) (FilterDecision, SkipReason)](e *evaluation, filters []T, joined string, entry fs.DirEntry, depth int) {
+	for i, filter := range filters {
+		decision, reason := filter(joined, entry, depth)
+		if reason == "" {
+			reason = SkipReasonCustomFilter
+		}
+
+		switch decision {
+		case FilterAccept:
+			e.decide(false, reason, "filter "+strconv.Itoa(i), "", 0)
+		case FilterReject:
+			e.decide(true, reason, "filter "+strconv.Itoa(i), "", 0)
+		}
+	}
+}
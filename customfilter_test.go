@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"io/fs"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var customFilterFS = mapFS(map[string]string{
+	"main.go":             "package main",
+	"main_test.go":        "package main",
+	"README.md":           "readme",
+	"gen/gen.go":          "package gen",
+	"pkg/pkg.go":          "package pkg",
+	"pkg/deep/deep.go":    "package deep",
+	"vendor/lib/lib.go":   "package lib",
+	"vendor/lib/keep.txt": "keep",
+})
+
+func TestCustomFilters(t *testing.T) {
+	rejectTests := func(path string, entry fs.DirEntry, depth int) (FilterDecision, SkipReason) {
+		if strings.HasSuffix(entry.Name(), "_test.go") {
+			return FilterReject, "test_file"
+		}
+		return FilterDefer, ""
+	}
+
+	cases := []struct {
+		name     string
+		opts     []Option
+		expected []string
+		skipped  map[string]SkipReason
+	}{
+		{
+			"file reject with reason",
+			[]Option{WithFileFilter(rejectTests)},
+			[]string{"README.md", "gen/gen.go", "main.go", "pkg/deep/deep.go", "pkg/pkg.go", "vendor/lib/keep.txt", "vendor/lib/lib.go"},
+			map[string]SkipReason{"main_test.go": "test_file"},
+		},
+		{
+			"file reject by depth without reason",
+			[]Option{WithFileFilter(func(path string, entry fs.DirEntry, depth int) (FilterDecision, SkipReason) {
+				if depth > 1 {
+					return FilterReject, ""
+				}
+				return FilterDefer, ""
+			})},
+			[]string{"README.md", "gen/gen.go", "main.go", "main_test.go", "pkg/pkg.go"},
+			map[string]SkipReason{"pkg/deep/deep.go": SkipReasonCustomFilter, "vendor/lib/lib.go": SkipReasonCustomFilter, "vendor/lib/keep.txt": SkipReasonCustomFilter},
+		},
+		{
+			"dir reject prunes",
+			[]Option{WithDirFilter(func(path string, entry fs.DirEntry, depth int) (FilterDecision, SkipReason) {
+				if entry.Name() == "gen" || path == "pkg/deep" {
+					return FilterReject, "generated"
+				}
+				return FilterDefer, ""
+			})},
+			[]string{"README.md", "main.go", "main_test.go", "pkg/pkg.go", "vendor/lib/keep.txt", "vendor/lib/lib.go"},
+			map[string]SkipReason{"gen": "generated", "pkg/deep": "generated"},
+		},
+		{
+			"accept overrides earlier filters",
+			[]Option{
+				WithExcludeDirectory("vendor"),
+				WithAllowListExtensions("go"),
+				WithDirFilter(func(path string, entry fs.DirEntry, depth int) (FilterDecision, SkipReason) {
+					if entry.Name() == "vendor" {
+						return FilterAccept, ""
+					}
+					return FilterDefer, ""
+				}),
+				WithFileFilter(func(path string, entry fs.DirEntry, depth int) (FilterDecision, SkipReason) {
+					if entry.Name() == "keep.txt" {
+						return FilterAccept, ""
+					}
+					return FilterDefer, ""
+				}),
+			},
+			[]string{"gen/gen.go", "main.go", "main_test.go", "pkg/deep/deep.go", "pkg/pkg.go", "vendor/lib/keep.txt", "vendor/lib/lib.go"},
+			map[string]SkipReason{"README.md": SkipReasonAllowListExtension},
+		},
+		{
+			"last filter decides",
+			[]Option{
+				WithFileFilter(rejectTests),
+				WithFileFilter(func(path string, entry fs.DirEntry, depth int) (FilterDecision, SkipReason) {
+					if path == "main_test.go" {
+						return FilterAccept, ""
+					}
+					return FilterDefer, ""
+				}),
+			},
+			[]string{"README.md", "gen/gen.go", "main.go", "main_test.go", "pkg/deep/deep.go", "pkg/pkg.go", "vendor/lib/keep.txt", "vendor/lib/lib.go"},
+			map[string]SkipReason{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			files, skipped := walkFS(t, customFilterFS, tc.opts...)
+
+			if !slices.Equal(locations(files), tc.expected) {
+				t.Errorf("expected %v got %v", tc.expected, locations(files))
+			}
+			for location, reason := range tc.skipped {
+				if skipped[location] != reason {
+					t.Errorf("expected %s skipped with %s got %q", location, reason, skipped[location])
+				}
+			}
+			if len(skipped) != len(tc.skipped) {
+				t.Errorf("expected %d skips got %v", len(tc.skipped), skipped)
+			}
+		})
+	}
+}
+
+func TestCustomFilterExplain(t *testing.T) {
+	walker := NewFileWalkerFS(customFilterFS, ".", nil, WithDirFilter(func(path string, entry fs.DirEntry, depth int) (FilterDecision, SkipReason) {
+		if path == "pkg" && depth == 0 {
+			return FilterReject, "owned_elsewhere"
+		}
+		return FilterDefer, ""
+	}))
+
+	explanation, err := walker.Explain("pkg/deep/deep.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !explanation.Ignored || explanation.Ancestor == nil || explanation.Reason != "owned_elsewhere" {
+		t.Fatalf("expected ignored through the pkg directory got %v", explanation)
+	}
+	if d := explanation.Ancestor.Decisions; len(d) != 1 || d[0].Pattern != "filter 0" {
+		t.Errorf("expected the decision of the first filter got %v", d)
+	}
+}
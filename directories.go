@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// FileType is what a File returned by the walker refers to
+type FileType string
+
+const (
+	FileTypeFile           FileType = "file"            // A file which passed every file filter
+	FileTypeDirectory      FileType = "directory"       // A directory which passed every directory filter, sent before anything below it when EmitDirectories is set
+	FileTypeLeaveDirectory FileType = "leave_directory" // Sent once everything below a directory has been sent when EmitLeaveDirectories is set
+)
+
+// directoryFile returns the File sent for a directory which passed every directory filter
+func (f *FileWalker) directoryFile(iteration int, root string, directory string, dir fs.DirEntry) *File {
+	return &File{
+		Location: filepath.ToSlash(filepath.Join(directory, dir.Name())),
+		Filename: dir.Name(),
+		Root:     root,
+		Depth:    iteration,
+		DirEntry: dir,
+		Type:     FileTypeDirectory,
+	}
+}
+
+// leaveFile returns the File sent once everything below the directory has been sent
+func leaveFile(dir *File, count int64) *File {
+	return &File{
+		Location:  dir.Location,
+		Filename:  dir.Filename,
+		Root:      dir.Root,
+		Depth:     dir.Depth,
+		DirEntry:  dir.DirEntry,
+		Type:      FileTypeLeaveDirectory,
+		FileCount: count,
+		info:      dir.info,
+	}
+}
+
+// emitDirectories sends a File for each of the directories found in a
+// directory as a single batch when EmitDirectories is set
+func (f *FileWalker) emitDirectories(iteration int, root string, directory string, dirs []fs.DirEntry, emit func(files []*File) error) ([]*File, error) {
+	if !f.cfg.EmitDirectories || len(dirs) == 0 {
+		return nil, nil
+	}
+
+	files := make([]*File, 0, len(dirs))
+	for _, dir := range dirs {
+		files = append(files, f.directoryFile(iteration, root, directory, dir))
+	}
+	return files, emit(files)
+}
+
+// countingEmit returns an emit which passes everything through while counting the files sent
+func countingEmit(count *int64, emit func(files []*File) error) func(files []*File) error {
+	return func(files []*File) error {
+		*count += countType(files, FileTypeFile)
+		return emit(files)
+	}
+}
+
+// countType returns how many of the files are of the supplied type
+func countType(files []*File, fileType FileType) int64 {
+	count := int64(0)
+	for _, file := range files {
+		if file.Type == fileType {
+			count++
+		}
+	}
+	return count
+}
+
+// walkDirState is a directory being walked by the pool whose leave event is sent once
+// it and every directory below it has been walked, only used with EmitLeaveDirectories
+type walkDirState struct {
+	file    *File
+	parent  *walkDirState
+	pending atomic.Int64 // the directory and those below it which have not finished walking
+	files   atomic.Int64 // files sent from the directory and those below it
+}
+
+// newWalkDirState returns the state for a directory found in the parent, which is nil at the root
+func newWalkDirState(file *File, parent *walkDirState) *walkDirState {
+	d := &walkDirState{file: file, parent: parent}
+	d.pending.Store(1)
+	if parent != nil {
+		parent.pending.Add(1)
+	}
+	return d
+}
+
+// finish marks the directory, or one below it, as walked sending the leave event for every
+// directory which is now completely walked from the directory up, adding their files to the parent
+func (d *walkDirState) finish(emit func(files []*File) error) error {
+	for ; d != nil; d = d.parent {
+		if d.pending.Add(-1) != 0 {
+			return nil
+		}
+		files := d.files.Load()
+		if d.parent != nil {
+			d.parent.files.Add(files)
+		}
+		if d.file != nil {
+			if err := emit([]*File{leaveFile(d.file, files)}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// directoryFS is a nested tree with a directory the tests exclude
+var directoryFS = mapFS(map[string]string{
+	"main.go":             "",
+	"pkg/pkg.go":          "",
+	"pkg/util.go":         "",
+	"pkg/sub/sub.go":      "",
+	"pkg/empty/.keep":     "",
+	"vendor/lib/lib.go":   "",
+	"docs/guide/intro.md": "",
+})
+
+func TestEmitDirectories(t *testing.T) {
+	for _, sorted := range []bool{false, true} {
+		t.Run(fmt.Sprintf("sorted %v", sorted), func(t *testing.T) {
+			files, _ := walkFS(t, directoryFS, WithExcludeDirectory("vendor"), WithEmitDirectories(true, false), WithSorted(sorted))
+			var dirs []string
+			for _, f := range files {
+				if f.Type == FileTypeDirectory {
+					dirs = append(dirs, fmt.Sprintf("%s:%d", f.Location, f.Depth))
+				}
+			}
+			slices.Sort(dirs)
+
+			expected := []string{"docs/guide:1", "docs:0", "pkg/empty:1", "pkg/sub:1", "pkg:0"}
+			if !slices.Equal(dirs, expected) {
+				t.Errorf("expected %v got %v", expected, dirs)
+			}
+		})
+	}
+}
+
+func TestEmitDirectoriesOff(t *testing.T) {
+	files, _ := walkFS(t, directoryFS, WithExcludeDirectory("vendor"))
+	for _, f := range files {
+		if f.Type != FileTypeFile {
+			t.Errorf("expected only files got %s %s", f.Type, f.Location)
+		}
+	}
+}
+
+func TestEmitLeaveDirectories(t *testing.T) {
+	for _, sorted := range []bool{false, true} {
+		t.Run(fmt.Sprintf("sorted %v", sorted), func(t *testing.T) {
+			files, _ := walkFS(t, directoryFS, WithExcludeDirectory("vendor"), WithEmitDirectories(true, true), WithSorted(sorted), WithIncludeHidden(true))
+
+			counts := map[string]int64{}
+			entered := map[string]int{}
+			for i, f := range files {
+				switch f.Type {
+				case FileTypeDirectory:
+					entered[f.Location] = i
+				case FileTypeLeaveDirectory:
+					counts[f.Location] = f.FileCount
+					if _, ok := entered[f.Location]; !ok {
+						t.Errorf("expected %s to be entered before it was left", f.Location)
+					}
+					// everything below the directory must be between entering and leaving it
+					for j, below := range files {
+						if strings.HasPrefix(below.Location, f.Location+"/") && (j < entered[f.Location] || j > i) {
+							t.Errorf("expected %s between entering and leaving %s", below.Location, f.Location)
+						}
+					}
+				}
+			}
+
+			expected := map[string]int64{"docs": 1, "docs/guide": 1, "pkg": 4, "pkg/empty": 1, "pkg/sub": 1}
+			if len(counts) != len(expected) {
+				t.Errorf("expected %v got %v", expected, counts)
+			}
+			for location, count := range expected {
+				if counts[location] != count {
+					t.Errorf("expected %s to contain %d files got %d", location, count, counts[location])
+				}
+			}
+		})
+	}
+}
+
+func TestEmitDirectoriesStats(t *testing.T) {
+	walker := NewFileWalkerFS(directoryFS, ".", nil, WithEmitDirectories(true, true))
+	if err := walker.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := walker.Stats()
+	if stats.FilesReturned != 6 || stats.DirectoriesReturned != 7 {
+		t.Errorf("expected 6 files and 7 directories got %d and %d", stats.FilesReturned, stats.DirectoriesReturned)
+	}
+}
+
+func TestEmitLeaveDirectoriesRequiresEmitDirectories(t *testing.T) {
+	walker := NewFileWalkerFS(directoryFS, ".", nil, WithEmitDirectories(false, true))
+	if err := walker.Start(); err == nil {
+		t.Error("expected an error")
+	}
+}
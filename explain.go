@@ -0,0 +1,357 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Decision is a single step taken by the filter pipeline which either ignored
+// or included a path. Steps that did not match the path are not recorded.
+type Decision struct {
+	Reason       SkipReason `json:"reason"`           // The filter which made the decision, named by the SkipReason it reports when ignoring
+	Ignore       bool       `json:"ignore"`           // True if this step ignored the path, false if it included it
+	Pattern      string     `json:"pattern"`          // The pattern, name, extension or regex which matched
+	Source       string     `json:"source,omitempty"` // The ignore file the pattern was read from, empty if it was not read from a file
+	Line         int        `json:"line,omitempty"`   // The line of the pattern within Source, 0 if it was not read from a file
+	OverriddenBy int        `json:"overridden_by"`    // Index into Decisions of the later step which reversed this one, or -1 if it was not overridden
+}
+
+// String returns the decision in a form similar to git check-ignore -v
+func (d Decision) String() string {
+	action := "include"
+	if d.Ignore {
+		action = "ignore"
+	}
+
+	var sb strings.Builder
+	if d.Source != "" {
+		sb.WriteString(d.Source + ":" + strconv.Itoa(d.Line) + ":")
+	}
+	sb.WriteString(d.Pattern + "\t" + string(d.Reason) + " " + action)
+	if d.OverriddenBy != -1 {
+		sb.WriteString(" (overridden by " + strconv.Itoa(d.OverriddenBy) + ")")
+	}
+	return sb.String()
+}
+
+// Explanation is the full decision trace for why a path was ignored or included
+type Explanation struct {
+	Path      string       `json:"path"`
+	IsDir     bool         `json:"is_dir"`
+	Ignored   bool         `json:"ignored"`
+	Reason    SkipReason   `json:"reason,omitempty"`    // The reason the path was skipped when Ignored
+	Decisions []Decision   `json:"decisions,omitempty"` // Every step which matched the path in the order they were applied
+	Consulted []string     `json:"consulted,omitempty"` // Every ignore file which applied to the path in the order they were checked
+	Ancestor  *Explanation `json:"ancestor,omitempty"`  // Set when a parent directory was ignored, and as such the path is never reached
+	Content   *Content     `json:"content,omitempty"`   // What was detected about the content of a file when IgnoreBinaryFiles is set
+}
+
+// String returns a human readable multi-line form of the explanation
+func (e *Explanation) String() string {
+	var sb strings.Builder
+	if e.Ignored {
+		sb.WriteString(fmt.Sprintf("%s ignored (%s)\n", e.Path, e.Reason))
+	} else {
+		sb.WriteString(fmt.Sprintf("%s included\n", e.Path))
+	}
+
+	if e.Ancestor != nil {
+		sb.WriteString("  via ancestor " + strings.ReplaceAll(e.Ancestor.String(), "\n", "\n  "))
+		return strings.TrimRight(sb.String(), " ")
+	}
+
+	for _, c := range e.Consulted {
+		sb.WriteString("  consulted " + c + "\n")
+	}
+	if e.Content != nil {
+		sb.WriteString("  content " + e.Content.String() + "\n")
+	}
+	for i, d := range e.Decisions {
+		sb.WriteString(fmt.Sprintf("  %d: %s\n", i, d.String()))
+	}
+	return sb.String()
+}
+
+// decisionTrace records each decision made by the filter pipeline. A nil
+// trace records nothing so the walk pays no cost unless explaining
+type decisionTrace struct {
+	decisions []Decision
+	content   *Content
+}
+
+// setContent records what was detected about the content of the file
+func (t *decisionTrace) setContent(content *Content) {
+	if t == nil {
+		return
+	}
+	t.content = content
+}
+
+// record adds a decision to the trace, marking any earlier decision
+// with the opposite outcome as overridden by it
+func (t *decisionTrace) record(reason SkipReason, ignore bool, pattern string, source string, line int) {
+	if t == nil {
+		return
+	}
+
+	for i := range t.decisions {
+		if t.decisions[i].OverriddenBy == -1 && t.decisions[i].Ignore != ignore {
+			t.decisions[i].OverriddenBy = len(t.decisions)
+		}
+	}
+
+	t.decisions = append(t.decisions, Decision{
+		Reason:       reason,
+		Ignore:       ignore,
+		Pattern:      pattern,
+		Source:       source,
+		Line:         line,
+		OverriddenBy: -1,
+	})
+}
+
+// explanation converts the trace into an Explanation for the supplied path
+func (t *decisionTrace) explanation(joined string, isDir bool, ignored bool, reason SkipReason, layers ignoreLayers) *Explanation {
+	groups := [][]ignoreFile{layers.gitGlobal, layers.global, layers.gitignores, layers.ignores, layers.custom}
+	if isDir {
+		groups = append(groups, layers.modules)
+	}
+
+	consulted := []string{}
+	for _, group := range groups {
+		for _, ignore := range group {
+			// .gitmodules produce one entry per submodule and custom
+			// ignore patterns have no source so skip both duplicates and blanks
+			if ignore.source != "" && (len(consulted) == 0 || consulted[len(consulted)-1] != ignore.source) {
+				consulted = append(consulted, ignore.source)
+			}
+		}
+	}
+
+	return &Explanation{
+		Path:      joined,
+		IsDir:     isDir,
+		Ignored:   ignored,
+		Reason:    reason,
+		Decisions: t.decisions,
+		Consulted: consulted,
+		Content:   t.content,
+	}
+}
+
+// newDecisionTrace returns a trace to record the filter pipeline into
+// if a skip detail handler is set, otherwise nil
+func (f *FileWalker) newDecisionTrace() *decisionTrace {
+	if f.skipDetailHandler == nil {
+		return nil
+	}
+	return &decisionTrace{}
+}
+
+// skip reports the skipped path to the skip handler, and the skip detail handler if set
+func (f *FileWalker) skip(joined string, name string, isDir bool, reason SkipReason, trace *decisionTrace, layers ignoreLayers) {
+	f.stats.skip(reason)
+	f.skipHandler(joined, name, isDir, reason)
+	if trace != nil {
+		f.skipDetailHandler(trace.explanation(joined, isDir, true, reason, layers))
+	}
+}
+
+// SetSkipDetailHandler sets the function that is called whenever a file or directory is
+// skipped, receiving the full decision trace for the skip as returned by Explain. Recording
+// the trace has a cost so it is only done when this handler is set. It is called after
+// the handler supplied to SetSkipHandler.
+func (f *FileWalker) SetSkipDetailHandler(handler func(explanation *Explanation)) {
+	f.skipDetailHandler = handler
+}
+
+// Explain reports why the supplied path would be ignored or included with the current
+// settings, similar to git check-ignore -v but covering every filter the walker applies.
+// Every directory from the root being walked down to the path is read so that the same
+// ignore files are consulted as when walking. If a parent directory would be ignored
+// the returned Explanation is marked ignored with the parents explanation as Ancestor.
+func (f *FileWalker) Explain(location string) (*Explanation, error) {
+	e, err := f.explainer()
+	if err != nil {
+		return nil, err
+	}
+	return e.explain(location)
+}
+
+// explainer returns a walker to explain with, which reads the same files through the same
+// handlers and filters as f but has its own frozen settings and stats. This allows Explain
+// to be called concurrently, and while walking, without changing the walk or its Stats.
+func (f *FileWalker) explainer() (*FileWalker, error) {
+	f.walkMutex.Lock()
+	defer f.walkMutex.Unlock()
+
+	// while walking the settings the walk is using are explained
+	cfg, include, exclude := f.cfg, f.includeGlobs, f.excludeGlobs
+	if !f.isWalking {
+		var err error
+		cfg, include, exclude, err = f.frozenConfig()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &FileWalker{
+		WalkerConfig:    *cfg,
+		cfg:             cfg,
+		includeGlobs:    include,
+		excludeGlobs:    exclude,
+		sink:            discardSink{},
+		errorsHandler:   f.errorsHandler,
+		skipHandler:     func(path string, name string, isDir bool, reason SkipReason) {},
+		directory:       f.directory,
+		directories:     f.directories,
+		fsys:            f.fsys,
+		osOpen:          f.osOpen,
+		osReadFile:      f.osReadFile,
+		contentDetector: f.contentDetector,
+		fileFilters:     f.fileFilters,
+		dirFilters:      f.dirFilters,
+	}, nil
+}
+
+// explain is Explain on a walker returned by explainer
+func (f *FileWalker) explain(location string) (*Explanation, error) {
+	root, rel, err := f.explainRoot(location)
+	if err != nil {
+		return nil, err
+	}
+	if rel == "." {
+		return &Explanation{Path: filepath.ToSlash(root), IsDir: true}, nil
+	}
+
+	layers, err := f.buildRootLayers(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var ancestors []os.FileInfo
+	directory := root
+	components := strings.Split(rel, "/")
+	for depth, name := range components {
+		last := depth == len(components)-1
+		joined := filepath.ToSlash(filepath.Join(directory, name))
+
+		if maxDepth := layers.config(f).MaxDepth; maxDepth != -1 && depth >= maxDepth {
+			trace := &decisionTrace{}
+			trace.record(SkipReasonMaxDepth, true, strconv.Itoa(maxDepth), "", 0)
+			return trace.explanation(joined, !last, true, SkipReasonMaxDepth, layers), nil
+		}
+
+		foundFiles, err := f.readDir(directory)
+		if err != nil {
+			return nil, err
+		}
+
+		var entry fs.DirEntry
+		files := []fs.DirEntry{}
+		for _, file := range foundFiles {
+			if link := f.resolveSymlinkDir(file, directory); link != nil {
+				file = link
+			}
+			if !file.IsDir() {
+				files = append(files, file)
+			}
+			if file.Name() == name {
+				entry = file
+			}
+		}
+		if entry == nil {
+			return nil, &fs.PathError{Op: "explain", Path: location, Err: fs.ErrNotExist}
+		}
+
+		ancestors = f.ancestorsWith(ancestors, directory)
+		layers, err = f.loadIgnoreFiles(directory, depth, files, layers)
+		if err != nil {
+			return nil, err
+		}
+
+		trace := &decisionTrace{}
+		var ignored bool
+		var reason SkipReason
+		if entry.IsDir() {
+			ignored, reason, err = f.evaluateDir(entry, root, directory, depth, layers, ancestors, trace)
+		} else {
+			ignored, reason, _, err = f.evaluateFile(entry, root, directory, depth, layers, ancestors, trace)
+		}
+		if err != nil {
+			return nil, err
+		}
+		explanation := trace.explanation(joined, entry.IsDir(), ignored, reason, layers)
+
+		if last {
+			return explanation, nil
+		}
+		if ignored {
+			return &Explanation{
+				Path:     filepath.ToSlash(filepath.Join(directory, filepath.FromSlash(strings.Join(components[depth:], "/")))),
+				Ignored:  true,
+				Reason:   reason,
+				Ancestor: explanation,
+			}, nil
+		}
+		if !entry.IsDir() {
+			return nil, &fs.PathError{Op: "explain", Path: location, Err: fs.ErrNotExist}
+		}
+
+		directory = joined
+	}
+
+	return nil, &fs.PathError{Op: "explain", Path: location, Err: fs.ErrNotExist}
+}
+
+// explainRoot finds which of the directories being walked contains the supplied
+// location returning that directory and the slash separated path relative to it
+func (f *FileWalker) explainRoot(location string) (string, string, error) {
+	roots := f.directories
+	if len(roots) == 0 {
+		roots = []string{f.directory}
+	}
+
+	for _, root := range roots {
+		if root == "" {
+			continue
+		}
+
+		if f.fsys != nil {
+			cleanRoot := path.Clean(filepath.ToSlash(root))
+			cleanLocation := path.Clean(filepath.ToSlash(location))
+			switch {
+			case cleanLocation == cleanRoot:
+				return root, ".", nil
+			case cleanRoot == ".":
+				return root, cleanLocation, nil
+			case strings.HasPrefix(cleanLocation, cleanRoot+"/"):
+				return root, cleanLocation[len(cleanRoot)+1:], nil
+			}
+			continue
+		}
+
+		absRoot, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		absLocation, err := filepath.Abs(location)
+		if err != nil {
+			return "", "", err
+		}
+		rel, err := filepath.Rel(absRoot, absLocation)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		return root, filepath.ToSlash(rel), nil
+	}
+
+	return "", "", fmt.Errorf("%s is not inside any directory being walked", location)
+}
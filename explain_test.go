@@ -0,0 +1,255 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func TestExplainGitignoreOverride(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ".gitignore"), "# logs\n*.log\n")
+	writeFile(t, filepath.Join(root, "sub", ".gitignore"), "!keep.log\n")
+	writeFile(t, filepath.Join(root, "sub", "keep.log"), "")
+	writeFile(t, filepath.Join(root, "sub", "drop.log"), "")
+
+	walker := NewFileWalker(root, make(chan *File, 10))
+
+	explanation, err := walker.Explain(filepath.Join(root, "sub", "keep.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if explanation.Ignored {
+		t.Errorf("expected keep.log to be included got %v", explanation)
+	}
+	if len(explanation.Decisions) != 2 {
+		t.Fatalf("expected 2 decisions got %v", explanation.Decisions)
+	}
+
+	first, second := explanation.Decisions[0], explanation.Decisions[1]
+	if !first.Ignore || first.Pattern != "*.log" || first.Line != 2 || first.Reason != SkipReasonGitignore {
+		t.Errorf("unexpected first decision %+v", first)
+	}
+	if first.Source != filepath.ToSlash(filepath.Join(root, ".gitignore")) {
+		t.Errorf("expected first decision from root .gitignore got %s", first.Source)
+	}
+	if first.OverriddenBy != 1 {
+		t.Errorf("expected first decision to be overridden by the second got %d", first.OverriddenBy)
+	}
+	if second.Ignore || second.Pattern != "!keep.log" || second.Line != 1 || second.OverriddenBy != -1 {
+		t.Errorf("unexpected second decision %+v", second)
+	}
+
+	expectedConsulted := []string{
+		filepath.ToSlash(filepath.Join(root, ".gitignore")),
+		filepath.ToSlash(filepath.Join(root, "sub", ".gitignore")),
+	}
+	if !slices.Equal(explanation.Consulted, expectedConsulted) {
+		t.Errorf("expected consulted %v got %v", expectedConsulted, explanation.Consulted)
+	}
+
+	explanation, err = walker.Explain(filepath.Join(root, "sub", "drop.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !explanation.Ignored || explanation.Reason != SkipReasonGitignore {
+		t.Errorf("expected drop.log to be ignored by gitignore got %v", explanation)
+	}
+}
+
+func TestExplainAncestorIgnored(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ".gitignore"), "build/\n")
+	writeFile(t, filepath.Join(root, "build", "out", "main.go"), "")
+
+	walker := NewFileWalker(root, make(chan *File, 10))
+	explanation, err := walker.Explain(filepath.Join(root, "build", "out", "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !explanation.Ignored || explanation.Reason != SkipReasonGitignore {
+		t.Errorf("expected ignored by gitignore got %v", explanation)
+	}
+	if explanation.Ancestor == nil || explanation.Ancestor.Path != filepath.ToSlash(filepath.Join(root, "build")) {
+		t.Fatalf("expected ancestor build got %v", explanation.Ancestor)
+	}
+	if !explanation.Ancestor.IsDir || explanation.Ancestor.Decisions[0].Pattern != "build/" {
+		t.Errorf("unexpected ancestor explanation %v", explanation.Ancestor)
+	}
+}
+
+func TestExplainFilters(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "readme.md"), "")
+	writeFile(t, filepath.Join(root, "main.go"), "")
+	writeFile(t, filepath.Join(root, "vendor", "lib.go"), "")
+	writeFile(t, filepath.Join(root, "a", "b", "deep.go"), "")
+
+	walker := NewFileWalker(root, make(chan *File, 10))
+	walker.AllowListExtensions = []string{"go"}
+	walker.ExcludeDirectory = []string{"vendor"}
+	walker.MaxDepth = 2
+
+	tests := []struct {
+		path    string
+		ignored bool
+		reason  SkipReason
+	}{
+		{"readme.md", true, SkipReasonAllowListExtension},
+		{"main.go", false, ""},
+		{"vendor", true, SkipReasonExcludeDirectory},
+		{"vendor/lib.go", true, SkipReasonExcludeDirectory},
+		{"a/b", false, ""},
+		{"a/b/deep.go", true, SkipReasonMaxDepth},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			explanation, err := walker.Explain(filepath.Join(root, filepath.FromSlash(tt.path)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if explanation.Ignored != tt.ignored || explanation.Reason != tt.reason {
+				t.Errorf("expected ignored=%v reason=%q got %v", tt.ignored, tt.reason, explanation)
+			}
+		})
+	}
+}
+
+func TestExplainErrors(t *testing.T) {
+	root := t.TempDir()
+	walker := NewFileWalker(root, make(chan *File, 10))
+
+	if _, err := walker.Explain(filepath.Join(root, "missing.go")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected not exist error got %v", err)
+	}
+	if _, err := walker.Explain(t.TempDir()); err == nil {
+		t.Error("expected error for path outside of the walked directory")
+	}
+}
+
+func TestExplainLeavesStatsUnchanged(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ".gitignore"), "*.log\n")
+	writeFile(t, filepath.Join(root, "sub", ".ignore"), "*.tmp\n")
+	writeFile(t, filepath.Join(root, "sub", "main.go"), "package main")
+
+	fileListQueue := make(chan *File, 10)
+	walker := NewFileWalker(root, fileListQueue)
+	walker.IgnoreBinaryFiles = true
+	if err := walker.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for range fileListQueue {
+	}
+	before := walker.Stats()
+
+	if _, err := walker.Explain(filepath.Join(root, "sub", "main.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	after := walker.Stats()
+	if after.IgnoreFilesParsed != before.IgnoreFilesParsed || after.BinaryBytesRead != before.BinaryBytesRead {
+		t.Errorf("expected Explain to leave stats unchanged got %+v then %+v", before, after)
+	}
+}
+
+func TestExplainConcurrent(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ".gitignore"), "*.log\n")
+	writeFile(t, filepath.Join(root, "a.log"), "")
+	writeFile(t, filepath.Join(root, "a.go"), "")
+
+	walker := NewFileWalker(root, make(chan *File, 10))
+	walker.ExcludeGlobs = []string{"*.md"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			explanation, err := walker.Explain(filepath.Join(root, "a.log"))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !explanation.Ignored || explanation.Reason != SkipReasonGitignore {
+				t.Errorf("expected a.log to be ignored by .gitignore got %v", explanation)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestExplainFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"repo/.ignore":      {Data: []byte("*.tmp\n")},
+		"repo/src/a.tmp":    {Data: []byte("")},
+		"repo/src/main.go":  {Data: []byte("")},
+		"other/.ignore":     {Data: []byte("*.go\n")},
+		"other/unrelated.x": {Data: []byte("")},
+	}
+
+	walker := NewParallelFileWalker([]string{"other", "repo"}, make(chan *File, 10))
+	walker.fsys = fsys
+
+	explanation, err := walker.Explain("repo/src/a.tmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !explanation.Ignored || explanation.Reason != SkipReasonIgnoreFile || explanation.Decisions[0].Source != "repo/.ignore" {
+		t.Errorf("expected a.tmp ignored by repo/.ignore got %v", explanation)
+	}
+
+	explanation, err = walker.Explain("repo/src/main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if explanation.Ignored {
+		t.Errorf("expected main.go to be included got %v", explanation)
+	}
+}
+
+func TestSkipDetailHandler(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ".gitignore"), "*.log\n")
+	writeFile(t, filepath.Join(root, "debug.log"), "")
+	writeFile(t, filepath.Join(root, "main.go"), "")
+
+	fileListQueue := make(chan *File, 10)
+	walker := NewFileWalker(root, fileListQueue)
+	walker.IncludeHidden = true
+
+	details := map[string]*Explanation{}
+	walker.SetSkipDetailHandler(func(explanation *Explanation) {
+		details[explanation.Path] = explanation
+	})
+	if err := walker.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for range fileListQueue {
+	}
+
+	if len(details) != 1 {
+		t.Fatalf("expected a single skip got %v", details)
+	}
+	detail := details[filepath.ToSlash(filepath.Join(root, "debug.log"))]
+	if detail == nil || detail.Reason != SkipReasonGitignore || detail.Decisions[0].Pattern != "*.log" || detail.Decisions[0].Line != 1 {
+		t.Errorf("unexpected skip detail %v", detail)
+	}
+
+	explanation, err := walker.Explain(filepath.Join(root, "debug.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if explanation.String() != detail.String() {
+		t.Errorf("expected Explain to match the skip detail\n%s\n%s", explanation, detail)
+	}
+}
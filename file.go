@@ -7,20 +7,16 @@
 package gocodewalker
 
 import (
-	"bytes"
+	"context"
 	"errors"
-	"io"
+	"fmt"
 	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
-	"regexp"
-	"slices"
 	"strings"
 	"sync"
-
-	"github.com/boyter/gocodewalker/go-gitignore"
-	"golang.org/x/sync/errgroup"
+	"time"
 )
 
 const (
@@ -41,6 +37,7 @@ const (
 	SkipReasonIgnoreFile             SkipReason = "ignore_file"
 	SkipReasonCustomIgnore           SkipReason = "custom_ignore"
 	SkipReasonGlobalIgnore           SkipReason = "global_ignore"
+	SkipReasonGitGlobalIgnore        SkipReason = "git_global_ignore"
 	SkipReasonModuleIgnore           SkipReason = "module_ignore"
 	SkipReasonIncludeFilename        SkipReason = "include_filename"
 	SkipReasonExcludeFilename        SkipReason = "exclude_filename"
@@ -55,140 +52,127 @@ const (
 	SkipReasonExcludeDirectory       SkipReason = "exclude_directory"
 	SkipReasonIncludeDirectoryRegex  SkipReason = "include_directory_regex"
 	SkipReasonExcludeDirectoryRegex  SkipReason = "exclude_directory_regex"
+	SkipReasonSymlinkLoop            SkipReason = "symlink_loop"
+	SkipReasonSymlinkEscape          SkipReason = "symlink_escape"
+	SkipReasonMaxDepth               SkipReason = "max_depth" // Only reported by Explain as the walker never reads past MaxDepth
+	SkipReasonRegularFile            SkipReason = "regular_file"
+	SkipReasonSymlink                SkipReason = "symlink"
+	SkipReasonFIFO                   SkipReason = "fifo"
+	SkipReasonSocket                 SkipReason = "socket"
+	SkipReasonDevice                 SkipReason = "device"
+	SkipReasonIrregular              SkipReason = "irregular"
+	SkipReasonMinSize                SkipReason = "min_size"
+	SkipReasonMaxSize                SkipReason = "max_size"
+	SkipReasonModifiedAfter          SkipReason = "modified_after"
+	SkipReasonModifiedBefore         SkipReason = "modified_before"
+	SkipReasonIncludePermissions     SkipReason = "include_permissions"
+	SkipReasonExcludePermissions     SkipReason = "exclude_permissions"
+	SkipReasonOwner                  SkipReason = "owner"
+	SkipReasonIncludeGlob            SkipReason = "include_glob"
+	SkipReasonIncludeLanguage        SkipReason = "include_language"
+	SkipReasonExcludeGlob            SkipReason = "exclude_glob"
+	SkipReasonCustomFilter           SkipReason = "custom_filter" // Reported when a FileFilter or DirFilter rejects without a reason of its own
 )
 
 // File is a struct returned which contains the location and the filename of the file that passed all exclusion rules
 type File struct {
 	Location string
 	Filename string
+	Root     string      // The directory being walked when this file was found, which with NewParallelFileWalker identifies which of the directories it came from
+	Depth    int         // How many directories below Root the file is, where 0 is directly inside Root
+	DirEntry fs.DirEntry // The entry read from the directory while walking, nil if the File was not produced by a walker
+	Content  *Content    // What was detected about the content when IgnoreBinaryFiles is set, otherwise nil
+	Type     FileType    // What the File refers to, which is only ever a directory when EmitDirectories is set
+	Language string      // The language detected when DetectLanguages or IncludeLanguages is set, empty if it could not be
+	// FileCount is the number of files sent from the directory and every directory below it,
+	// only set when Type is FileTypeLeaveDirectory
+	FileCount int64
+	info      fs.FileInfo
 }
 
 var semaphoreCount = 8
 
 type FileWalker struct {
-	fileListQueue          chan<- *File
-	errorsHandler          func(error) bool // If returns true will continue to process where possible, otherwise returns if possible
-	skipHandler            func(path string, name string, isDir bool, reason SkipReason)
-	directory              string
-	directories            []string
-	LocationExcludePattern []string // Case-sensitive patterns which exclude directory/file matches
-	IncludeDirectory       []string
-	ExcludeDirectory       []string // Paths to always ignore such as .git,.svn and .hg
-	IncludeFilename        []string
-	ExcludeFilename        []string
-	IncludeDirectoryRegex  []*regexp.Regexp // Must match regex as logical OR IE can match any of them
-	ExcludeDirectoryRegex  []*regexp.Regexp
-	IncludeFilenameRegex   []*regexp.Regexp
-	ExcludeFilenameRegex   []*regexp.Regexp
-	AllowListExtensions    []string // Which extensions should be allowed case sensitive
-	ExcludeListExtensions  []string // Which extensions should be excluded case sensitive
-	walkMutex              sync.Mutex
-	terminateWalking       bool
-	isWalking              bool
-	IgnoreIgnoreFile       bool     // Should .ignore files be respected?
-	IgnoreGitIgnore        bool     // Should .gitignore files be respected?
-	IgnoreGitModules       bool     // Should .gitmodules files be respected?
-	CustomIgnore           []string // Custom ignore filenames discovered while walking
-	CustomIgnorePatterns   []string // Custom ignore patterns re-anchored at every directory
-	CustomIgnoreFiles      []string // Paths to ignore files read once and anchored at the walk root (lowest priority; any discovered ignore file overrides them)
-	IncludeHidden          bool     // Should hidden files and directories be included/walked
-	osOpen                 func(name string) (*os.File, error)
-	osReadFile             func(name string) ([]byte, error)
-	countingSemaphore      chan bool
-	semaphoreCount         int
-	MaxDepth               int
-	IgnoreBinaryFiles      bool // Should we open the file and try to determine if it is binary?
-	IgnoreBinaryFileBytes  int  // How many bytes should be used
+	WalkerConfig
+	cfg               *WalkerConfig // copy of WalkerConfig taken when walking starts which is what the walk reads
+	includeGlobs      []*glob       // IncludeGlobs compiled when walking starts
+	excludeGlobs      []*glob       // ExcludeGlobs compiled when walking starts
+	sink              Sink
+	errorsHandler     func(error) bool // If returns true will continue to process where possible, otherwise returns if possible
+	skipHandler       func(path string, name string, isDir bool, reason SkipReason)
+	skipDetailHandler func(explanation *Explanation)
+	directory         string
+	directories       []string
+	walkMutex         sync.Mutex
+	terminateWalking  bool
+	isWalking         bool
+	ctx               context.Context    // set by StartContext and observed by every goroutine while walking
+	cancel            context.CancelFunc // cancels ctx, called by Terminate so blocked sends are released
+	fsys              fs.FS              // When set all reads go through this rather than the operating system
+	osOpen            func(name string) (*os.File, error)
+	osReadFile        func(name string) ([]byte, error)
+	countingSemaphore chan bool
+	contentDetector   ContentDetector
+	compare           func(a fs.DirEntry, b fs.DirEntry) int
+	snapshot          *snapshotState
+	changeQueue       chan<- *Change
+	stats             walkStats
+	progressHandler   func(stats Stats)
+	fileFilters       []FileFilter
+	dirFilters        []DirFilter
 }
 
 // NewFileWalker constructs a filewalker, which will walk the supplied directory
 // and output File results to the supplied queue as it finds them
-func NewFileWalker(directory string, fileListQueue chan<- *File) *FileWalker {
-	return &FileWalker{
-		fileListQueue:          fileListQueue,
-		errorsHandler:          func(e error) bool { return true }, // a generic one that just swallows everything
-		skipHandler:            func(path string, name string, isDir bool, reason SkipReason) {},
-		directory:              directory,
-		LocationExcludePattern: nil,
-		IncludeDirectory:       nil,
-		ExcludeDirectory:       nil,
-		IncludeFilename:        nil,
-		ExcludeFilename:        nil,
-		IncludeDirectoryRegex:  nil,
-		ExcludeDirectoryRegex:  nil,
-		IncludeFilenameRegex:   nil,
-		ExcludeFilenameRegex:   nil,
-		AllowListExtensions:    nil,
-		ExcludeListExtensions:  nil,
-		walkMutex:              sync.Mutex{},
-		terminateWalking:       false,
-		isWalking:              false,
-		IgnoreIgnoreFile:       false,
-		IgnoreGitIgnore:        false,
-		CustomIgnore:           []string{},
-		CustomIgnorePatterns:   []string{},
-		CustomIgnoreFiles:      []string{},
-		IgnoreGitModules:       false,
-		IncludeHidden:          false,
-		osOpen:                 os.Open,
-		osReadFile:             os.ReadFile,
-		countingSemaphore:      make(chan bool, semaphoreCount),
-		semaphoreCount:         semaphoreCount,
-		MaxDepth:               -1,
-		IgnoreBinaryFiles:      false,
-		IgnoreBinaryFileBytes:  IgnoreBinaryFileBytes,
+func NewFileWalker(directory string, fileListQueue chan<- *File, opts ...Option) *FileWalker {
+	f := newFileWalker(fileListQueue)
+	f.directory = directory
+	for _, opt := range opts {
+		opt(f)
 	}
+	return f
 }
 
 // NewParallelFileWalker constructs a filewalker, which will walk the supplied directories in parallel
 // and output File results to the supplied queue as it finds them
-func NewParallelFileWalker(directories []string, fileListQueue chan<- *File) *FileWalker {
+func NewParallelFileWalker(directories []string, fileListQueue chan<- *File, opts ...Option) *FileWalker {
+	f := newFileWalker(fileListQueue)
+	f.directories = directories
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// newFileWalker constructs a filewalker with the default settings and nothing to walk,
+// sending to the fileListQueue if supplied otherwise discarding everything found
+func newFileWalker(fileListQueue chan<- *File) *FileWalker {
+	var sink Sink = discardSink{}
+	if fileListQueue != nil {
+		sink = ChanSink(fileListQueue)
+	}
+
 	return &FileWalker{
-		fileListQueue:          fileListQueue,
-		errorsHandler:          func(e error) bool { return true }, // a generic one that just swallows everything
-		skipHandler:            func(path string, name string, isDir bool, reason SkipReason) {},
-		directories:            directories,
-		LocationExcludePattern: nil,
-		IncludeDirectory:       nil,
-		ExcludeDirectory:       nil,
-		IncludeFilename:        nil,
-		ExcludeFilename:        nil,
-		IncludeDirectoryRegex:  nil,
-		ExcludeDirectoryRegex:  nil,
-		IncludeFilenameRegex:   nil,
-		ExcludeFilenameRegex:   nil,
-		AllowListExtensions:    nil,
-		ExcludeListExtensions:  nil,
-		walkMutex:              sync.Mutex{},
-		terminateWalking:       false,
-		isWalking:              false,
-		IgnoreIgnoreFile:       false,
-		IgnoreGitIgnore:        false,
-		CustomIgnore:           []string{},
-		CustomIgnorePatterns:   []string{},
-		CustomIgnoreFiles:      []string{},
-		IgnoreGitModules:       false,
-		IncludeHidden:          false,
-		osOpen:                 os.Open,
-		osReadFile:             os.ReadFile,
-		countingSemaphore:      make(chan bool, semaphoreCount),
-		semaphoreCount:         semaphoreCount,
-		MaxDepth:               -1,
-		IgnoreBinaryFiles:      false,
-		IgnoreBinaryFileBytes:  IgnoreBinaryFileBytes,
+		WalkerConfig:      DefaultConfig(),
+		sink:              sink,
+		errorsHandler:     func(e error) bool { return true }, // a generic one that just swallows everything
+		skipHandler:       func(path string, name string, isDir bool, reason SkipReason) {},
+		osOpen:            os.Open,
+		osReadFile:        os.ReadFile,
+		countingSemaphore: make(chan bool, semaphoreCount),
 	}
 }
 
 // SetConcurrency sets the concurrency when walking
 // which controls the number of goroutines that
-// walk directories concurrently
+// walk directories concurrently at any depth
 // by default it is set to 8
 // must be a whole integer greater than 0
 func (f *FileWalker) SetConcurrency(i int) {
 	f.walkMutex.Lock()
 	defer f.walkMutex.Unlock()
 	if i >= 1 {
-		f.semaphoreCount = i
+		f.Concurrency = i
 	}
 }
 
@@ -208,11 +192,16 @@ func (f *FileWalker) Terminate() {
 	f.walkMutex.Lock()
 	defer f.walkMutex.Unlock()
 	f.terminateWalking = true
+	if f.cancel != nil {
+		f.cancel()
+	}
 }
 
 // SetErrorHandler sets the function that is called on processing any error
 // where if you return true it will attempt to continue processing, and if false
-// will return the error instantly
+// will return the error instantly. This is the case wherever the error happens, so
+// returning false for a directory deep in the tree which cannot be read stops the
+// whole walk, not just that directory, and the error is returned from Start.
 func (f *FileWalker) SetErrorHandler(errors func(error) bool) {
 	if errors != nil {
 		f.errorsHandler = errors
@@ -233,46 +222,101 @@ func (f *FileWalker) SetSkipHandler(handler func(path string, name string, isDir
 // Returns usual ioutil errors if there is a file issue
 // and a ErrTerminateWalk if terminate is called while walking
 func (f *FileWalker) Start() error {
+	return f.StartContext(context.Background())
+}
+
+// StartContext is the same as Start but will stop walking when the supplied
+// context is cancelled or its deadline passes. Cancellation also releases any
+// goroutine blocked sending to the fileListQueue or Sink, so a consumer that
+// stops reading does not leak walker goroutines. When stopped by the context the
+// returned error wraps both ErrTerminateWalk and ctx.Err()
+func (f *FileWalker) StartContext(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	f.walkMutex.Lock()
 	f.isWalking = true
+	f.ctx = ctx
+	f.cancel = cancel
+	err := f.freezeConfig()
+	finishStats := f.startStats()
 	f.walkMutex.Unlock()
 
-	// we now set the counting semaphore based on the count
-	// done here because it should not change while walking
-	f.countingSemaphore = make(chan bool, semaphoreCount)
+	if err == nil {
+		// we now set the counting semaphore based on the count
+		// done here because it should not change while walking
+		f.countingSemaphore = make(chan bool, f.concurrency())
+		f.snapshot, err = f.loadSnapshot()
+	}
+	if err == nil {
+		err = f.walkRoots()
+	}
+
+	f.sink.Close()
 
-	var err error
+	err = f.finishSnapshot(err)
+	finishStats()
+
+	f.walkMutex.Lock()
+	f.isWalking = false
+	f.cancel = nil
+	f.walkMutex.Unlock()
+
+	return err
+}
+
+// walkRoots walks the directory, or each of the directories in parallel
+func (f *FileWalker) walkRoots() error {
 	if len(f.directories) != 0 {
-		eg := errgroup.Group{}
-		for _, directory := range f.directories {
-			d := directory // capture var
-			eg.Go(func() error {
-				globalIgnores, gerr := f.buildGlobalIgnores(d)
-				if gerr != nil {
-					return gerr
-				}
-				return f.walkDirectoryRecursive(0, d, globalIgnores, []gitignore.GitIgnore{}, []gitignore.GitIgnore{}, []gitignore.GitIgnore{}, []gitignore.GitIgnore{})
-			})
+		if f.cfg.Sorted {
+			return f.walkRootsSorted()
 		}
+		return f.walkRootsPooled(f.directories, f.emit)
+	}
 
-		err = eg.Wait()
-	} else {
-		if f.directory != "" {
-			var globalIgnores []gitignore.GitIgnore
-			globalIgnores, err = f.buildGlobalIgnores(f.directory)
-			if err == nil {
-				err = f.walkDirectoryRecursive(0, f.directory, globalIgnores, []gitignore.GitIgnore{}, []gitignore.GitIgnore{}, []gitignore.GitIgnore{}, []gitignore.GitIgnore{})
-			}
-		}
+	if f.directory == "" {
+		return nil
 	}
 
-	close(f.fileListQueue)
+	if f.cfg.Sorted {
+		return f.walkRoot(f.directory, f.emit)
+	}
+	return f.walkRootsPooled([]string{f.directory}, f.emit)
+}
+
+// walkRoot walks a single directory from the top in sorted order, recording how long it took
+func (f *FileWalker) walkRoot(directory string, emit func(files []*File) error) error {
+	defer f.stats.root(directory, time.Now())
+
+	layers, err := f.buildRootLayers(directory)
+	if err != nil {
+		return err
+	}
+	return f.walkDirectoryRecursive(0, directory, directory, layers, nil, emit)
+}
+
+// concurrency returns how many directories can be walked at once
+func (f *FileWalker) concurrency() int {
+	if f.cfg.Concurrency < 1 {
+		return semaphoreCount
+	}
+	return f.cfg.Concurrency
+}
 
+// interrupted returns the error which should be returned if walking has been
+// terminated or the context cancelled, otherwise it returns nil
+func (f *FileWalker) interrupted() error {
 	f.walkMutex.Lock()
-	f.isWalking = false
+	terminated := f.terminateWalking
 	f.walkMutex.Unlock()
 
-	return err
+	if terminated {
+		return ErrTerminateWalk
+	}
+	if err := f.ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrTerminateWalk, err)
+	}
+	return nil
 }
 
 // buildGlobalIgnores reads each path in CustomIgnoreFiles, parses it as gitignore
@@ -283,22 +327,22 @@ func (f *FileWalker) Start() error {
 // ignores, meaning any ignore file discovered while walking overrides them.
 // Missing or unreadable files are passed through errorsHandler and skipped when it
 // returns true, consistent with how the other ignore file reads behave.
-func (f *FileWalker) buildGlobalIgnores(directory string) ([]gitignore.GitIgnore, error) {
-	if len(f.CustomIgnoreFiles) == 0 {
-		return []gitignore.GitIgnore{}, nil
+func (f *FileWalker) buildGlobalIgnores(directory string) ([]ignoreFile, error) {
+	if len(f.cfg.CustomIgnoreFiles) == 0 {
+		return []ignoreFile{}, nil
 	}
 
-	abs, err := filepath.Abs(directory)
+	abs, err := f.absDir(directory)
 	if err != nil {
 		if f.errorsHandler(err) {
-			return []gitignore.GitIgnore{}, nil
+			return []ignoreFile{}, nil
 		}
 		return nil, err
 	}
 
-	globalIgnores := []gitignore.GitIgnore{}
-	for _, ignoreFile := range f.CustomIgnoreFiles {
-		c, err := f.osReadFile(ignoreFile)
+	globalIgnores := []ignoreFile{}
+	for _, location := range f.cfg.CustomIgnoreFiles {
+		c, err := f.readFile(location)
 		if err != nil {
 			if f.errorsHandler(err) {
 				continue // if asked to ignore it lets continue
@@ -306,65 +350,154 @@ func (f *FileWalker) buildGlobalIgnores(directory string) ([]gitignore.GitIgnore
 			return nil, err
 		}
 
-		gitIgnore := gitignore.New(bytes.NewReader(c), filepath.ToSlash(abs), nil)
-		globalIgnores = append(globalIgnores, gitIgnore)
+		globalIgnores = append(globalIgnores, f.newIgnoreFile(c, filepath.ToSlash(abs), location))
 	}
 
 	return globalIgnores, nil
 }
 
+// buildGitGlobalIgnores loads the users global git excludes file when RespectGlobalGitIgnore
+// is set, locating it through core.excludesFile in their git config the same way git does.
+// As git does, patterns are anchored at the root of the repository containing the supplied
+// directory. A missing excludes file is not an error because git silently ignores it too.
+func (f *FileWalker) buildGitGlobalIgnores(directory string) []ignoreFile {
+	if !f.cfg.RespectGlobalGitIgnore || f.cfg.IgnoreGitIgnore || f.fsys != nil {
+		return []ignoreFile{}
+	}
+
+	repoRoot, err := filepath.Abs(FindRepositoryRoot(directory))
+	if err != nil {
+		return []ignoreFile{}
+	}
+
+	excludesFile := findGitExcludesFile(repoRoot)
+	if excludesFile == "" {
+		return []ignoreFile{}
+	}
+
+	c, err := os.ReadFile(excludesFile)
+	if err != nil {
+		return []ignoreFile{}
+	}
+	f.stats.ignoreFilesParsed.Add(1)
+
+	return []ignoreFile{f.newIgnoreFile(c, filepath.ToSlash(repoRoot), excludesFile)}
+}
+
+// buildRootLayers builds the ignore layers which apply at the root of a walk
+func (f *FileWalker) buildRootLayers(directory string) (ignoreLayers, error) {
+	globalIgnores, err := f.buildGlobalIgnores(directory)
+	if err != nil {
+		return ignoreLayers{}, err
+	}
+
+	return f.buildProjectLayers(directory, ignoreLayers{
+		gitGlobal: f.buildGitGlobalIgnores(directory),
+		global:    globalIgnores,
+	})
+}
+
+// walkDirectoryRecursive walks the directory and everything below it in sorted order. Only the
+// directories at the root are walked in parallel, as everything below them needs to be sent in order
 func (f *FileWalker) walkDirectoryRecursive(iteration int,
+	root string,
 	directory string,
-	globalIgnores []gitignore.GitIgnore,
-	gitignores []gitignore.GitIgnore,
-	ignores []gitignore.GitIgnore,
-	moduleIgnores []gitignore.GitIgnore,
-	customIgnores []gitignore.GitIgnore) error {
+	layers ignoreLayers,
+	ancestors []os.FileInfo,
+	emit func(files []*File) error) error {
 
 	// implement max depth option
-	if f.MaxDepth != -1 && iteration >= f.MaxDepth {
+	if maxDepth := layers.config(f).MaxDepth; maxDepth != -1 && iteration >= maxDepth {
 		return nil
 	}
 
 	if iteration == 1 {
-		f.countingSemaphore <- true
+		select {
+		case f.countingSemaphore <- true:
+		case <-f.ctx.Done():
+			return f.interrupted()
+		}
 		defer func() {
 			<-f.countingSemaphore
 		}()
 	}
 
-	// NB have to call unlock not using defer because method is recursive
-	// and will deadlock if not done manually
-	f.walkMutex.Lock()
-	if f.terminateWalking {
-		f.walkMutex.Unlock()
-		return ErrTerminateWalk
+	// files need to be put in order along with the directories so are not sent while reading
+	scan, err := f.readDirectory(iteration, root, directory, layers, ancestors, nil)
+	if scan == nil || err != nil {
+		return err
 	}
-	f.walkMutex.Unlock()
 
-	d, err := f.osOpen(directory)
-	if err != nil {
-		// nothing we can do with this so return nil and process as best we can
-		if f.errorsHandler(err) {
-			return nil
-		}
+	if err := f.walkSorted(iteration, root, directory, scan, emit); err != nil {
 		return err
 	}
-	defer func(d *os.File) {
-		err := d.Close()
+
+	return f.recordDirectory(directory, scan.record)
+}
+
+// readDirectory returns the directory read and filtered, passing the files which should be returned
+// to emit in batches as they are found if not nil. Returns nil if the directory could not be read and the
+// errorsHandler asked to continue.
+func (f *FileWalker) readDirectory(iteration int,
+	root string,
+	directory string,
+	layers ignoreLayers,
+	ancestors []os.FileInfo,
+	emit func(files []*File) error) (*directoryScan, error) {
+
+	if err := f.interrupted(); err != nil {
+		return nil, err
+	}
+
+	// when recording a snapshot a directory which is unchanged since the
+	// previous walk is not read, returning what was found last time instead
+	dirInfo, cached := f.cachedDirectory(directory)
+	if cached != nil {
+		scan, err := f.cachedScan(iteration, root, directory, layers, ancestors, cached)
 		if err != nil {
-			f.errorsHandler(err)
+			return nil, err
+		}
+		if scan != nil {
+			f.stats.directoriesCached.Add(1)
+			return scan, nil
 		}
-	}(d)
+	}
 
-	foundFiles, err := d.ReadDir(-1)
+	return f.scanDirectory(iteration, root, directory, layers, ancestors, dirInfo, emit)
+}
+
+// directoryScan is a directory which has been read and had the filter pipeline run against everything in it
+type directoryScan struct {
+	files     []*File            // The files which should be returned, if not already sent while scanning
+	returned  int64              // How many files should be returned, including any already sent
+	dirs      []fs.DirEntry      // The directories which should be walked
+	layers    ignoreLayers       // The ignore layers which apply to the directories
+	ancestors []os.FileInfo      // The ancestors of the directories
+	record    *snapshotDirectory // What was found, only set when dirInfo is supplied
+}
+
+// scanDirectory reads the directory and runs the filter pipeline against everything in it, passing
+// the files which should be returned to emit in batches as it goes. Returns nil if the directory could not be
+// read and the errorsHandler asked to continue. If emit is nil the files are added to the scan
+// instead. When dirInfo is supplied a record of everything found is built, which is used to
+// compare against later walks
+func (f *FileWalker) scanDirectory(iteration int,
+	root string,
+	directory string,
+	layers ignoreLayers,
+	ancestors []os.FileInfo,
+	dirInfo fs.FileInfo,
+	emit func(files []*File) error) (*directoryScan, error) {
+
+	foundFiles, err := f.readDir(directory)
 	if err != nil {
 		// nothing we can do with this so return nil and process as best we can
 		if f.errorsHandler(err) {
-			return nil
+			return nil, nil
 		}
-		return err
+		return nil, err
 	}
+	f.stats.directoriesRead.Add(1)
 
 	files := []fs.DirEntry{}
 	dirs := []fs.DirEntry{}
@@ -375,499 +508,139 @@ func (f *FileWalker) walkDirectoryRecursive(iteration int,
 	for _, file := range foundFiles {
 		if file.IsDir() {
 			dirs = append(dirs, file)
+		} else if link := f.resolveSymlinkDir(file, directory); link != nil {
+			dirs = append(dirs, link)
 		} else {
 			files = append(files, file)
 		}
 	}
 
-	// Pull out all ignore, gitignore and gitmodule files and add them
-	// to out collection of gitignores to be applied for this pass
-	// and any subdirectories
-	// Since they can apply to the current list of files we need to ensure
-	// we do this before processing files themselves
-	for _, file := range files {
-		if !f.IgnoreGitIgnore {
-			if file.Name() == GitIgnore {
-				c, err := f.osReadFile(filepath.Join(directory, file.Name()))
-				if err != nil {
-					if f.errorsHandler(err) {
-						continue // if asked to ignore it lets continue
-					}
-					return err
-				}
-
-				abs, err := filepath.Abs(directory)
-				if err != nil {
-					if f.errorsHandler(err) {
-						continue // if asked to ignore it lets continue
-					}
-					return err
-				}
-
-				gitIgnore := gitignore.New(bytes.NewReader(c), filepath.ToSlash(abs), nil)
-				gitignores = append(gitignores, gitIgnore)
-			}
-		}
-
-		if !f.IgnoreIgnoreFile {
-			if file.Name() == Ignore {
-				c, err := f.osReadFile(filepath.Join(directory, file.Name()))
-				if err != nil {
-					if f.errorsHandler(err) {
-						continue // if asked to ignore it lets continue
-					}
-					return err
-				}
-
-				abs, err := filepath.Abs(directory)
-				if err != nil {
-					if f.errorsHandler(err) {
-						continue // if asked to ignore it lets continue
-					}
-					return err
-				}
-
-				gitIgnore := gitignore.New(bytes.NewReader(c), abs, nil)
-				ignores = append(ignores, gitIgnore)
-			}
-		}
-
-		// this should only happen on the first iteration
-		// because there should be one .gitmodules file per repository
-		// however we also need to support someone running in a directory of
-		// projects that have multiple repositories or in a go vendor
-		// repository etc... hence check every time
-		if !f.IgnoreGitModules {
-			if file.Name() == GitModules {
-				// now we need to open and parse the file
-				c, err := f.osReadFile(filepath.Join(directory, file.Name()))
-				if err != nil {
-					if f.errorsHandler(err) {
-						continue // if asked to ignore it lets continue
-					}
-					return err
-				}
-
-				abs, err := filepath.Abs(directory)
-				if err != nil {
-					if f.errorsHandler(err) {
-						continue // if asked to ignore it lets continue
-					}
-					return err
-				}
-
-				for _, gm := range extractGitModuleFolders(string(c)) {
-					gitIgnore := gitignore.New(strings.NewReader(gm), abs, nil)
-					moduleIgnores = append(moduleIgnores, gitIgnore)
-				}
-			}
-		}
-
-		for _, ci := range f.CustomIgnore {
-			if file.Name() == ci {
-				c, err := f.osReadFile(filepath.Join(directory, file.Name()))
-				if err != nil {
-					if f.errorsHandler(err) {
-						continue // if asked to ignore it lets continue
-					}
-					return err
-				}
-
-				abs, err := filepath.Abs(directory)
-				if err != nil {
-					if f.errorsHandler(err) {
-						continue // if asked to ignore it lets continue
-					}
-					return err
-				}
+	// when following symlinks we need to know every directory above us
+	// so that links pointing back at one of them are not walked forever
+	ancestors = f.ancestorsWith(ancestors, directory)
 
-				gitIgnore := gitignore.New(bytes.NewReader(c), abs, nil)
-				customIgnores = append(customIgnores, gitIgnore)
-			}
-		}
+	// Since ignore files can apply to the current list of files we need
+	// to ensure we load them before processing files themselves
+	layers, err = f.loadIgnoreFiles(directory, iteration, files, layers)
+	if err != nil {
+		return nil, err
 	}
-	if !f.IgnoreGitIgnore {
-		gitdir := os.Getenv("GIT_DIR")
-		if gitdir == "" {
-			gitdir = filepath.Join(directory, ".git")
+
+	var record *snapshotDirectory
+	if dirInfo != nil {
+		record = &snapshotDirectory{
+			ModTime:    dirInfo.ModTime(),
+			IgnoreHash: layers.hash(),
 		}
-		file := filepath.Join(gitdir, "info", "exclude")
-		if content, err := os.ReadFile(file); err == nil {
-			abs, err := filepath.Abs(directory)
-			if err == nil {
-				gitExclude := gitignore.New(bytes.NewReader(content), abs, nil)
-				if gitExclude != nil {
-					gitignores = append(gitignores, gitExclude)
-				}
+		for _, file := range files {
+			if f.isIgnoreFileName(file.Name()) {
+				record.IgnoreFiles = append(record.IgnoreFiles, file.Name())
 			}
 		}
 	}
 
-	// If we have custom ignore patterns defined we should concatenate them and treat them as a single gitignore file
-	if len(f.CustomIgnorePatterns) > 0 {
-		customIgnorePatternsCombined := strings.Join(f.CustomIgnorePatterns, "\n")
-
-		abs, err := filepath.Abs(directory)
-		if err != nil {
-			if !f.errorsHandler(err) {
-				return err
-			}
-		}
-
-		gitIgnore := gitignore.New(bytes.NewReader([]byte(customIgnorePatternsCombined)), abs, nil)
-		customIgnores = append(customIgnores, gitIgnore)
+	scan := &directoryScan{
+		layers:    layers,
+		ancestors: ancestors,
+		record:    record,
 	}
 
-	// Process files first to start feeding whatever process is consuming
-	// the output before traversing into directories for more files
+	var batch []*File
 	for _, file := range files {
-		shouldIgnore := false
-		var skipReason SkipReason
 		joined := filepath.ToSlash(filepath.Join(directory, file.Name()))
 
-		// Global ignore files supplied by path are the lowest priority, so they
-		// are checked first and anything discovered while walking can override them
-		for _, ignore := range globalIgnores {
-			if m := ignore.MatchIsDir(joined, false); m != nil {
-				shouldIgnore = m.Ignore()
-				if shouldIgnore {
-					skipReason = SkipReasonGlobalIgnore
-				} else {
-					skipReason = ""
-				}
-			}
-		}
-
-		for _, ignore := range gitignores {
-			// we have the following situations
-			// 1. none of the gitignores match
-			// 2. one or more match
-			// for #1 this means we should include the file
-			// for #2 this means the last one wins since it should be the most correct
-			if m := ignore.MatchIsDir(joined, false); m != nil {
-				shouldIgnore = m.Ignore()
-				if shouldIgnore {
-					skipReason = SkipReasonGitignore
-				} else {
-					skipReason = ""
-				}
-			}
-		}
-
-		for _, ignore := range ignores {
-			// same rules as above
-			if m := ignore.MatchIsDir(joined, false); m != nil {
-				shouldIgnore = m.Ignore()
-				if shouldIgnore {
-					skipReason = SkipReasonIgnoreFile
-				} else {
-					skipReason = ""
-				}
-			}
-		}
-
-		for _, ignore := range customIgnores {
-			// same rules as above
-			if m := ignore.MatchIsDir(joined, false); m != nil {
-				shouldIgnore = m.Ignore()
-				if shouldIgnore {
-					skipReason = SkipReasonCustomIgnore
-				} else {
-					skipReason = ""
-				}
-			}
+		trace := f.newDecisionTrace()
+		shouldIgnore, skipReason, details, err := f.evaluateFile(file, root, directory, iteration, layers, ancestors, trace)
+		if err != nil {
+			return nil, err
 		}
 
-		if len(f.IncludeFilename) != 0 {
-			// include files
-			shouldIgnore = !slices.ContainsFunc(f.IncludeFilename, func(allow string) bool {
-				return file.Name() == allow
-			})
-			if shouldIgnore {
-				skipReason = SkipReasonIncludeFilename
-			} else {
-				skipReason = ""
-			}
-		}
-		// Exclude comes after include as it takes precedence
-		for _, deny := range f.ExcludeFilename {
-			if file.Name() == deny {
-				shouldIgnore = true
-				skipReason = SkipReasonExcludeFilename
-				break
+		if shouldIgnore {
+			f.skip(joined, file.Name(), false, skipReason, trace, layers)
+			if record != nil {
+				record.Skipped = append(record.Skipped, newSnapshotSkip(file, false, skipReason))
 			}
+			continue
 		}
 
-		if len(f.IncludeFilenameRegex) != 0 {
-			shouldIgnore = !slices.ContainsFunc(f.IncludeFilenameRegex, func(allow *regexp.Regexp) bool {
-				return allow.MatchString(file.Name())
-			})
-			if shouldIgnore {
-				skipReason = SkipReasonIncludeFilenameRegex
-			} else {
-				skipReason = ""
-			}
-		}
-		// Exclude comes after include as it takes precedence
-		for _, deny := range f.ExcludeFilenameRegex {
-			if deny.MatchString(file.Name()) {
-				shouldIgnore = true
-				skipReason = SkipReasonExcludeFilenameRegex
-				break
-			}
+		result := &File{
+			Location: joined,
+			Filename: file.Name(),
+			Root:     root,
+			Depth:    iteration,
+			DirEntry: file,
+			Content:  details.content,
+			Type:     FileTypeFile,
+			Language: details.language,
+			info:     details.info,
 		}
+		scan.returned++
 
-		// Ignore hidden files
-		if !f.IncludeHidden {
-			s, err := IsHiddenDirEntry(file, directory)
+		if f.cfg.StatFiles && details.info == nil {
+			result.info, err = file.Info()
 			if err != nil {
 				if !f.errorsHandler(err) {
-					return err
+					return nil, err
 				}
 			}
-
-			if s {
-				shouldIgnore = true
-				skipReason = SkipReasonHidden
-			}
 		}
 
-		// Check against extensions
-		if len(f.AllowListExtensions) != 0 {
-			ext := GetExtension(file.Name())
-			// try again because we could have one of those pesky ones such as something.spec.tsx
-			// but only if we didn't already find something to save on a bit of processing
-			if !slices.Contains(f.AllowListExtensions, ext) && !slices.Contains(f.AllowListExtensions, GetExtension(ext)) {
-				shouldIgnore = true
-				skipReason = SkipReasonAllowListExtension
-			}
+		if record != nil {
+			info, _ := result.Info()
+			sf := newSnapshotFile(info)
+			sf.Name = file.Name()
+			sf.Content = details.content
+			sf.Language = details.language
+			sf.file = result
+			record.Files = append(record.Files, sf)
 		}
 
-		if len(f.ExcludeListExtensions) != 0 {
-			ext := GetExtension(file.Name())
-			shouldIgnore = slices.ContainsFunc(f.ExcludeListExtensions, func(deny string) bool {
-				return ext == deny || GetExtension(ext) == deny
-			})
-			if shouldIgnore {
-				skipReason = SkipReasonExcludeListExtension
-			} else {
-				skipReason = ""
-			}
+		if emit == nil {
+			scan.files = append(scan.files, result)
+			continue
 		}
 
-		for _, p := range f.LocationExcludePattern {
-			if strings.Contains(joined, p) {
-				shouldIgnore = true
-				skipReason = SkipReasonLocationExcludePattern
-				break
-			}
-		}
-
-		if f.IgnoreBinaryFiles {
-			fi, err := os.Open(filepath.Join(directory, file.Name()))
-			if err != nil {
-				if !f.errorsHandler(err) {
-					return err
-				}
-			}
-			defer func(fi *os.File) {
-				_ = fi.Close()
-			}(fi)
-
-			buffer := make([]byte, f.IgnoreBinaryFileBytes)
-
-			// Read up to buffer size
-			_, err = io.ReadFull(fi, buffer)
-			if err != nil && err != io.EOF && !errors.Is(err, io.ErrUnexpectedEOF) {
-				if !f.errorsHandler(err) {
-					return err
-				}
-			}
-
-			// cheaply check if is binary file by checking for null byte.
-			// note that this could be improved later on by checking for magic numbers and the like
-			// but that should probably be its own package
-			for _, b := range buffer {
-				if b == 0 {
-					shouldIgnore = true
-					skipReason = SkipReasonBinary
-					break
-				}
+		// sent in batches so that a directory holding a huge number of files
+		// is not held in memory, with a new slice each time as the sink can keep it
+		batch = append(batch, result)
+		if len(batch) == f.cfg.BatchSize {
+			if err := emit(batch); err != nil {
+				return nil, err
 			}
+			batch = nil
 		}
+	}
 
-		if shouldIgnore {
-			f.skipHandler(joined, file.Name(), false, skipReason)
-		} else {
-			f.fileListQueue <- &File{
-				Location: joined,
-				Filename: file.Name(),
-			}
+	if len(batch) != 0 {
+		if err := emit(batch); err != nil {
+			return nil, err
 		}
 	}
 
-	// if we are the 1st iteration IE not the root, we run in parallel
-	wg := sync.WaitGroup{}
-
-	// Now we process the directories after hopefully giving the
-	// channel some files to process
 	for _, dir := range dirs {
-		var shouldIgnore bool
-		var skipReason SkipReason
 		joined := filepath.ToSlash(filepath.Join(directory, dir.Name()))
 
-		// Check against the ignore files we have if the file we are looking at
-		// should be ignored
-		// It is safe to always call this because the gitignores will not be added
-		// in previous steps
-		for _, ignore := range globalIgnores {
-			if m := ignore.MatchIsDir(joined, true); m != nil {
-				shouldIgnore = m.Ignore()
-				if shouldIgnore {
-					skipReason = SkipReasonGlobalIgnore
-				} else {
-					skipReason = ""
-				}
-			}
-		}
-		for _, ignore := range gitignores {
-			// we have the following situations
-			// 1. none of the gitignores match
-			// 2. one or more match
-			// for #1 this means we should include the file
-			// for #2 this means the last one wins since it should be the most correct
-			if m := ignore.MatchIsDir(joined, true); m != nil {
-				shouldIgnore = m.Ignore()
-				if shouldIgnore {
-					skipReason = SkipReasonGitignore
-				} else {
-					skipReason = ""
-				}
-			}
-		}
-		for _, ignore := range ignores {
-			// same rules as above
-			if m := ignore.MatchIsDir(joined, true); m != nil {
-				shouldIgnore = m.Ignore()
-				if shouldIgnore {
-					skipReason = SkipReasonIgnoreFile
-				} else {
-					skipReason = ""
-				}
-			}
-		}
-		for _, ignore := range customIgnores {
-			// same rules as above
-			if m := ignore.MatchIsDir(joined, true); m != nil {
-				shouldIgnore = m.Ignore()
-				if shouldIgnore {
-					skipReason = SkipReasonCustomIgnore
-				} else {
-					skipReason = ""
-				}
-			}
-		}
-		for _, ignore := range moduleIgnores {
-			// same rules as above
-			if m := ignore.MatchIsDir(joined, true); m != nil {
-				shouldIgnore = m.Ignore()
-				if shouldIgnore {
-					skipReason = SkipReasonModuleIgnore
-				} else {
-					skipReason = ""
-				}
-			}
-		}
-
-		// start by saying we didn't find it then check each possible
-		// choice to see if we did find it
-		// if we didn't find it then we should ignore
-		if len(f.IncludeDirectory) != 0 {
-			shouldIgnore = !slices.ContainsFunc(f.IncludeDirectory, func(allow string) bool {
-				return dir.Name() == allow
-			})
-			if shouldIgnore {
-				skipReason = SkipReasonIncludeDirectory
-			} else {
-				skipReason = ""
-			}
-		}
-		// Confirm if there are any files in the path deny list which usually includes
-		// things like .git .hg and .svn
-		// Comes after include as it takes precedence
-		for _, deny := range f.ExcludeDirectory {
-			if isSuffixDir(joined, deny) {
-				shouldIgnore = true
-				skipReason = SkipReasonExcludeDirectory
-				break
-			}
-		}
-
-		if len(f.IncludeDirectoryRegex) != 0 {
-			shouldIgnore = !slices.ContainsFunc(f.IncludeDirectoryRegex, func(allow *regexp.Regexp) bool {
-				return allow.MatchString(dir.Name())
-			})
-			if shouldIgnore {
-				skipReason = SkipReasonIncludeDirectoryRegex
-			} else {
-				skipReason = ""
-			}
-		}
-		// Exclude comes after include as it takes precedence
-		for _, deny := range f.ExcludeDirectoryRegex {
-			if deny.MatchString(dir.Name()) {
-				shouldIgnore = true
-				skipReason = SkipReasonExcludeDirectoryRegex
-				break
-			}
-		}
-
-		// Ignore hidden directories
-		if !f.IncludeHidden {
-			s, err := IsHiddenDirEntry(dir, directory)
-			if err != nil {
-				if !f.errorsHandler(err) {
-					return err
-				}
-			}
-
-			if s {
-				shouldIgnore = true
-				skipReason = SkipReasonHidden
-			}
-		}
-
-		for _, p := range f.LocationExcludePattern {
-			if strings.Contains(joined, p) {
-				shouldIgnore = true
-				skipReason = SkipReasonLocationExcludePattern
-				break
-			}
+		trace := f.newDecisionTrace()
+		shouldIgnore, skipReason, err := f.evaluateDir(dir, root, directory, iteration, layers, ancestors, trace)
+		if err != nil {
+			return nil, err
 		}
 
 		if shouldIgnore {
-			f.skipHandler(joined, dir.Name(), true, skipReason)
+			f.skip(joined, dir.Name(), true, skipReason, trace, layers)
+			if record != nil {
+				record.Skipped = append(record.Skipped, newSnapshotSkip(dir, true, skipReason))
+			}
+			continue
 		}
 
-		if !shouldIgnore {
-			if iteration == 0 {
-				wg.Add(1)
-				go func(iteration int, directory string, gitignores []gitignore.GitIgnore, ignores []gitignore.GitIgnore) {
-					_ = f.walkDirectoryRecursive(iteration+1, joined, globalIgnores, gitignores, ignores, moduleIgnores, customIgnores)
-					wg.Done()
-				}(iteration, joined, gitignores, ignores)
-			} else {
-				err = f.walkDirectoryRecursive(iteration+1, joined, globalIgnores, gitignores, ignores, moduleIgnores, customIgnores)
-				if err != nil {
-					return err
-				}
-			}
+		scan.dirs = append(scan.dirs, dir)
+		if record != nil {
+			record.Directories = append(record.Directories, dir.Name())
 		}
 	}
 
-	wg.Wait()
-
-	return nil
+	return scan, nil
 }
 
 // FindRepositoryRoot given the supplied directory walks backwards looking for a
@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"io/fs"
+	"os"
+	"time"
+)
+
+// Info returns the fs.FileInfo for the file. If the walker had StatFiles set this is
+// the value fetched while walking, otherwise it is fetched from the DirEntry on first
+// call and remembered for later calls. Files not produced by a walker fall back to an
+// os.Lstat of Location. Like fs.DirEntry this describes the entry itself so symlinks
+// are not followed.
+func (f *File) Info() (fs.FileInfo, error) {
+	if f.info != nil {
+		return f.info, nil
+	}
+
+	var info fs.FileInfo
+	var err error
+	if f.DirEntry != nil {
+		info, err = f.DirEntry.Info()
+	} else {
+		info, err = os.Lstat(f.Location)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f.info = info
+	return info, nil
+}
+
+// Size returns the size of the file in bytes, or 0 if the info could not be fetched
+func (f *File) Size() int64 {
+	info, err := f.Info()
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// Mode returns the file mode bits. If the info could not be fetched only the type
+// bits known from the DirEntry are returned, or 0 if there is no DirEntry
+func (f *File) Mode() fs.FileMode {
+	info, err := f.Info()
+	if err != nil {
+		if f.DirEntry != nil {
+			return f.DirEntry.Type()
+		}
+		return 0
+	}
+	return info.Mode()
+}
+
+// ModTime returns the modification time of the file, or the zero time if the info could not be fetched
+func (f *File) ModTime() time.Time {
+	info, err := f.Info()
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
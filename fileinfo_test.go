@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestFileInfoMetadata(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "top.go"), "12345")
+	writeFile(t, filepath.Join(root, "a", "b", "deep.go"), "1234567890")
+
+	for _, statFiles := range []bool{false, true} {
+		fileListQueue := make(chan *File, 100)
+		walker := NewFileWalker(root, fileListQueue)
+		walker.StatFiles = statFiles
+		if err := walker.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		files := map[string]*File{}
+		for f := range fileListQueue {
+			files[f.Filename] = f
+		}
+
+		top, deep := files["top.go"], files["deep.go"]
+		if top == nil || deep == nil {
+			t.Fatalf("expected both files got %v", files)
+		}
+
+		if (top.info != nil) != statFiles {
+			t.Errorf("StatFiles=%v expected info populated to be %v", statFiles, statFiles)
+		}
+		if top.Depth != 0 || deep.Depth != 2 {
+			t.Errorf("expected depths 0 and 2 got %d and %d", top.Depth, deep.Depth)
+		}
+		if top.Root != root || deep.Root != root {
+			t.Errorf("expected root %q got %q and %q", root, top.Root, deep.Root)
+		}
+		if top.Size() != 5 || deep.Size() != 10 {
+			t.Errorf("expected sizes 5 and 10 got %d and %d", top.Size(), deep.Size())
+		}
+		if !top.Mode().IsRegular() {
+			t.Errorf("expected regular file mode got %v", top.Mode())
+		}
+		if time.Since(top.ModTime()) > time.Hour {
+			t.Errorf("expected recent modification time got %v", top.ModTime())
+		}
+		if top.DirEntry == nil || top.DirEntry.Name() != "top.go" {
+			t.Errorf("expected DirEntry for top.go got %v", top.DirEntry)
+		}
+	}
+}
+
+func TestFileInfoParallelRoot(t *testing.T) {
+	root1 := t.TempDir()
+	root2 := t.TempDir()
+	writeFile(t, filepath.Join(root1, "one.go"), "")
+	writeFile(t, filepath.Join(root2, "two.go"), "")
+
+	fileListQueue := make(chan *File, 100)
+	walker := NewParallelFileWalker([]string{root1, root2}, fileListQueue)
+	if err := walker.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	for f := range fileListQueue {
+		switch f.Filename {
+		case "one.go":
+			if f.Root != root1 {
+				t.Errorf("expected one.go root %q got %q", root1, f.Root)
+			}
+		case "two.go":
+			if f.Root != root2 {
+				t.Errorf("expected two.go root %q got %q", root2, f.Root)
+			}
+		}
+	}
+}
+
+func TestFileInfoFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"src/main.go": {Data: []byte("package main"), Mode: 0o600},
+	}
+
+	fileListQueue := make(chan *File, 100)
+	walker := NewFileWalkerFS(fsys, ".", fileListQueue)
+	if err := walker.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	f := <-fileListQueue
+	if f.Size() != 12 || f.Mode() != 0o600 || f.Depth != 1 || f.Root != "." {
+		t.Errorf("unexpected metadata size=%d mode=%v depth=%d root=%q", f.Size(), f.Mode(), f.Depth, f.Root)
+	}
+}
+
+func TestFileInfoNotFromWalker(t *testing.T) {
+	location := filepath.Join(t.TempDir(), "manual.go")
+	writeFile(t, location, "abc")
+
+	f := &File{Location: location, Filename: "manual.go"}
+	if f.Size() != 3 {
+		t.Errorf("expected size 3 got %d", f.Size())
+	}
+
+	missing := &File{Location: filepath.Join(t.TempDir(), "missing.go")}
+	if _, err := missing.Info(); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error got %v", err)
+	}
+	if missing.Size() != 0 || missing.Mode() != 0 || !missing.ModTime().IsZero() {
+		t.Error("expected zero values for missing file")
+	}
+}
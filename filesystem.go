@@ -0,0 +1,210 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/boyter/gocodewalker/go-gitignore"
+)
+
+// NewFileWalkerFS constructs a filewalker, which will walk the supplied root inside fsys
+// and output File results to the supplied queue as it finds them.
+// All directory reads, ignore file reads, binary checks and .git/info/exclude lookups
+// go through fsys, allowing walking of an embed.FS, zip.Reader, fstest.MapFS or any other fs.FS.
+// The root must be a valid fs.FS path such as "." or "src/pkg" and every File Location
+// is returned relative to fsys. Paths in CustomIgnoreFiles are also resolved inside fsys.
+func NewFileWalkerFS(fsys fs.FS, root string, fileListQueue chan<- *File, opts ...Option) *FileWalker {
+	f := NewFileWalker(root, fileListQueue)
+	f.fsys = fsys
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// readDir returns the entries of the supplied directory either from the fs.FS
+// if one was supplied or the operating system
+func (f *FileWalker) readDir(directory string) ([]fs.DirEntry, error) {
+	if f.fsys != nil {
+		return fs.ReadDir(f.fsys, directory)
+	}
+
+	d, err := f.osOpen(directory)
+	if err != nil {
+		return nil, err
+	}
+	defer func(d *os.File) {
+		err := d.Close()
+		if err != nil {
+			f.errorsHandler(err)
+		}
+	}(d)
+
+	return d.ReadDir(-1)
+}
+
+// readFile returns the contents of the supplied file either from the fs.FS
+// if one was supplied or the operating system. It is only used to read ignore
+// and project config files, so every successful read is counted as one parsed
+func (f *FileWalker) readFile(name string) ([]byte, error) {
+	var content []byte
+	var err error
+	if f.fsys != nil {
+		content, err = fs.ReadFile(f.fsys, name)
+	} else {
+		content, err = f.osReadFile(name)
+	}
+	if err == nil {
+		f.stats.ignoreFilesParsed.Add(1)
+	}
+	return content, err
+}
+
+// openFile opens the supplied file for reading either from the fs.FS
+// if one was supplied or the operating system
+func (f *FileWalker) openFile(name string) (io.ReadCloser, error) {
+	if f.fsys != nil {
+		return f.fsys.Open(name)
+	}
+	return os.Open(name)
+}
+
+// stat returns the info of the named file following symlinks, either from
+// the fs.FS if one was supplied or the operating system
+func (f *FileWalker) stat(name string) (fs.FileInfo, error) {
+	if f.fsys != nil {
+		return fs.Stat(f.fsys, name)
+	}
+	return os.Stat(name)
+}
+
+// lstat returns the info of the named file without following symlinks, either
+// from the fs.FS if one was supplied, which has no symlinks, or the operating system
+func (f *FileWalker) lstat(name string) (fs.FileInfo, error) {
+	if f.fsys != nil {
+		return fs.Stat(f.fsys, name)
+	}
+	return os.Lstat(name)
+}
+
+// absDir returns the absolute path of the directory which is used as the base
+// of any ignore file found in it. When walking a fs.FS there is no real absolute
+// path so the FS-relative path is rooted at / which keeps gitignore anchoring
+// relative to the FS rather than the current working directory
+func (f *FileWalker) absDir(directory string) (string, error) {
+	if f.fsys != nil {
+		return path.Join("/", directory), nil
+	}
+	return filepath.Abs(directory)
+}
+
+// matchIgnore matches the joined path against the supplied ignore resolving it
+// the same way absDir resolves the base of the ignore file
+func (f *FileWalker) matchIgnore(ignore gitignore.GitIgnore, joined string, isDir bool) gitignore.Match {
+	if f.fsys != nil {
+		return ignore.Absolute(path.Join("/", joined), isDir)
+	}
+	return ignore.MatchIsDir(joined, isDir)
+}
+
+// isHidden determines if the entry is hidden, which for a fs.FS is always
+// based on the name because there are no file attributes to check
+func (f *FileWalker) isHidden(entry fs.DirEntry, directory string) (bool, error) {
+	if f.fsys != nil {
+		return strings.HasPrefix(entry.Name(), "."), nil
+	}
+	return IsHiddenDirEntry(entry, directory)
+}
+
+// gitInfoExclude reads $GIT_DIR/info/exclude for the supplied directory returning
+// false if there is no such file. GIT_DIR is only respected when walking the operating
+// system, as it refers to a location outside any fs.FS
+func (f *FileWalker) gitInfoExclude(directory string) (ignoreFile, bool) {
+	var location string
+	var content []byte
+	var err error
+	if f.fsys != nil {
+		location = path.Join(directory, ".git", "info", "exclude")
+		content, err = fs.ReadFile(f.fsys, location)
+	} else {
+		gitdir := os.Getenv("GIT_DIR")
+		if gitdir == "" {
+			gitdir = filepath.Join(directory, ".git")
+		}
+		location = filepath.Join(gitdir, "info", "exclude")
+		content, err = os.ReadFile(location)
+	}
+	if err != nil {
+		return ignoreFile{}, false
+	}
+	f.stats.ignoreFilesParsed.Add(1)
+
+	abs, err := f.absDir(directory)
+	if err != nil {
+		return ignoreFile{}, false
+	}
+
+	return f.newIgnoreFile(content, abs, filepath.ToSlash(location)), true
+}
+
+// readHead opens the file and returns up to its first n bytes
+func (f *FileWalker) readHead(location string, n int) ([]byte, error) {
+	fi, err := f.openFile(location)
+	if err != nil {
+		return nil, err
+	}
+	defer func(fi io.ReadCloser) {
+		_ = fi.Close()
+	}(fi)
+
+	buffer := make([]byte, n)
+
+	// Read up to buffer size
+	read, err := io.ReadFull(fi, buffer)
+	if err != nil && err != io.EOF && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return buffer[:read], err
+	}
+	return buffer[:read], nil
+}
+
+// fileHead is the start of a file, read at most once while the file is evaluated so
+// that detecting its language and whether it is binary share a single open and read
+type fileHead struct {
+	size int    // the most bytes any check of the file needs
+	data []byte // up to size bytes once read
+	err  error  // set if reading failed and the errorsHandler asked to continue
+	read bool
+}
+
+// head returns up to the first n bytes of the file, reading h.size bytes of it the first time
+// it is called. A read error is passed to the errorsHandler once, and only returned if it asks
+// for processing to stop, otherwise it is left in h.err along with whatever could be read
+func (f *FileWalker) head(h *fileHead, location string, n int) ([]byte, error) {
+	if !h.read {
+		h.read = true
+		h.data, h.err = f.readHead(location, h.size)
+		if h.err != nil && !f.errorsHandler(h.err) {
+			return nil, h.err
+		}
+	}
+	return h.data[:min(n, len(h.data))], nil
+}
+
+// detectContent passes the start of the file, which is its first IgnoreBinaryFileBytes
+// bytes, to the content detector to determine if it is binary
+func (f *FileWalker) detectContent(head []byte) *Content {
+	detector := f.contentDetector
+	if detector == nil {
+		detector = DefaultContentDetector{}
+	}
+
+	content := detector.Detect(head)
+	return &content
+}
@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"io/fs"
+	"slices"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+// collectWalkFS runs the supplied walker and returns the sorted locations it emitted
+func collectWalkFS(t *testing.T, walker *FileWalker, fileListQueue chan *File) []string {
+	t.Helper()
+	go func() {
+		if err := walker.Start(); err != nil {
+			t.Errorf("walker returned error: %v", err)
+		}
+	}()
+
+	got := []string{}
+	for f := range fileListQueue {
+		got = append(got, f.Location)
+	}
+	slices.Sort(got)
+	return got
+}
+
+// mapFS creates a fstest.MapFS holding a regular file with the supplied content at each path
+func mapFS(files map[string]string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for location, content := range files {
+		fsys[location] = &fstest.MapFile{Data: []byte(content)}
+	}
+	return fsys
+}
+
+// walkFS walks fsys from its root with the supplied options returning every File in the order
+// the sink received them, and the reason each path skipped was skipped keyed by the path
+func walkFS(t *testing.T, fsys fs.FS, opts ...Option) ([]*File, map[string]SkipReason) {
+	t.Helper()
+	var mutex sync.Mutex
+	skipped := map[string]SkipReason{}
+	sink := &SliceSink{}
+	opts = append([]Option{WithSink(sink), WithSkipHandler(func(path string, name string, isDir bool, reason SkipReason) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		skipped[path] = reason
+	})}, opts...)
+
+	walker := NewFileWalkerFS(fsys, ".", nil, opts...)
+	if err := walker.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return sink.Files, skipped
+}
+
+// locations returns the sorted locations of the supplied files
+func locations(files []*File) []string {
+	found := []string{}
+	for _, f := range files {
+		found = append(found, f.Location)
+	}
+	slices.Sort(found)
+	return found
+}
+
+func TestNewFileWalkerFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		".gitignore":               {Data: []byte("/build\n*.log\n")},
+		"main.go":                  {Data: []byte("package main")},
+		"debug.log":                {Data: []byte("log")},
+		"build/out.go":             {Data: []byte("package out")},
+		"src/build/keep.go":        {Data: []byte("package build")},
+		"src/.ignore":              {Data: []byte("generated.go\n")},
+		"src/generated.go":         {Data: []byte("package src")},
+		"src/lib.go":               {Data: []byte("package src")},
+		".hidden/secret.go":        {Data: []byte("package hidden")},
+		".git/info/exclude":        {Data: []byte("local.go\n")},
+		"local.go":                 {Data: []byte("package main")},
+		"vendor/thing/vendored.go": {Data: []byte("package thing")},
+	}
+
+	fileListQueue := make(chan *File, 100)
+	walker := NewFileWalkerFS(fsys, ".", fileListQueue)
+	got := collectWalkFS(t, walker, fileListQueue)
+
+	expected := []string{
+		"main.go",
+		"src/build/keep.go",
+		"src/lib.go",
+		"vendor/thing/vendored.go",
+	}
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected %v got %v", expected, got)
+	}
+}
+
+func TestNewFileWalkerFSSubdirectoryRoot(t *testing.T) {
+	fsys := fstest.MapFS{
+		"repo/.gitignore":     {Data: []byte("/out\n")},
+		"repo/a.go":           {Data: []byte("package a")},
+		"repo/out/b.go":       {Data: []byte("package out")},
+		"repo/sub/out/c.go":   {Data: []byte("package out")},
+		"elsewhere/d.go":      {Data: []byte("package d")},
+		"repo/sub/.gitignore": {Data: []byte("/e.go\n")},
+		"repo/sub/e.go":       {Data: []byte("package sub")},
+	}
+
+	fileListQueue := make(chan *File, 100)
+	walker := NewFileWalkerFS(fsys, "repo", fileListQueue)
+	walker.IncludeHidden = true
+	got := collectWalkFS(t, walker, fileListQueue)
+
+	expected := []string{
+		"repo/.gitignore",
+		"repo/a.go",
+		"repo/sub/.gitignore",
+		"repo/sub/out/c.go",
+	}
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected %v got %v", expected, got)
+	}
+}
+
+func TestNewFileWalkerFSBinary(t *testing.T) {
+	fsys := fstest.MapFS{
+		"text.txt":   {Data: []byte("hello")},
+		"binary.bin": {Data: []byte{'a', 0, 'b'}},
+	}
+
+	fileListQueue := make(chan *File, 100)
+	walker := NewFileWalkerFS(fsys, ".", fileListQueue)
+	walker.IgnoreBinaryFiles = true
+
+	var skipped []string
+	walker.SetSkipHandler(func(path string, name string, isDir bool, reason SkipReason) {
+		if reason == SkipReasonBinary {
+			skipped = append(skipped, path)
+		}
+	})
+	got := collectWalkFS(t, walker, fileListQueue)
+
+	if !slices.Equal(got, []string{"text.txt"}) {
+		t.Errorf("expected only text.txt got %v", got)
+	}
+	if !slices.Equal(skipped, []string{"binary.bin"}) {
+		t.Errorf("expected binary.bin to be skipped got %v", skipped)
+	}
+}
+
+func TestNewFileWalkerFSCustomIgnoreFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config/global.ignore": {Data: []byte("/config\n*.tmp\n")},
+		"a.go":                 {Data: []byte("package a")},
+		"b.tmp":                {Data: []byte("tmp")},
+	}
+
+	fileListQueue := make(chan *File, 100)
+	walker := NewFileWalkerFS(fsys, ".", fileListQueue)
+	walker.CustomIgnoreFiles = []string{"config/global.ignore"}
+	got := collectWalkFS(t, walker, fileListQueue)
+
+	if !slices.Equal(got, []string{"a.go"}) {
+		t.Errorf("expected only a.go got %v", got)
+	}
+}
+
+func TestNewFileWalkerFSMissingRoot(t *testing.T) {
+	fileListQueue := make(chan *File, 100)
+	walker := NewFileWalkerFS(fstest.MapFS{}, "missing", fileListQueue)
+	walker.SetErrorHandler(func(e error) bool { return false })
+
+	if err := walker.Start(); err == nil {
+		t.Error("expected error for missing root got nil")
+	}
+}
@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"io/fs"
+	"strings"
+)
+
+// FileTypePolicy is which types of file are returned, where anything not regular is never opened
+// to check if it is binary so that reading a FIFO for example cannot block walking. It only applies
+// to files as directories, including symlinks to them when FollowSymlinks is set, are always walked.
+// The zero value allows no type of file, so is rejected by Validate rather than returning nothing.
+type FileTypePolicy struct {
+	Regular   bool `json:"regular"`   // Regular files
+	Symlink   bool `json:"symlink"`   // Symlinks which are not followed as directories, whatever they point at
+	FIFO      bool `json:"fifo"`      // Named pipes
+	Socket    bool `json:"socket"`    // Unix domain sockets
+	Device    bool `json:"device"`    // Block and character devices
+	Irregular bool `json:"irregular"` // Anything else which is not a regular file, such as a Windows reparse point that is not a symlink
+}
+
+// DefaultFileTypePolicy returns regular files and symlinks, as was the case before other types were skipped
+func DefaultFileTypePolicy() FileTypePolicy {
+	return FileTypePolicy{Regular: true, Symlink: true}
+}
+
+// String returns the names of the types returned, comma separated
+func (p FileTypePolicy) String() string {
+	names := []string{}
+	for _, t := range p.types() {
+		if t.included {
+			names = append(names, t.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// ParseFileTypePolicy returns a policy which only returns the comma separated types named, which
+// are any of regular, symlink, fifo, socket, device and irregular. Returns false if any are unknown.
+func ParseFileTypePolicy(s string) (FileTypePolicy, bool) {
+	p := FileTypePolicy{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, t := range p.types() {
+			if t.name == name {
+				*t.set = true
+				found = true
+			}
+		}
+		if !found {
+			return p, false
+		}
+	}
+	return p, true
+}
+
+// fileTypePolicyType is one of the types in a policy
+type fileTypePolicyType struct {
+	name     string
+	included bool
+	set      *bool
+}
+
+func (p *FileTypePolicy) types() []fileTypePolicyType {
+	return []fileTypePolicyType{
+		{"regular", p.Regular, &p.Regular},
+		{"symlink", p.Symlink, &p.Symlink},
+		{"fifo", p.FIFO, &p.FIFO},
+		{"socket", p.Socket, &p.Socket},
+		{"device", p.Device, &p.Device},
+		{"irregular", p.Irregular, &p.Irregular},
+	}
+}
+
+// skipReason returns the reason a file with the supplied mode is skipped,
+// or an empty string if it is one of the types returned
+func (p FileTypePolicy) skipReason(mode fs.FileMode) SkipReason {
+	switch {
+	case mode.IsRegular():
+		if !p.Regular {
+			return SkipReasonRegularFile
+		}
+	case mode&fs.ModeSymlink != 0:
+		if !p.Symlink {
+			return SkipReasonSymlink
+		}
+	case mode&fs.ModeNamedPipe != 0:
+		if !p.FIFO {
+			return SkipReasonFIFO
+		}
+	case mode&fs.ModeSocket != 0:
+		if !p.Socket {
+			return SkipReasonSocket
+		}
+	case mode&fs.ModeDevice != 0:
+		if !p.Device {
+			return SkipReasonDevice
+		}
+	default:
+		if !p.Irregular {
+			return SkipReasonIrregular
+		}
+	}
+	return ""
+}
+
+// isRegularFile returns true if the file is a regular file, or a symlink to one, and as
+// such can be opened without blocking. Symlinks which cannot be resolved are treated as
+// regular so that opening them reports the error as it did before types were checked
+func (f *FileWalker) isRegularFile(file fs.DirEntry, joined string) bool {
+	if file.Type().IsRegular() {
+		return true
+	}
+	if file.Type()&fs.ModeSymlink == 0 {
+		return false
+	}
+
+	info, err := f.stat(joined)
+	if err != nil {
+		return true
+	}
+	return info.Mode().IsRegular()
+}
@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"io/fs"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+// fileTypeFS holds a file of every type, each of which holds a null byte so
+// that it is skipped as binary if it is opened when IgnoreBinaryFiles is set.
+// The symlink points at the fifo so should not be opened either
+var fileTypeFS = fstest.MapFS{
+	"regular": &fstest.MapFile{Data: []byte{0}},
+	"symlink": &fstest.MapFile{Data: []byte("fifo"), Mode: fs.ModeSymlink},
+	"fifo":    &fstest.MapFile{Data: []byte{0}, Mode: fs.ModeNamedPipe},
+	"socket":  &fstest.MapFile{Data: []byte{0}, Mode: fs.ModeSocket},
+	"device":  &fstest.MapFile{Data: []byte{0}, Mode: fs.ModeDevice | fs.ModeCharDevice},
+	"other":   &fstest.MapFile{Data: []byte{0}, Mode: fs.ModeIrregular},
+}
+
+func TestFileTypesDefault(t *testing.T) {
+	files, skipped := walkFS(t, fileTypeFS)
+
+	if !slices.Equal(locations(files), []string{"regular", "symlink"}) {
+		t.Errorf("expected regular and symlink got %v", locations(files))
+	}
+	expected := map[string]SkipReason{
+		"fifo":   SkipReasonFIFO,
+		"socket": SkipReasonSocket,
+		"device": SkipReasonDevice,
+		"other":  SkipReasonIrregular,
+	}
+	for name, reason := range expected {
+		if skipped[name] != reason {
+			t.Errorf("expected %s skipped with %s got %s", name, reason, skipped[name])
+		}
+	}
+}
+
+func TestFileTypesPolicy(t *testing.T) {
+	files, skipped := walkFS(t, fileTypeFS, WithFileTypes(FileTypePolicy{FIFO: true, Socket: true}))
+
+	if !slices.Equal(locations(files), []string{"fifo", "socket"}) {
+		t.Errorf("expected fifo and socket got %v", locations(files))
+	}
+	if skipped["regular"] != SkipReasonRegularFile || skipped["symlink"] != SkipReasonSymlink {
+		t.Errorf("expected regular and symlink skipped got %v", skipped)
+	}
+}
+
+func TestFileTypesNotOpened(t *testing.T) {
+	all := FileTypePolicy{Regular: true, Symlink: true, FIFO: true, Socket: true, Device: true, Irregular: true}
+	files, skipped := walkFS(t, fileTypeFS, WithFileTypes(all), WithIgnoreBinaryFiles(true))
+
+	// only the regular file is opened, and so found to be binary
+	if !slices.Equal(locations(files), []string{"device", "fifo", "other", "socket", "symlink"}) {
+		t.Errorf("expected every file other than regular got %v", locations(files))
+	}
+	if len(skipped) != 1 || skipped["regular"] != SkipReasonBinary {
+		t.Errorf("expected regular skipped as binary got %v", skipped)
+	}
+}
+
+func TestParseFileTypePolicy(t *testing.T) {
+	p, ok := ParseFileTypePolicy("regular, fifo")
+	if !ok || p != (FileTypePolicy{Regular: true, FIFO: true}) {
+		t.Errorf("expected regular and fifo got %+v", p)
+	}
+	if p.String() != "regular,fifo" {
+		t.Errorf("expected regular,fifo got %s", p.String())
+	}
+	if _, ok := ParseFileTypePolicy("regular,door"); ok {
+		t.Error("expected door to be unknown")
+	}
+}
@@ -0,0 +1,33 @@
+// SPDX-License-Identifier: MIT
+//go:build unix
+
+package gocodewalker
+
+import (
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestFileTypesFIFONotOpened(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "main.go"), "package main")
+	if err := syscall.Mkfifo(filepath.Join(root, "pipe"), 0o644); err != nil {
+		t.Skip("cannot create a fifo", err)
+	}
+
+	// opening the fifo would block forever as nothing writes to it
+	for _, types := range []FileTypePolicy{DefaultFileTypePolicy(), {Regular: true, FIFO: true}} {
+		got, skipped, _ := symlinkWalk(t, root, func(f *FileWalker) {
+			f.IgnoreBinaryFiles = true
+			f.FileTypes = types
+		})
+
+		if !got["main.go"] || got["pipe"] != types.FIFO {
+			t.Errorf("expected main.go and the fifo only if included got %v", got)
+		}
+		if !types.FIFO && skipped["pipe"] != SkipReasonFIFO {
+			t.Errorf("expected the fifo skipped with %s got %v", SkipReasonFIFO, skipped)
+		}
+	}
+}
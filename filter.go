@@ -0,0 +1,533 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"bytes"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/boyter/gocodewalker/go-gitignore"
+	"github.com/boyter/gocodewalker/language"
+)
+
+// ignoreFile is a parsed ignore file along with where it was read from,
+// which is reported when explaining why a path was ignored, and a hash
+// of its content used to spot changes between walks
+type ignoreFile struct {
+	gitignore.GitIgnore
+	source string
+	hash   uint64
+}
+
+// newIgnoreFile parses the content as gitignore syntax anchored at base,
+// with the patterns matching regardless of case when IgnoreCase is set
+func (f *FileWalker) newIgnoreFile(content []byte, base string, source string) ignoreFile {
+	h := fnv.New64a()
+	_, _ = h.Write(content)
+
+	parse := gitignore.New
+	if f.cfg.IgnoreCase {
+		parse = gitignore.NewIgnoreCase
+	}
+
+	return ignoreFile{
+		GitIgnore: parse(bytes.NewReader(content), base, nil),
+		source:    source,
+		hash:      h.Sum64(),
+	}
+}
+
+// ignoreLayers holds every ignore file which applies to a directory grouped by
+// how they were found. Each group is inherited from the parent directory with
+// any ignore files found in the directory itself appended.
+type ignoreLayers struct {
+	gitGlobal  []ignoreFile
+	global     []ignoreFile
+	gitignores []ignoreFile
+	ignores    []ignoreFile
+	modules    []ignoreFile
+	custom     []ignoreFile
+	project    *WalkerConfig // the settings with any project config files applied, nil if there are none
+}
+
+// evaluation tracks the state of the filter pipeline for a single path. Each filter
+// either ignores or includes the path, and the last one to do so decides the outcome
+type evaluation struct {
+	ignore bool
+	reason SkipReason
+	trace  *decisionTrace
+}
+
+// decide records the outcome of a filter which matched the path
+func (e *evaluation) decide(ignore bool, reason SkipReason, pattern string, source string, line int) {
+	e.ignore = ignore
+	if ignore {
+		e.reason = reason
+	} else {
+		e.reason = ""
+	}
+	e.trace.record(reason, ignore, pattern, source, line)
+}
+
+// matchIgnores checks the path against each of the supplied ignore files in order
+// where for each one that matches, the last one wins since it should be the most correct
+func (f *FileWalker) matchIgnores(e *evaluation, ignores []ignoreFile, joined string, isDir bool, reason SkipReason) {
+	for _, ignore := range ignores {
+		if m := f.matchIgnore(ignore.GitIgnore, joined, isDir); m != nil {
+			e.decide(m.Ignore(), reason, m.String(), ignore.source, m.Position().Line)
+		}
+	}
+}
+
+// loadIgnoreFiles pulls out all ignore, gitignore, gitmodule and project config files from the
+// files in the directory, which is at the supplied depth, and appends them to the inherited layers
+// to be applied for this directory and any subdirectories. The returned layers never share a backing
+// array with the layers passed in, as sibling directories may be walked concurrently.
+func (f *FileWalker) loadIgnoreFiles(directory string, depth int, files []fs.DirEntry, layers ignoreLayers) (ignoreLayers, error) {
+	layers = ignoreLayers{
+		gitGlobal:  layers.gitGlobal,
+		global:     layers.global,
+		gitignores: slices.Clip(layers.gitignores),
+		ignores:    slices.Clip(layers.ignores),
+		modules:    slices.Clip(layers.modules),
+		custom:     slices.Clip(layers.custom),
+		project:    layers.project,
+	}
+
+	for _, file := range files {
+		location := filepath.ToSlash(filepath.Join(directory, file.Name()))
+
+		if f.cfg.RespectProjectConfig && file.Name() == ProjectConfig {
+			var err error
+			layers, err = f.loadProjectConfig(location, directory, depth, layers)
+			if err != nil {
+				return layers, err
+			}
+		}
+
+		if !f.cfg.IgnoreGitIgnore {
+			if file.Name() == GitIgnore {
+				c, err := f.readFile(location)
+				if err != nil {
+					if f.errorsHandler(err) {
+						continue // if asked to ignore it lets continue
+					}
+					return layers, err
+				}
+
+				abs, err := f.absDir(directory)
+				if err != nil {
+					if f.errorsHandler(err) {
+						continue // if asked to ignore it lets continue
+					}
+					return layers, err
+				}
+
+				layers.gitignores = append(layers.gitignores, f.newIgnoreFile(c, filepath.ToSlash(abs), location))
+			}
+		}
+
+		if !f.cfg.IgnoreIgnoreFile {
+			if file.Name() == Ignore {
+				c, err := f.readFile(location)
+				if err != nil {
+					if f.errorsHandler(err) {
+						continue // if asked to ignore it lets continue
+					}
+					return layers, err
+				}
+
+				abs, err := f.absDir(directory)
+				if err != nil {
+					if f.errorsHandler(err) {
+						continue // if asked to ignore it lets continue
+					}
+					return layers, err
+				}
+
+				layers.ignores = append(layers.ignores, f.newIgnoreFile(c, abs, location))
+			}
+		}
+
+		// this should only happen on the first iteration
+		// because there should be one .gitmodules file per repository
+		// however we also need to support someone running in a directory of
+		// projects that have multiple repositories or in a go vendor
+		// repository etc... hence check every time
+		if !f.cfg.IgnoreGitModules {
+			if file.Name() == GitModules {
+				// now we need to open and parse the file
+				c, err := f.readFile(location)
+				if err != nil {
+					if f.errorsHandler(err) {
+						continue // if asked to ignore it lets continue
+					}
+					return layers, err
+				}
+
+				abs, err := f.absDir(directory)
+				if err != nil {
+					if f.errorsHandler(err) {
+						continue // if asked to ignore it lets continue
+					}
+					return layers, err
+				}
+
+				for _, gm := range extractGitModuleFolders(string(c)) {
+					layers.modules = append(layers.modules, f.newIgnoreFile([]byte(gm), abs, location))
+				}
+			}
+		}
+
+		for _, ci := range f.cfg.CustomIgnore {
+			if file.Name() == ci {
+				c, err := f.readFile(location)
+				if err != nil {
+					if f.errorsHandler(err) {
+						continue // if asked to ignore it lets continue
+					}
+					return layers, err
+				}
+
+				abs, err := f.absDir(directory)
+				if err != nil {
+					if f.errorsHandler(err) {
+						continue // if asked to ignore it lets continue
+					}
+					return layers, err
+				}
+
+				layers.custom = append(layers.custom, f.newIgnoreFile(c, abs, location))
+			}
+		}
+	}
+
+	if !f.cfg.IgnoreGitIgnore {
+		if gitExclude, ok := f.gitInfoExclude(directory); ok {
+			layers.gitignores = append(layers.gitignores, gitExclude)
+		}
+	}
+
+	// If we have custom ignore patterns defined we should concatenate them and treat them as a single gitignore file
+	if len(f.cfg.CustomIgnorePatterns) > 0 {
+		customIgnorePatternsCombined := strings.Join(f.cfg.CustomIgnorePatterns, "\n")
+
+		abs, err := f.absDir(directory)
+		if err != nil {
+			if !f.errorsHandler(err) {
+				return layers, err
+			}
+		}
+
+		layers.custom = append(layers.custom, f.newIgnoreFile([]byte(customIgnorePatternsCombined), abs, ""))
+	}
+
+	return layers, nil
+}
+
+// fileDetails is what was found out about a file while running the filter pipeline against it
+type fileDetails struct {
+	content  *Content    // set if it was checked for being binary
+	info     fs.FileInfo // set if it was fetched for the metadata filters
+	language string      // set if languages are detected and it could be
+}
+
+// evaluateFile runs the file filter pipeline against the supplied file returning
+// if it should be ignored and why, along with the details found out along the way.
+// An error is only returned when the errorsHandler asks for processing to stop.
+func (f *FileWalker) evaluateFile(file fs.DirEntry, root string, directory string, depth int, layers ignoreLayers, ancestors []os.FileInfo, trace *decisionTrace) (bool, SkipReason, fileDetails, error) {
+	e := evaluation{trace: trace}
+	cfg := layers.config(f)
+	joined := filepath.ToSlash(filepath.Join(directory, file.Name()))
+
+	// The users global git excludes file is the lowest priority followed by global
+	// ignore files supplied by path, so they are checked first and anything
+	// discovered while walking can override them
+	f.matchIgnores(&e, layers.gitGlobal, joined, false, SkipReasonGitGlobalIgnore)
+	f.matchIgnores(&e, layers.global, joined, false, SkipReasonGlobalIgnore)
+	f.matchIgnores(&e, layers.gitignores, joined, false, SkipReasonGitignore)
+	f.matchIgnores(&e, layers.ignores, joined, false, SkipReasonIgnoreFile)
+	f.matchIgnores(&e, layers.custom, joined, false, SkipReasonCustomIgnore)
+
+	if len(f.cfg.IncludeFilename) != 0 {
+		// include files
+		if i := f.indexName(f.cfg.IncludeFilename, file.Name()); i == -1 {
+			e.decide(true, SkipReasonIncludeFilename, strings.Join(f.cfg.IncludeFilename, ","), "", 0)
+		} else {
+			e.decide(false, SkipReasonIncludeFilename, f.cfg.IncludeFilename[i], "", 0)
+		}
+	}
+	// Exclude comes after include as it takes precedence
+	for _, deny := range f.cfg.ExcludeFilename {
+		if f.equalName(file.Name(), deny) {
+			e.decide(true, SkipReasonExcludeFilename, deny, "", 0)
+			break
+		}
+	}
+
+	if len(f.cfg.IncludeFilenameRegex) != 0 {
+		i := slices.IndexFunc(f.cfg.IncludeFilenameRegex, func(allow *regexp.Regexp) bool {
+			return allow.MatchString(file.Name())
+		})
+		if i == -1 {
+			e.decide(true, SkipReasonIncludeFilenameRegex, joinRegexps(f.cfg.IncludeFilenameRegex), "", 0)
+		} else {
+			e.decide(false, SkipReasonIncludeFilenameRegex, f.cfg.IncludeFilenameRegex[i].String(), "", 0)
+		}
+	}
+	// Exclude comes after include as it takes precedence
+	for _, deny := range f.cfg.ExcludeFilenameRegex {
+		if deny.MatchString(file.Name()) {
+			e.decide(true, SkipReasonExcludeFilenameRegex, deny.String(), "", 0)
+			break
+		}
+	}
+
+	// Ignore hidden files
+	if !cfg.IncludeHidden {
+		s, err := f.isHidden(file, directory)
+		if err != nil {
+			if !f.errorsHandler(err) {
+				return false, "", fileDetails{}, err
+			}
+		}
+
+		if s {
+			e.decide(true, SkipReasonHidden, file.Name(), "", 0)
+		}
+	}
+
+	// Check against extensions
+	if len(cfg.AllowListExtensions) != 0 {
+		ext := GetExtension(file.Name())
+		// try again because we could have one of those pesky ones such as something.spec.tsx
+		// but only if we didn't already find something to save on a bit of processing
+		if f.indexName(cfg.AllowListExtensions, ext) == -1 && f.indexName(cfg.AllowListExtensions, GetExtension(ext)) == -1 {
+			e.decide(true, SkipReasonAllowListExtension, ext, "", 0)
+		}
+	}
+
+	if len(f.cfg.ExcludeListExtensions) != 0 {
+		ext := GetExtension(file.Name())
+		excluded := slices.ContainsFunc(f.cfg.ExcludeListExtensions, func(deny string) bool {
+			return f.equalName(ext, deny) || f.equalName(GetExtension(ext), deny)
+		})
+		e.decide(excluded, SkipReasonExcludeListExtension, ext, "", 0)
+	}
+
+	for _, p := range f.cfg.LocationExcludePattern {
+		if strings.Contains(joined, p) {
+			e.decide(true, SkipReasonLocationExcludePattern, p, "", 0)
+			break
+		}
+	}
+
+	if len(f.includeGlobs) != 0 || len(f.excludeGlobs) != 0 {
+		rel := relativePath(root, joined)
+		if len(f.includeGlobs) != 0 {
+			i := slices.IndexFunc(f.includeGlobs, func(allow *glob) bool {
+				return allow.match(rel)
+			})
+			if i == -1 {
+				e.decide(true, SkipReasonIncludeGlob, joinGlobs(f.includeGlobs), "", 0)
+			} else {
+				e.decide(false, SkipReasonIncludeGlob, f.includeGlobs[i].source, "", 0)
+			}
+		}
+		// Exclude comes after include as it takes precedence
+		for _, deny := range f.excludeGlobs {
+			if deny.match(rel) {
+				e.decide(true, SkipReasonExcludeGlob, deny.source, "", 0)
+				break
+			}
+		}
+	}
+
+	applyCustomFilters(&e, f.fileFilters, joined, file, depth)
+
+	if reason := f.cfg.FileTypes.skipReason(file.Type()); reason != "" {
+		e.decide(true, reason, file.Type().String(), "", 0)
+	}
+
+	info, err := f.evaluateMetadata(&e, file)
+	if err != nil {
+		return false, "", fileDetails{}, err
+	}
+
+	// the start of the file is read once for as much as both language and binary detection need
+	var head fileHead
+	if f.detectsLanguages() {
+		head.size = language.HeadBytes
+	}
+	if cfg.IgnoreBinaryFiles {
+		head.size = max(head.size, f.cfg.IgnoreBinaryFileBytes)
+	}
+
+	lang, err := f.evaluateLanguage(&e, file, joined, &head)
+	if err != nil {
+		return false, "", fileDetails{}, err
+	}
+
+	// only regular files are opened, as anything else such as a FIFO could block forever
+	var content *Content
+	if cfg.IgnoreBinaryFiles && f.isRegularFile(file, joined) {
+		data, err := f.head(&head, joined, f.cfg.IgnoreBinaryFileBytes)
+		if err != nil {
+			return false, "", fileDetails{}, err
+		}
+		f.stats.binaryBytesRead.Add(int64(len(data)))
+
+		if head.err != nil {
+			// if we cannot read it we cannot say it is text so treat it as binary
+			content = &Content{Binary: true}
+		} else {
+			content = f.detectContent(data)
+		}
+		trace.setContent(content)
+
+		if content.Binary {
+			e.decide(true, SkipReasonBinary, content.String(), "", 0)
+		}
+	}
+
+	if !e.ignore {
+		reason, err := f.checkSymlink(file, root, joined, ancestors)
+		if err != nil {
+			if !f.errorsHandler(err) {
+				return false, "", fileDetails{}, err
+			}
+		}
+		if reason != "" {
+			e.decide(true, reason, "", "", 0)
+		}
+	}
+
+	return e.ignore, e.reason, fileDetails{content: content, info: info, language: lang}, nil
+}
+
+// evaluateDir runs the directory filter pipeline against the supplied directory
+// returning if it should be ignored and why. An error is only returned when the
+// errorsHandler asks for processing to stop.
+func (f *FileWalker) evaluateDir(dir fs.DirEntry, root string, directory string, depth int, layers ignoreLayers, ancestors []os.FileInfo, trace *decisionTrace) (bool, SkipReason, error) {
+	e := evaluation{trace: trace}
+	cfg := layers.config(f)
+	joined := filepath.ToSlash(filepath.Join(directory, dir.Name()))
+
+	// Check against the ignore files we have if the file we are looking at
+	// should be ignored
+	// It is safe to always call this because the gitignores will not be added
+	// in previous steps
+	f.matchIgnores(&e, layers.gitGlobal, joined, true, SkipReasonGitGlobalIgnore)
+	f.matchIgnores(&e, layers.global, joined, true, SkipReasonGlobalIgnore)
+	f.matchIgnores(&e, layers.gitignores, joined, true, SkipReasonGitignore)
+	f.matchIgnores(&e, layers.ignores, joined, true, SkipReasonIgnoreFile)
+	f.matchIgnores(&e, layers.custom, joined, true, SkipReasonCustomIgnore)
+	f.matchIgnores(&e, layers.modules, joined, true, SkipReasonModuleIgnore)
+
+	// start by saying we didn't find it then check each possible
+	// choice to see if we did find it
+	// if we didn't find it then we should ignore
+	if len(f.cfg.IncludeDirectory) != 0 {
+		if i := f.indexName(f.cfg.IncludeDirectory, dir.Name()); i == -1 {
+			e.decide(true, SkipReasonIncludeDirectory, strings.Join(f.cfg.IncludeDirectory, ","), "", 0)
+		} else {
+			e.decide(false, SkipReasonIncludeDirectory, f.cfg.IncludeDirectory[i], "", 0)
+		}
+	}
+	// Confirm if there are any files in the path deny list which usually includes
+	// things like .git .hg and .svn
+	// Comes after include as it takes precedence
+	for _, deny := range cfg.ExcludeDirectory {
+		if f.isSuffixDir(joined, deny) {
+			e.decide(true, SkipReasonExcludeDirectory, deny, "", 0)
+			break
+		}
+	}
+
+	if len(f.cfg.IncludeDirectoryRegex) != 0 {
+		i := slices.IndexFunc(f.cfg.IncludeDirectoryRegex, func(allow *regexp.Regexp) bool {
+			return allow.MatchString(dir.Name())
+		})
+		if i == -1 {
+			e.decide(true, SkipReasonIncludeDirectoryRegex, joinRegexps(f.cfg.IncludeDirectoryRegex), "", 0)
+		} else {
+			e.decide(false, SkipReasonIncludeDirectoryRegex, f.cfg.IncludeDirectoryRegex[i].String(), "", 0)
+		}
+	}
+	// Exclude comes after include as it takes precedence
+	for _, deny := range cfg.ExcludeDirectoryRegex {
+		if deny.MatchString(dir.Name()) {
+			e.decide(true, SkipReasonExcludeDirectoryRegex, deny.String(), "", 0)
+			break
+		}
+	}
+
+	// Ignore hidden directories
+	if !cfg.IncludeHidden {
+		s, err := f.isHidden(dir, directory)
+		if err != nil {
+			if !f.errorsHandler(err) {
+				return false, "", err
+			}
+		}
+
+		if s {
+			e.decide(true, SkipReasonHidden, dir.Name(), "", 0)
+		}
+	}
+
+	for _, p := range f.cfg.LocationExcludePattern {
+		if strings.Contains(joined, p) {
+			e.decide(true, SkipReasonLocationExcludePattern, p, "", 0)
+			break
+		}
+	}
+
+	if len(f.includeGlobs) != 0 || len(f.excludeGlobs) != 0 {
+		rel := relativePath(root, joined)
+		// a directory nothing below which can match is never read, but one which could is
+		// not included as that would override the ignore files for it
+		if len(f.includeGlobs) != 0 && !slices.ContainsFunc(f.includeGlobs, func(allow *glob) bool {
+			return allow.matchBelow(rel)
+		}) {
+			e.decide(true, SkipReasonIncludeGlob, joinGlobs(f.includeGlobs), "", 0)
+		}
+		for _, deny := range f.excludeGlobs {
+			if deny.match(rel) {
+				e.decide(true, SkipReasonExcludeGlob, deny.source, "", 0)
+				break
+			}
+		}
+	}
+
+	applyCustomFilters(&e, f.dirFilters, joined, dir, depth)
+
+	if !e.ignore {
+		reason, err := f.checkSymlink(dir, root, joined, ancestors)
+		if err != nil {
+			if !f.errorsHandler(err) {
+				return false, "", err
+			}
+		}
+		if reason != "" {
+			e.decide(true, reason, "", "", 0)
+		}
+	}
+
+	return e.ignore, e.reason, nil
+}
+
+// joinRegexps returns the supplied regular expressions as a single comma separated string
+func joinRegexps(regexps []*regexp.Regexp) string {
+	s := make([]string, 0, len(regexps))
+	for _, r := range regexps {
+		s = append(s, r.String())
+	}
+	return strings.Join(s, ",")
+}
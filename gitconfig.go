@@ -0,0 +1,318 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxGitConfigIncludeDepth matches the limit git itself applies to nested include directives
+const maxGitConfigIncludeDepth = 10
+
+// gitConfig holds the subset of git configuration the walker cares about while
+// the configuration files are being parsed
+type gitConfig struct {
+	home          string
+	gitDir        string
+	excludesFile  string
+	excludesFound bool
+}
+
+// findGitExcludesFile determines the path to the global excludes file for the repository
+// at repoRoot the same way git does. It reads the XDG, global and repository git config
+// files in increasing order of precedence, following include and includeIf sections, and
+// if core.excludesFile is not set in any of them falls back to $XDG_CONFIG_HOME/git/ignore
+// or $HOME/.config/git/ignore. Returns an empty string if no location can be determined.
+func findGitExcludesFile(repoRoot string) string {
+	home, _ := os.UserHomeDir()
+
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" && home != "" {
+		xdg = filepath.Join(home, ".config")
+	}
+
+	c := &gitConfig{
+		home:   home,
+		gitDir: resolveGitDir(repoRoot),
+	}
+
+	configFiles := []string{}
+	if xdg != "" {
+		configFiles = append(configFiles, filepath.Join(xdg, "git", "config"))
+	}
+	if home != "" {
+		configFiles = append(configFiles, filepath.Join(home, ".gitconfig"))
+	}
+	if c.gitDir != "" {
+		configFiles = append(configFiles, filepath.Join(c.gitDir, "config"))
+	}
+
+	for _, configFile := range configFiles {
+		c.parseFile(configFile, 0)
+	}
+
+	if c.excludesFound {
+		return c.excludesFile
+	}
+
+	if xdg != "" {
+		return filepath.Join(xdg, "git", "ignore")
+	}
+	return ""
+}
+
+// resolveGitDir returns the git directory for the repository at repoRoot following
+// the "gitdir: " indirection used by worktrees and submodules, or an empty string
+// if there is no git repository at repoRoot
+func resolveGitDir(repoRoot string) string {
+	abs, err := filepath.Abs(repoRoot)
+	if err != nil {
+		return ""
+	}
+
+	gitDir := filepath.Join(abs, ".git")
+	stat, err := os.Stat(gitDir)
+	if err != nil {
+		return ""
+	}
+	if stat.IsDir() {
+		return gitDir
+	}
+
+	content, err := os.ReadFile(gitDir)
+	if err != nil {
+		return ""
+	}
+	target, ok := strings.CutPrefix(strings.TrimSpace(string(content)), "gitdir:")
+	if !ok {
+		return ""
+	}
+	target = strings.TrimSpace(target)
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(abs, target)
+	}
+	return filepath.Clean(target)
+}
+
+// parseFile reads a single git config file applying any values we care about
+// and recursing into included files. Missing or unreadable files are skipped
+// which is the same as git does for include paths that do not exist.
+func (c *gitConfig) parseFile(configFile string, depth int) {
+	if depth > maxGitConfigIncludeDepth {
+		return
+	}
+
+	content, err := os.ReadFile(configFile)
+	if err != nil {
+		return
+	}
+
+	section := ""
+	subsection := ""
+	for _, line := range joinGitConfigContinuations(string(content)) {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' {
+			section, subsection, line = parseGitConfigSection(line)
+			line = strings.TrimSpace(line)
+			if line == "" || line[0] == '#' || line[0] == ';' {
+				continue
+			}
+		}
+
+		key, value := parseGitConfigKeyValue(line)
+
+		switch {
+		case section == "core" && subsection == "" && key == "excludesfile":
+			c.excludesFile = c.expandPath(value, configFile)
+			c.excludesFound = true
+		case section == "include" && subsection == "" && key == "path":
+			c.parseFile(c.expandPath(value, configFile), depth+1)
+		case section == "includeif" && key == "path":
+			if c.includeIfMatches(subsection, configFile) {
+				c.parseFile(c.expandPath(value, configFile), depth+1)
+			}
+		}
+	}
+}
+
+// includeIfMatches evaluates the condition of an includeIf section. Only the
+// gitdir and gitdir/i conditions and onbranch are supported, anything else
+// such as hasconfig is treated as not matching
+func (c *gitConfig) includeIfMatches(condition string, configFile string) bool {
+	switch {
+	case strings.HasPrefix(condition, "gitdir:"):
+		return c.gitDirMatches(strings.TrimPrefix(condition, "gitdir:"), configFile, false)
+	case strings.HasPrefix(condition, "gitdir/i:"):
+		return c.gitDirMatches(strings.TrimPrefix(condition, "gitdir/i:"), configFile, true)
+	case strings.HasPrefix(condition, "onbranch:"):
+		return c.onBranchMatches(strings.TrimPrefix(condition, "onbranch:"))
+	}
+	return false
+}
+
+// gitDirMatches implements the gitdir: includeIf condition
+// https://git-scm.com/docs/git-config#_conditional_includes
+func (c *gitConfig) gitDirMatches(pattern string, configFile string, caseInsensitive bool) bool {
+	if c.gitDir == "" || pattern == "" {
+		return false
+	}
+
+	// a trailing slash means everything inside, which expanding the path would lose
+	trailingSlash := strings.HasSuffix(pattern, "/")
+
+	switch {
+	case strings.HasPrefix(pattern, "~/"), strings.HasPrefix(pattern, "./"):
+		pattern = c.expandPath(strings.TrimPrefix(pattern, "./"), configFile)
+	case !filepath.IsAbs(pattern):
+		pattern = "**/" + pattern
+	}
+
+	pattern = strings.TrimSuffix(filepath.ToSlash(pattern), "/")
+	if trailingSlash {
+		pattern += "/**"
+	}
+
+	// compiling removes the leading / from an absolute pattern, so it is removed from the path as well
+	g, err := compileGlob(pattern, caseInsensitive)
+	if err != nil {
+		return false
+	}
+	return g.match(strings.TrimPrefix(filepath.ToSlash(c.gitDir), "/"))
+}
+
+// onBranchMatches implements the onbranch: includeIf condition by reading HEAD
+func (c *gitConfig) onBranchMatches(pattern string) bool {
+	if c.gitDir == "" || pattern == "" {
+		return false
+	}
+
+	content, err := os.ReadFile(filepath.Join(c.gitDir, "HEAD"))
+	if err != nil {
+		return false
+	}
+	branch, ok := strings.CutPrefix(strings.TrimSpace(string(content)), "ref: refs/heads/")
+	if !ok {
+		return false
+	}
+
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	g, err := compileGlob(pattern, false)
+	if err != nil {
+		return false
+	}
+	return g.match(branch)
+}
+
+// expandPath expands a leading ~/ to the home directory and resolves relative
+// paths against the directory of the config file they were found in
+func (c *gitConfig) expandPath(value string, configFile string) string {
+	if value == "~" {
+		return c.home
+	}
+	if strings.HasPrefix(value, "~/") {
+		return filepath.Join(c.home, value[2:])
+	}
+	if !filepath.IsAbs(value) {
+		return filepath.Join(filepath.Dir(configFile), value)
+	}
+	return value
+}
+
+// joinGitConfigContinuations splits the config into logical lines joining any
+// line ending in a backslash with the line following it
+func joinGitConfigContinuations(content string) []string {
+	lines := []string{}
+	current := ""
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		if strings.HasSuffix(line, "\\") && !strings.HasSuffix(line, "\\\\") {
+			current += strings.TrimSuffix(line, "\\")
+			continue
+		}
+		lines = append(lines, current+line)
+		current = ""
+	}
+	if current != "" {
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+// parseGitConfigSection parses a section header such as [core], [includeIf "gitdir:~/work/"]
+// or the deprecated [section.subsection] form. Section names are case-insensitive and
+// returned lowercased, subsections are case-sensitive. Anything after the closing bracket
+// is returned so a key on the same line as the header can still be processed.
+func parseGitConfigSection(line string) (string, string, string) {
+	end := strings.Index(line, "]")
+	if end == -1 {
+		return "", "", ""
+	}
+	header := strings.TrimSpace(line[1:end])
+	rest := line[end+1:]
+
+	if i := strings.IndexAny(header, " \t"); i != -1 {
+		section := strings.ToLower(header[:i])
+		subsection := strings.TrimSpace(header[i:])
+		subsection = strings.TrimSuffix(strings.TrimPrefix(subsection, "\""), "\"")
+		subsection = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(subsection)
+		return section, subsection, rest
+	}
+
+	if section, subsection, ok := strings.Cut(header, "."); ok {
+		return strings.ToLower(section), strings.ToLower(subsection), rest
+	}
+
+	return strings.ToLower(header), "", rest
+}
+
+// parseGitConfigKeyValue parses a key = value line returning the lowercased key
+// and the value with quotes, escapes and trailing comments handled. A key with
+// no value is a boolean true in git config
+func parseGitConfigKeyValue(line string) (string, string) {
+	key, raw, found := strings.Cut(line, "=")
+	key = strings.ToLower(strings.TrimSpace(key))
+	if !found {
+		return key, "true"
+	}
+
+	var sb strings.Builder
+	inQuotes := false
+	raw = strings.TrimSpace(raw)
+	for i := 0; i < len(raw); i++ {
+		ch := raw[i]
+		switch {
+		case ch == '\\' && i+1 < len(raw):
+			i++
+			switch raw[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'b':
+				// backspace removes the previous character
+				s := sb.String()
+				if len(s) > 0 {
+					sb.Reset()
+					sb.WriteString(s[:len(s)-1])
+				}
+			default:
+				sb.WriteByte(raw[i])
+			}
+		case ch == '"':
+			inQuotes = !inQuotes
+		case (ch == '#' || ch == ';') && !inQuotes:
+			return key, strings.TrimSpace(sb.String())
+		default:
+			sb.WriteByte(ch)
+		}
+	}
+
+	return key, strings.TrimSpace(sb.String())
+}
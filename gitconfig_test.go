@@ -0,0 +1,252 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setupGitHome points HOME and XDG_CONFIG_HOME at fresh temporary directories
+// so tests never read the git configuration of whoever is running them
+func setupGitHome(t *testing.T) (string, string) {
+	t.Helper()
+	home := t.TempDir()
+	xdg := filepath.Join(t.TempDir(), "xdg")
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	return home, xdg
+}
+
+// makeRepo creates an empty git repository layout in a new temporary directory
+func makeRepo(t *testing.T) string {
+	t.Helper()
+	repo := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(repo, ".git", "HEAD"), "ref: refs/heads/main\n")
+	return repo
+}
+
+func TestFindGitExcludesFileDefaultsToXDG(t *testing.T) {
+	_, xdg := setupGitHome(t)
+	repo := makeRepo(t)
+
+	got := findGitExcludesFile(repo)
+	if want := filepath.Join(xdg, "git", "ignore"); got != want {
+		t.Errorf("expected %q got %q", want, got)
+	}
+}
+
+func TestFindGitExcludesFileFromGitconfig(t *testing.T) {
+	home, _ := setupGitHome(t)
+	repo := makeRepo(t)
+	writeFile(t, filepath.Join(home, ".gitconfig"), "[core]\n\texcludesFile = ~/.gitignore_global ; comment\n")
+
+	got := findGitExcludesFile(repo)
+	if want := filepath.Join(home, ".gitignore_global"); got != want {
+		t.Errorf("expected %q got %q", want, got)
+	}
+}
+
+func TestFindGitExcludesFilePrecedence(t *testing.T) {
+	home, xdg := setupGitHome(t)
+	repo := makeRepo(t)
+	writeFile(t, filepath.Join(xdg, "git", "config"), "[core]\nexcludesfile = /from/xdg\n")
+	writeFile(t, filepath.Join(home, ".gitconfig"), "[core]\nexcludesfile = /from/home\n")
+	writeFile(t, filepath.Join(repo, ".git", "config"), "[core]\n\tbare = false\n")
+
+	if got := findGitExcludesFile(repo); got != filepath.FromSlash("/from/home") {
+		t.Errorf("expected ~/.gitconfig to override XDG config got %q", got)
+	}
+
+	writeFile(t, filepath.Join(repo, ".git", "config"), "[CORE]\nExcludesFile = \"/from/repo\"\n")
+	if got := findGitExcludesFile(repo); got != filepath.FromSlash("/from/repo") {
+		t.Errorf("expected repository config to override global config got %q", got)
+	}
+}
+
+func TestFindGitExcludesFileInclude(t *testing.T) {
+	home, _ := setupGitHome(t)
+	repo := makeRepo(t)
+	writeFile(t, filepath.Join(home, ".gitconfig"), "[include]\n\tpath = extra/config\n")
+	writeFile(t, filepath.Join(home, "extra", "config"), "[core]\n\texcludesFile = relative.ignore\n")
+
+	got := findGitExcludesFile(repo)
+	if want := filepath.Join(home, "extra", "relative.ignore"); got != want {
+		t.Errorf("expected %q got %q", want, got)
+	}
+}
+
+func TestFindGitExcludesFileIncludeIf(t *testing.T) {
+	home, _ := setupGitHome(t)
+	repo := makeRepo(t)
+	writeFile(t, filepath.Join(home, "work.config"), "[core]\n\texcludesFile = /from/work\n")
+	writeFile(t, filepath.Join(home, "branch.config"), "[core]\n\texcludesFile = /from/branch\n")
+
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{
+			name:   "gitdir matches repository",
+			config: "[includeIf \"gitdir:" + filepath.ToSlash(repo) + "/\"]\n\tpath = work.config\n",
+			want:   "/from/work",
+		},
+		{
+			name:   "gitdir relative pattern matches anywhere",
+			config: "[includeIf \"gitdir:" + filepath.Base(repo) + "/\"]\n\tpath = work.config\n",
+			want:   "/from/work",
+		},
+		{
+			name:   "gitdir does not match",
+			config: "[core]\nexcludesFile = /from/home\n[includeIf \"gitdir:/somewhere/else/\"]\n\tpath = work.config\n",
+			want:   "/from/home",
+		},
+		{
+			name:   "gitdir double star matches any directories",
+			config: "[includeIf \"gitdir:/**/" + filepath.Base(repo) + "/**\"]\n\tpath = work.config\n",
+			want:   "/from/work",
+		},
+		{
+			name:   "gitdir/i ignores case",
+			config: "[includeIf \"gitdir/i:" + strings.ToUpper(filepath.ToSlash(repo)) + "/\"]\n\tpath = work.config\n",
+			want:   "/from/work",
+		},
+		{
+			name:   "gitdir is case sensitive",
+			config: "[core]\nexcludesFile = /from/home\n[includeIf \"gitdir:" + strings.ToUpper(filepath.ToSlash(repo)) + "/\"]\n\tpath = work.config\n",
+			want:   "/from/home",
+		},
+		{
+			name:   "onbranch matches",
+			config: "[includeIf \"onbranch:main\"]\n\tpath = branch.config\n",
+			want:   "/from/branch",
+		},
+		{
+			name:   "onbranch glob matches",
+			config: "[includeIf \"onbranch:m?i*\"]\n\tpath = branch.config\n",
+			want:   "/from/branch",
+		},
+		{
+			name:   "unsupported condition ignored",
+			config: "[core]\nexcludesFile = /from/home\n[includeIf \"hasconfig:remote.*.url:https://example.com/**\"]\n\tpath = work.config\n",
+			want:   "/from/home",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeFile(t, filepath.Join(home, ".gitconfig"), tt.config)
+			if got := findGitExcludesFile(repo); got != filepath.FromSlash(tt.want) {
+				t.Errorf("expected %q got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseGitConfigKeyValue(t *testing.T) {
+	tests := []struct {
+		line  string
+		key   string
+		value string
+	}{
+		{"excludesFile = ~/.gitignore", "excludesfile", "~/.gitignore"},
+		{"excludesFile=\"with space.ignore\"", "excludesfile", "with space.ignore"},
+		{"excludesFile = value # comment", "excludesfile", "value"},
+		{"excludesFile = \"semi;colon\" ; comment", "excludesfile", "semi;colon"},
+		{"excludesFile = escaped\\\"quote", "excludesfile", "escaped\"quote"},
+		{"bare", "bare", "true"},
+	}
+
+	for _, tt := range tests {
+		key, value := parseGitConfigKeyValue(tt.line)
+		if key != tt.key || value != tt.value {
+			t.Errorf("parseGitConfigKeyValue(%q) = %q, %q want %q, %q", tt.line, key, value, tt.key, tt.value)
+		}
+	}
+}
+
+func TestRespectGlobalGitIgnore(t *testing.T) {
+	home, _ := setupGitHome(t)
+	repo := makeRepo(t)
+	writeFile(t, filepath.Join(home, ".gitconfig"), "[core]\n\texcludesFile = ~/global.ignore\n")
+	writeFile(t, filepath.Join(home, "global.ignore"), "*.log\n/root.txt\n*.keep\n")
+	writeFile(t, filepath.Join(repo, ".gitignore"), "!important.keep\n")
+	writeFile(t, filepath.Join(repo, "main.go"), "")
+	writeFile(t, filepath.Join(repo, "debug.log"), "")
+	writeFile(t, filepath.Join(repo, "root.txt"), "")
+	writeFile(t, filepath.Join(repo, "sub", "root.txt"), "")
+	writeFile(t, filepath.Join(repo, "sub", "other.keep"), "")
+	writeFile(t, filepath.Join(repo, "sub", "important.keep"), "")
+
+	walk := func(respect bool) (map[string]bool, map[string]SkipReason) {
+		fileListQueue := make(chan *File, 100)
+		walker := NewFileWalker(filepath.Join(repo, "."), fileListQueue)
+		walker.RespectGlobalGitIgnore = respect
+		skipped := map[string]SkipReason{}
+		walker.SetSkipHandler(func(path string, name string, isDir bool, reason SkipReason) {
+			rel, _ := filepath.Rel(repo, filepath.FromSlash(path))
+			skipped[filepath.ToSlash(rel)] = reason
+		})
+		if err := walker.Start(); err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]bool{}
+		for f := range fileListQueue {
+			rel, _ := filepath.Rel(repo, filepath.FromSlash(f.Location))
+			got[filepath.ToSlash(rel)] = true
+		}
+		return got, skipped
+	}
+
+	got, _ := walk(false)
+	if !got["debug.log"] || !got["root.txt"] {
+		t.Errorf("expected global excludes to be ignored when not enabled got %v", got)
+	}
+
+	got, skipped := walk(true)
+	if got["debug.log"] || skipped["debug.log"] != SkipReasonGitGlobalIgnore {
+		t.Errorf("expected debug.log to be skipped with %s got %v", SkipReasonGitGlobalIgnore, skipped)
+	}
+	if got["root.txt"] {
+		t.Errorf("expected root.txt to be ignored by root anchored /root.txt")
+	}
+	if !got["sub/root.txt"] {
+		t.Errorf("expected sub/root.txt to be emitted as /root.txt is anchored at the repository root")
+	}
+	if got["sub/other.keep"] {
+		t.Errorf("expected sub/other.keep to be ignored")
+	}
+	if !got["sub/important.keep"] {
+		t.Errorf("expected .gitignore negation to override the lower priority global excludes")
+	}
+}
+
+func TestRespectGlobalGitIgnoreDisabledWithIgnoreGitIgnore(t *testing.T) {
+	_, xdg := setupGitHome(t)
+	repo := makeRepo(t)
+	writeFile(t, filepath.Join(xdg, "git", "ignore"), "*.log\n")
+	writeFile(t, filepath.Join(repo, "debug.log"), "")
+
+	fileListQueue := make(chan *File, 100)
+	walker := NewFileWalker(repo, fileListQueue)
+	walker.RespectGlobalGitIgnore = true
+
+	walker.IgnoreGitIgnore = true
+	if err := walker.Start(); err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for range fileListQueue {
+		count++
+	}
+	if count != 1 {
+		t.Errorf("expected debug.log to be emitted when IgnoreGitIgnore is set got %d files", count)
+	}
+}
@@ -0,0 +1,238 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// glob is a compiled IncludeGlobs or ExcludeGlobs pattern, matched against slash separated
+// paths relative to the root being walked, or a git config includeIf condition. Braces are expanded when compiled so each of the
+// patterns is split into its segments, where a segment of ** matches any number of directories
+// and any other is matched against a single path element as path.Match does
+type glob struct {
+	source   string
+	patterns [][]string
+	fold     bool
+}
+
+// compileGlob compiles the supplied pattern, returning an error if it is malformed.
+// When ignoring case the pattern is lowercased, as is every path matched against it
+func compileGlob(pattern string, ignoreCase bool) (*glob, error) {
+	g := &glob{source: pattern, fold: ignoreCase}
+
+	trimmed := strings.TrimPrefix(strings.TrimPrefix(pattern, "./"), "/")
+	if ignoreCase {
+		trimmed = strings.ToLower(trimmed)
+	}
+	for _, expanded := range expandBraces(trimmed) {
+		segments := []string{}
+		for _, segment := range strings.Split(expanded, "/") {
+			if segment == "**" {
+				// consecutive ** match the same as one
+				if len(segments) == 0 || segments[len(segments)-1] != "**" {
+					segments = append(segments, segment)
+				}
+				continue
+			}
+
+			segment = negateClasses(segment)
+			if _, err := path.Match(segment, ""); err != nil {
+				return nil, fmt.Errorf("glob %q: %w", pattern, err)
+			}
+			segments = append(segments, segment)
+		}
+		g.patterns = append(g.patterns, segments)
+	}
+
+	return g, nil
+}
+
+// compileGlobs compiles each of the supplied patterns
+func compileGlobs(patterns []string, ignoreCase bool) ([]*glob, error) {
+	globs := make([]*glob, 0, len(patterns))
+	for _, pattern := range patterns {
+		g, err := compileGlob(pattern, ignoreCase)
+		if err != nil {
+			return nil, err
+		}
+		globs = append(globs, g)
+	}
+	return globs, nil
+}
+
+// match returns true if the slash separated path relative to the root matches the glob
+func (g *glob) match(rel string) bool {
+	segments := g.segments(rel)
+	return slices.ContainsFunc(g.patterns, func(pattern []string) bool {
+		return matchSegments(pattern, segments, false)
+	})
+}
+
+// matchBelow returns true if anything below the directory, as a slash separated path relative
+// to the root, could match the glob. When false there is no need to read the directory
+func (g *glob) matchBelow(rel string) bool {
+	segments := g.segments(rel)
+	return slices.ContainsFunc(g.patterns, func(pattern []string) bool {
+		return matchSegments(pattern, segments, true)
+	})
+}
+
+// segments splits the path into the segments matched against the patterns
+func (g *glob) segments(rel string) []string {
+	if g.fold {
+		rel = strings.ToLower(rel)
+	}
+	return strings.Split(rel, "/")
+}
+
+// matchSegments matches the pattern against the path, one segment at a time. With below set
+// it instead returns true if the path could be the directory of something the pattern matches
+func matchSegments(pattern []string, segments []string, below bool) bool {
+	for len(pattern) != 0 {
+		if pattern[0] == "**" {
+			if below {
+				return true
+			}
+			for i := range len(segments) + 1 {
+				if matchSegments(pattern[1:], segments[i:], false) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return below
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+
+	return len(segments) == 0 && !below
+}
+
+// expandBraces returns every pattern described by the braces in the supplied pattern, such
+// that *.{ts,tsx} returns *.ts and *.tsx. Braces can be nested, and unmatched braces along
+// with any escaped or within a character class are left as they are
+func expandBraces(pattern string) []string {
+	depth, start := 0, -1
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '[':
+			if depth == 0 {
+				if end := classEnd(pattern, i); end != -1 {
+					i = end
+				}
+			}
+		case '{':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth != 0 {
+				continue
+			}
+
+			expanded := []string{}
+			for _, alternative := range splitAlternatives(pattern[start+1 : i]) {
+				expanded = append(expanded, expandBraces(pattern[:start]+alternative+pattern[i+1:])...)
+			}
+			return expanded
+		}
+	}
+	return []string{pattern}
+}
+
+// splitAlternatives splits the contents of a pair of braces on the commas outside of any nested braces
+func splitAlternatives(s string) []string {
+	alternatives := []string{}
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				alternatives = append(alternatives, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(alternatives, s[start:])
+}
+
+// classEnd returns the index of the ] closing the character class opening at start, or -1 if it is not closed
+func classEnd(s string, start int) int {
+	i := start + 1
+	if i < len(s) && (s[i] == '^' || s[i] == '!') {
+		i++
+	}
+	// a ] straight after the opening is part of the class
+	if i < len(s) && s[i] == ']' {
+		i++
+	}
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ']':
+			return i
+		}
+	}
+	return -1
+}
+
+// negateClasses rewrites character classes negated with [!...], as is usual in globs, to the [^...] path.Match expects
+func negateClasses(segment string) string {
+	b := []byte(segment)
+	for i := 0; i < len(b); i++ {
+		switch b[i] {
+		case '\\':
+			i++
+		case '[':
+			if i+1 < len(b) && b[i+1] == '!' {
+				b[i+1] = '^'
+			}
+			if end := classEnd(segment, i); end != -1 {
+				i = end
+			}
+		}
+	}
+	return string(b)
+}
+
+// relativePath returns the slash separated path of joined relative to the root being walked
+func relativePath(root string, joined string) string {
+	r := strings.TrimSuffix(filepath.ToSlash(filepath.Clean(root)), "/")
+	if r == "." {
+		return joined
+	}
+	return strings.TrimPrefix(joined, r+"/")
+}
+
+// joinGlobs returns the source of the supplied globs as a single comma separated string
+func joinGlobs(globs []*glob) string {
+	s := make([]string, 0, len(globs))
+	for _, g := range globs {
+		s = append(s, g.source)
+	}
+	return strings.Join(s, ",")
+}
@@ -0,0 +1,186 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"io/fs"
+	"slices"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func TestGlobMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "pkg/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "pkg/sub/main.go", true},
+		{"src/**/*.{ts,tsx}", "src/app.tsx", true},
+		{"src/**/*.{ts,tsx}", "src/components/ui/button.ts", true},
+		{"src/**/*.{ts,tsx}", "src/components/button.js", false},
+		{"src/**/*.{ts,tsx}", "lib/app.ts", false},
+		{"{src,lib}/*.{go,r{s,b}}", "lib/main.rb", true},
+		{"{src,lib}/*.{go,r{s,b}}", "lib/main.rc", false},
+		{"**/test?/*", "a/tests/x", true},
+		{"**/[a-c]*.go", "pkg/b.go", true},
+		{"**/[!a-c]*.go", "pkg/b.go", false},
+		{"**/[!a-c]*.go", "pkg/d.go", true},
+		{"**/[^a-c]*.go", "pkg/d.go", true},
+		{"vendor/**", "vendor", true},
+		{"vendor/**", "vendor/a/b.go", true},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/**/**/b", "a/x/b", true},
+		{"/docs/*.md", "docs/readme.md", true},
+		{"./docs/*.md", "docs/readme.md", true},
+		{`\{a\}.go`, "{a}.go", true},
+		{`\{a\}.go`, "a.go", false},
+		{"[{]*", "{x", true},
+	}
+
+	for _, tc := range cases {
+		g, err := compileGlob(tc.pattern, false)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.pattern, err)
+		}
+		if got := g.match(tc.path); got != tc.match {
+			t.Errorf("expected %s matching %s to be %v got %v", tc.pattern, tc.path, tc.match, got)
+		}
+	}
+}
+
+func TestGlobMatchBelow(t *testing.T) {
+	cases := []struct {
+		pattern string
+		dir     string
+		below   bool
+	}{
+		{"*.go", "pkg", false},
+		{"**/*.go", "pkg/sub", true},
+		{"src/**/*.ts", "src", true},
+		{"src/**/*.ts", "src/a/b", true},
+		{"src/**/*.ts", "lib", false},
+		{"src/app/*.ts", "src", true},
+		{"src/app/*.ts", "src/app", true},
+		{"src/app/*.ts", "src/app/sub", false},
+		{"{src,lib}/*.go", "lib", true},
+		{"{src,lib}/*.go", "cmd", false},
+	}
+
+	for _, tc := range cases {
+		g, err := compileGlob(tc.pattern, false)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.pattern, err)
+		}
+		if got := g.matchBelow(tc.dir); got != tc.below {
+			t.Errorf("expected %s below %s to be %v got %v", tc.pattern, tc.dir, tc.below, got)
+		}
+	}
+}
+
+func TestGlobInvalid(t *testing.T) {
+	for _, pattern := range []string{"[a-", "src/[", `a\`} {
+		if _, err := compileGlob(pattern, false); err == nil {
+			t.Errorf("expected %q to be invalid", pattern)
+		}
+	}
+}
+
+func TestGlobWalk(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.go":                 &fstest.MapFile{Data: []byte("package main")},
+		"README.md":               &fstest.MapFile{Data: []byte("readme")},
+		"src/app.ts":              &fstest.MapFile{Data: []byte("app")},
+		"src/app.test.ts":         &fstest.MapFile{Data: []byte("test")},
+		"src/components/ui.tsx":   &fstest.MapFile{Data: []byte("ui")},
+		"src/components/ui.css":   &fstest.MapFile{Data: []byte("css")},
+		"src/generated/api.ts":    &fstest.MapFile{Data: []byte("api")},
+		"docs/guide/intro.md":     &fstest.MapFile{Data: []byte("intro")},
+		"node_modules/lib/lib.ts": &fstest.MapFile{Data: []byte("lib")},
+	}
+
+	cases := []struct {
+		name     string
+		opts     []Option
+		expected []string
+		read     []string
+	}{
+		{
+			"include prunes",
+			[]Option{WithIncludeGlobs("src/**/*.{ts,tsx}")},
+			[]string{"src/app.test.ts", "src/app.ts", "src/components/ui.tsx", "src/generated/api.ts"},
+			[]string{".", "src", "src/components", "src/generated"},
+		},
+		{
+			"exclude directory and file",
+			[]Option{WithIncludeGlobs("src/**/*.{ts,tsx}"), WithExcludeGlobs("**/generated", "**/*.test.*")},
+			[]string{"src/app.ts", "src/components/ui.tsx"},
+			[]string{".", "src", "src/components"},
+		},
+		{
+			"exclude only",
+			[]Option{WithExcludeGlobs("{src,node_modules}/**", "*.md")},
+			[]string{"docs/guide/intro.md", "main.go"},
+			[]string{".", "docs", "docs/guide"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var mutex sync.Mutex
+			read := []string{}
+			files, _ := walkFS(t, readDirRecorder{fsys, &mutex, &read}, tc.opts...)
+
+			if !slices.Equal(locations(files), tc.expected) {
+				t.Errorf("expected %v got %v", tc.expected, locations(files))
+			}
+			slices.Sort(read)
+			if !slices.Equal(read, tc.read) {
+				t.Errorf("expected only %v read got %v", tc.read, read)
+			}
+		})
+	}
+}
+
+func TestGlobExplain(t *testing.T) {
+	fsys := fstest.MapFS{
+		"src/app.ts": &fstest.MapFile{Data: []byte("app")},
+		"lib/lib.ts": &fstest.MapFile{Data: []byte("lib")},
+	}
+	walker := NewFileWalkerFS(fsys, ".", nil, WithIncludeGlobs("src/**/*.ts"))
+
+	explanation, err := walker.Explain("lib/lib.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !explanation.Ignored || explanation.Reason != SkipReasonIncludeGlob || explanation.Ancestor == nil {
+		t.Errorf("expected lib pruned by the include glob got %v", explanation)
+	}
+
+	explanation, err = walker.Explain("src/app.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if explanation.Ignored || len(explanation.Decisions) != 1 || explanation.Decisions[0].Pattern != "src/**/*.ts" {
+		t.Errorf("expected src/app.ts included by the glob got %v", explanation)
+	}
+}
+
+// readDirRecorder is an fs.FS which records every directory read
+type readDirRecorder struct {
+	fstest.MapFS
+	mutex *sync.Mutex
+	read  *[]string
+}
+
+func (r readDirRecorder) ReadDir(name string) ([]fs.DirEntry, error) {
+	r.mutex.Lock()
+	*r.read = append(*r.read, name)
+	r.mutex.Unlock()
+	return r.MapFS.ReadDir(name)
+}
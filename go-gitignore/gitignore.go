@@ -84,6 +84,19 @@ func New(r io.Reader, base string, errors func(Error) bool) GitIgnore {
 	return &ignore{_base: base, _pattern: _patterns, _errors: _errors}
 } // New()
 
+// NewIgnoreCase is the same as New except the patterns match paths
+// regardless of case, as git does when core.ignoreCase is set.
+func NewIgnoreCase(r io.Reader, base string, errors func(Error) bool) GitIgnore {
+	_ignore := New(r, base, errors).(*ignore)
+	for _, _pattern := range _ignore._pattern {
+		if _p, _ok := _pattern.(interface{ ignoreCase() }); _ok {
+			_p.ignoreCase()
+		}
+	}
+
+	return _ignore
+} // NewIgnoreCase()
+
 // NewFromFile creates a GitIgnore instance from the given file. An error
 // will be returned if file cannot be opened or its absolute path determined.
 func NewFromFile(file string) (GitIgnore, error) {
@@ -28,6 +28,7 @@ type pattern struct {
 	_string    string
 	_fnmatch   string
 	_position  Position
+	_fold      bool
 } // pattern()
 
 // matchType classifies name patterns for fast-path matching.
@@ -150,6 +151,19 @@ func (p *pattern) Position() Position { return p._position }
 // String returns the string representation of the pattern.
 func (p *pattern) String() string { return p._string }
 
+// ignoreCase makes the pattern match regardless of case, as git does when
+// core.ignoreCase is set.
+func (p *pattern) ignoreCase() { p._fold = true }
+
+// flags returns the fnmatch flags, adding FNM_CASEFOLD if the pattern
+// ignores case.
+func (p *pattern) flags(flags int) int {
+	if p._fold {
+		flags |= fnmatch.FNM_CASEFOLD
+	}
+	return flags
+} // flags()
+
 //
 // name patterns
 //      - designed to match trailing file/directory names only
@@ -182,6 +196,13 @@ func (p *pattern) name(tokens []*Token) Pattern {
 	return n
 } // name()
 
+// ignoreCase makes the name pattern match regardless of case, lowering the
+// literal used by the suffix fast-path so only the target needs lowering.
+func (n *name) ignoreCase() {
+	n._fold = true
+	n._literal = strings.ToLower(n._literal)
+} // ignoreCase()
+
 // Match returns true if the given path matches the name pattern. If the
 // pattern is meant for directories only, and the path is not a directory,
 // Match will return false. The matching is performed by fnmatch(). It
@@ -208,11 +229,17 @@ func (n *name) Match(path string, isdir bool) bool {
 	// fast-path dispatch avoids expensive fnmatch for simple patterns
 	switch n._matchType {
 	case matchExact:
+		if n._fold {
+			return strings.EqualFold(_target, n._literal)
+		}
 		return _target == n._literal
 	case matchSuffix:
+		if n._fold {
+			return strings.HasSuffix(strings.ToLower(_target), n._literal)
+		}
 		return strings.HasSuffix(_target, n._literal)
 	default:
-		return fnmatch.Match(n._fnmatch, _target, 0)
+		return fnmatch.Match(n._fnmatch, _target, n.flags(0))
 	}
 } // Match()
 
@@ -247,7 +274,7 @@ func (p *path) Match(path string, isdir bool) bool {
 		return false
 	}
 
-	return fnmatch.Match(p._fnmatch, path, fnmatch.FNM_PATHNAME)
+	return fnmatch.Match(p._fnmatch, path, p.flags(fnmatch.FNM_PATHNAME))
 } // Match()
 
 //
@@ -312,7 +339,7 @@ func (a *any) match(path []string, tokens []*Token) bool {
 			// if the current path element matches this token,
 			// we match if the remainder of the path matches the
 			// remaining tokens
-			if fnmatch.Match(_token.Token(), path[0], fnmatch.FNM_PATHNAME) {
+			if fnmatch.Match(_token.Token(), path[0], a.flags(fnmatch.FNM_PATHNAME)) {
 				return a.match(path[1:], tokens[1:])
 			}
 		}
@@ -514,3 +514,29 @@ func TestAllMatchPatternsViaRelative(t *testing.T) {
 		}
 	}
 }
+
+// TestIgnoreCase checks NewIgnoreCase matches every kind of pattern
+// regardless of case while New remains case sensitive.
+func TestIgnoreCase(t *testing.T) {
+	tests := []nameMatchTest{
+		{"node_modules", "Node_Modules", true, true},
+		{"*.LOG", "debug.log", false, true},
+		{"*.log", "DEBUG.LOG", false, true},
+		{"[a-c]*.Txt", "B.tXT", false, true},
+		{"build/Output", "BUILD/output", true, true},
+		{"**/Temp/**", "a/TEMP/b", false, true},
+		{"Makefile", "makefile.bak", false, false},
+	}
+
+	for _, tc := range tests {
+		_sensitive := gitignore.New(bytes.NewBufferString(tc.pattern+"\n"), "/base", nil)
+		if _sensitive.Relative(tc.path, tc.isdir) != nil {
+			t.Errorf("pattern %q, path %q: expected no match when case sensitive", tc.pattern, tc.path)
+		}
+
+		_ignore := gitignore.NewIgnoreCase(bytes.NewBufferString(tc.pattern+"\n"), "/base", nil)
+		if got := _ignore.Relative(tc.path, tc.isdir) != nil; got != tc.match {
+			t.Errorf("pattern %q, path %q: expected match=%v ignoring case, got %v", tc.pattern, tc.path, tc.match, got)
+		}
+	}
+}
@@ -2,9 +2,6 @@ module github.com/boyter/gocodewalker
 
 go 1.23.0
 
-require (
-	github.com/danwakefield/fnmatch v0.0.0-20160403171240-cbb64ac3d964
-	golang.org/x/sync v0.12.0
-)
+require github.com/danwakefield/fnmatch v0.0.0-20160403171240-cbb64ac3d964
 
 retract v1.2.0
@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"context"
+	"iter"
+)
+
+// Option configures a FileWalker before it starts walking
+type Option func(f *FileWalker)
+
+// Walk walks the supplied directory returning every file which passes all exclusion rules
+// as an iterator, configured by the supplied options. If walking fails the error is returned
+// as the final value, with errors passed to the error handler and not stopping the walk never
+// being returned. Breaking out of the loop stops walking, and all goroutines have finished
+// by the time the loop exits. Any sink set through WithSink is replaced by the iterator.
+//
+//	for f, err := range gocodewalker.Walk(".") {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(f.Location)
+//	}
+func Walk(directory string, opts ...Option) iter.Seq2[*File, error] {
+	return walkSeq(func(fileListQueue chan<- *File) *FileWalker {
+		return NewFileWalker(directory, fileListQueue)
+	}, opts)
+}
+
+// WalkParallel is the same as Walk but walks the supplied directories in parallel with the
+// results intermixed, unless Sorted is set in which case they are returned in the order supplied
+func WalkParallel(directories []string, opts ...Option) iter.Seq2[*File, error] {
+	return walkSeq(func(fileListQueue chan<- *File) *FileWalker {
+		return NewParallelFileWalker(directories, fileListQueue)
+	}, opts)
+}
+
+// walkSeq returns an iterator over a new walker created for each time it is ranged over
+func walkSeq(newWalker func(fileListQueue chan<- *File) *FileWalker, opts []Option) iter.Seq2[*File, error] {
+	return func(yield func(*File, error) bool) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		fileListQueue := make(chan *File, 100)
+		walker := newWalker(fileListQueue)
+		for _, opt := range opts {
+			opt(walker)
+		}
+		// the iterator is where files go, so a sink set by the options would leave it waiting forever
+		walker.SetSink(ChanSink(fileListQueue))
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- walker.StartContext(ctx)
+		}()
+
+		for f := range fileListQueue {
+			if !yield(f, nil) {
+				// stop walking and wait for it to finish so nothing is left running
+				cancel()
+				for range fileListQueue {
+				}
+				<-errs
+				return
+			}
+		}
+
+		if err := <-errs; err != nil {
+			yield(nil, err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestWalk(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a.go"), "")
+	writeFile(t, filepath.Join(root, "b.md"), "")
+	writeFile(t, filepath.Join(root, "pkg", "c.go"), "")
+
+	var files []string
+	for f, err := range Walk(root, func(f *FileWalker) {
+		f.AllowListExtensions = []string{"go"}
+	}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f.Filename)
+	}
+	sort.Strings(files)
+
+	if !slices.Equal(files, []string{"a.go", "c.go"}) {
+		t.Errorf("expected a.go and c.go got %v", files)
+	}
+}
+
+func TestWalkParallel(t *testing.T) {
+	first := t.TempDir()
+	second := t.TempDir()
+	writeFile(t, filepath.Join(first, "a.go"), "")
+	writeFile(t, filepath.Join(second, "b.go"), "")
+
+	var files []string
+	for f, err := range WalkParallel([]string{second, first}, func(f *FileWalker) {
+		f.Sorted = true
+	}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f.Filename)
+	}
+
+	if !slices.Equal(files, []string{"b.go", "a.go"}) {
+		t.Errorf("expected b.go then a.go got %v", files)
+	}
+}
+
+func TestWalkReturnsError(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "missing")
+
+	var walkErr error
+	for _, err := range Walk(root, func(f *FileWalker) {
+		f.SetErrorHandler(func(err error) bool {
+			return false
+		})
+	}) {
+		walkErr = err
+	}
+
+	if !errors.Is(walkErr, os.ErrNotExist) {
+		t.Errorf("expected not exist error got %v", walkErr)
+	}
+}
+
+func TestWalkBreakStopsWalking(t *testing.T) {
+	root := makeContextTree(t)
+	before := runtime.NumGoroutine()
+
+	count := 0
+	for range Walk(root) {
+		count++
+		if count == 5 {
+			break
+		}
+	}
+
+	if count != 5 {
+		t.Errorf("expected 5 files got %d", count)
+	}
+
+	// goroutines which have finished can take a moment to be removed from the count
+	for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("expected no goroutines left running got %d more", after-before)
+	}
+}
+
+func TestWalkWithSink(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a.go"), "")
+	writeFile(t, filepath.Join(root, "pkg", "b.go"), "")
+
+	sink := &SliceSink{}
+	done := make(chan []string, 1)
+	go func() {
+		var files []string
+		for f, err := range Walk(root, WithSink(sink)) {
+			if err != nil {
+				t.Error(err)
+			}
+			files = append(files, f.Filename)
+		}
+		done <- files
+	}()
+
+	select {
+	case files := <-done:
+		sort.Strings(files)
+		if !slices.Equal(files, []string{"a.go", "b.go"}) {
+			t.Errorf("expected a.go and b.go from the iterator got %v", files)
+		}
+		if len(sink.Files) != 0 {
+			t.Errorf("expected the iterator to replace the sink got %d files in it", len(sink.Files))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("iterator did not finish when a sink was set")
+	}
+}
@@ -0,0 +1,272 @@
+// SPDX-License-Identifier: MIT
+
+// Package language classifies files into the programming language they are written in, using
+// their name where it identifies a language and otherwise the first line or so of their content.
+// It is used by gocodewalker for IncludeLanguages and DetectLanguages but has no dependency on it,
+// so can be used to classify files found any other way.
+package language
+
+import (
+	"path"
+	"slices"
+	"strings"
+)
+
+// HeadBytes is how much of a file is needed to find a shebang or modeline, which are only
+// looked for in the first lines of a file
+const HeadBytes = 1024
+
+// Language is a language along with everything used to identify files written in it
+type Language struct {
+	Name         string   // The name of the language, such as Go or C++
+	Extensions   []string // Lowercase extensions without the leading dot
+	Filenames    []string // Names which identify the language whatever their extension, such as Makefile, matched regardless of case
+	Interpreters []string // Interpreters named by a shebang line, such as python3, with any version number removed
+	Modes        []string // Lowercase names used for the language in vim and emacs modelines, other than the lowercase name itself
+}
+
+// Languages is every language which can be detected. No extension, filename or interpreter is
+// listed against more than one language, so where they are shared such as .h the most common wins
+var Languages = []Language{
+	{Name: "Assembly", Extensions: []string{"asm", "s"}, Modes: []string{"nasm", "gas"}},
+	{Name: "Batch", Extensions: []string{"bat", "cmd"}, Modes: []string{"dosbatch", "bat"}},
+	{Name: "C", Extensions: []string{"c", "h"}},
+	{Name: "C#", Extensions: []string{"cs", "csx"}, Modes: []string{"cs", "csharp"}},
+	{Name: "C++", Extensions: []string{"cpp", "cc", "cxx", "c++", "hpp", "hh", "hxx", "h++", "ipp", "tpp"}, Modes: []string{"cpp"}},
+	{Name: "Clojure", Extensions: []string{"clj", "cljs", "cljc", "edn"}},
+	{Name: "CMake", Extensions: []string{"cmake"}, Filenames: []string{"CMakeLists.txt"}},
+	{Name: "CSS", Extensions: []string{"css"}},
+	{Name: "CSV", Extensions: []string{"csv"}},
+	{Name: "Dart", Extensions: []string{"dart"}},
+	{Name: "Dockerfile", Extensions: []string{"dockerfile"}, Filenames: []string{"Dockerfile", "Containerfile"}},
+	{Name: "Elixir", Extensions: []string{"ex", "exs"}, Interpreters: []string{"elixir"}},
+	{Name: "Erlang", Extensions: []string{"erl", "hrl"}, Filenames: []string{"rebar.config"}, Interpreters: []string{"escript"}},
+	{Name: "F#", Extensions: []string{"fs", "fsi", "fsx"}, Modes: []string{"fsharp"}},
+	{Name: "Go", Extensions: []string{"go"}, Modes: []string{"golang"}},
+	{Name: "Go Module", Filenames: []string{"go.mod", "go.sum", "go.work"}},
+	{Name: "Gradle", Extensions: []string{"gradle"}},
+	{Name: "GraphQL", Extensions: []string{"graphql", "gql"}},
+	{Name: "Groovy", Extensions: []string{"groovy"}, Filenames: []string{"Jenkinsfile"}, Interpreters: []string{"groovy"}},
+	{Name: "Haskell", Extensions: []string{"hs", "lhs"}, Interpreters: []string{"runhaskell"}},
+	{Name: "HCL", Extensions: []string{"hcl", "tf", "tfvars"}, Modes: []string{"terraform"}},
+	{Name: "HTML", Extensions: []string{"html", "htm", "xhtml"}},
+	{Name: "INI", Extensions: []string{"ini", "cfg"}, Filenames: []string{".editorconfig", ".gitconfig"}, Modes: []string{"dosini"}},
+	{Name: "Java", Extensions: []string{"java"}},
+	{Name: "JavaScript", Extensions: []string{"js", "mjs", "cjs", "jsx"}, Interpreters: []string{"node", "nodejs"}, Modes: []string{"js"}},
+	{Name: "JSON", Extensions: []string{"json", "jsonc", "json5"}, Filenames: []string{".eslintrc", ".babelrc"}},
+	{Name: "Julia", Extensions: []string{"jl"}, Interpreters: []string{"julia"}},
+	{Name: "Kotlin", Extensions: []string{"kt", "kts"}},
+	{Name: "LESS", Extensions: []string{"less"}},
+	{Name: "License", Filenames: []string{"LICENSE", "LICENCE", "COPYING", "LICENSE.txt", "LICENSE.md"}},
+	{Name: "Lisp", Extensions: []string{"lisp", "lsp", "cl", "el"}, Interpreters: []string{"sbcl", "clisp"}, Modes: []string{"emacs-lisp", "common-lisp"}},
+	{Name: "Lua", Extensions: []string{"lua"}, Interpreters: []string{"lua", "luajit"}},
+	{Name: "Makefile", Extensions: []string{"mk", "mak"}, Filenames: []string{"Makefile", "GNUmakefile"}, Interpreters: []string{"make"}, Modes: []string{"make"}},
+	{Name: "Markdown", Extensions: []string{"md", "markdown", "mdx"}},
+	{Name: "Nix", Extensions: []string{"nix"}},
+	{Name: "Objective-C", Extensions: []string{"m", "mm"}, Modes: []string{"objc", "objcpp"}},
+	{Name: "OCaml", Extensions: []string{"ml", "mli"}, Interpreters: []string{"ocaml"}},
+	{Name: "Perl", Extensions: []string{"pl", "pm", "t"}, Interpreters: []string{"perl"}},
+	{Name: "PHP", Extensions: []string{"php", "phtml"}, Interpreters: []string{"php"}},
+	{Name: "PowerShell", Extensions: []string{"ps1", "psm1", "psd1"}, Interpreters: []string{"pwsh", "powershell"}, Modes: []string{"ps1"}},
+	{Name: "Protocol Buffers", Extensions: []string{"proto"}, Modes: []string{"proto", "protobuf"}},
+	{Name: "Python", Extensions: []string{"py", "pyi", "pyw"}, Filenames: []string{"SConstruct", "SConscript"}, Interpreters: []string{"python", "pypy"}},
+	{Name: "R", Extensions: []string{"r"}, Filenames: []string{".Rprofile"}, Interpreters: []string{"Rscript"}},
+	{Name: "Ruby", Extensions: []string{"rb", "rake", "gemspec"}, Filenames: []string{"Gemfile", "Rakefile", "Vagrantfile", "Podfile"}, Interpreters: []string{"ruby", "jruby"}},
+	{Name: "Rust", Extensions: []string{"rs"}},
+	{Name: "Sass", Extensions: []string{"sass", "scss"}, Modes: []string{"scss"}},
+	{Name: "Scala", Extensions: []string{"scala", "sc", "sbt"}, Interpreters: []string{"scala"}},
+	{Name: "Shell", Extensions: []string{"sh", "bash", "zsh", "ksh", "fish"}, Filenames: []string{".bashrc", ".bash_profile", ".zshrc", ".profile"}, Interpreters: []string{"sh", "bash", "zsh", "ksh", "dash", "ash", "fish"}, Modes: []string{"sh", "bash", "zsh", "shell-script"}},
+	{Name: "SQL", Extensions: []string{"sql"}, Modes: []string{"plsql", "mysql"}},
+	{Name: "Swift", Extensions: []string{"swift"}},
+	{Name: "Tcl", Extensions: []string{"tcl"}, Interpreters: []string{"tclsh", "wish"}},
+	{Name: "TOML", Extensions: []string{"toml"}, Filenames: []string{"Cargo.lock", "Pipfile"}},
+	{Name: "TypeScript", Extensions: []string{"ts", "tsx", "mts", "cts"}, Interpreters: []string{"deno", "ts-node"}, Modes: []string{"typescriptreact"}},
+	{Name: "Vim Script", Extensions: []string{"vim"}, Filenames: []string{".vimrc", "_vimrc"}, Modes: []string{"vim"}},
+	{Name: "Vue", Extensions: []string{"vue"}},
+	{Name: "XML", Extensions: []string{"xml", "xsd", "xsl", "xslt", "svg", "plist", "csproj"}},
+	{Name: "YAML", Extensions: []string{"yaml", "yml"}, Filenames: []string{".clang-format"}},
+	{Name: "Zig", Extensions: []string{"zig"}},
+}
+
+// indexes built from Languages, which is expected not to change once something is detected
+var (
+	byExtension   = map[string]string{}
+	byFilename    = map[string]string{}
+	byInterpreter = map[string]string{}
+	byMode        = map[string]string{}
+	byName        = map[string]string{}
+)
+
+func init() {
+	for _, l := range Languages {
+		byName[strings.ToLower(l.Name)] = l.Name
+		byMode[strings.ToLower(l.Name)] = l.Name
+		for _, ext := range l.Extensions {
+			byExtension[ext] = l.Name
+		}
+		for _, name := range l.Filenames {
+			byFilename[strings.ToLower(name)] = l.Name
+		}
+		for _, interpreter := range l.Interpreters {
+			byInterpreter[interpreter] = l.Name
+		}
+		for _, mode := range l.Modes {
+			byMode[mode] = l.Name
+		}
+	}
+}
+
+// Lookup returns the name of the language as listed in Languages, matching regardless
+// of case so that go returns Go, and false if there is no such language
+func Lookup(name string) (string, bool) {
+	l, ok := byName[strings.ToLower(name)]
+	return l, ok
+}
+
+// Names returns the name of every language which can be detected, sorted
+func Names() []string {
+	names := make([]string, 0, len(Languages))
+	for _, l := range Languages {
+		names = append(names, l.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// FromFilename returns the language identified by the name of the file, checking for names such as
+// Makefile or Dockerfile before the extension, or an empty string if the name does not identify one.
+// Only the last element of the path is used
+func FromFilename(filename string) string {
+	name := strings.ToLower(path.Base(strings.ReplaceAll(filename, "\\", "/")))
+	if l, ok := byFilename[name]; ok {
+		return l
+	}
+
+	if ext := path.Ext(name); len(ext) > 1 && ext != name {
+		if l, ok := byExtension[ext[1:]]; ok {
+			return l
+		}
+	}
+
+	// names such as Dockerfile.dev or Makefile.linux are still what they start
+	// with, where the extension is not a known one as LICENSE.go is Go
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		return byFilename[name[:i]]
+	}
+	return ""
+}
+
+// FromShebang returns the language of the interpreter named by a #! on the first line of the
+// content, following /usr/bin/env, or an empty string if there is none or it is not known
+func FromShebang(head []byte) string {
+	line, ok := strings.CutPrefix(firstLine(head), "#!")
+	if !ok {
+		return ""
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	interpreter := path.Base(fields[0])
+	if interpreter == "env" {
+		interpreter = ""
+		for _, field := range fields[1:] {
+			// skip the options and variables env is passed, such as -S or PATH=...
+			if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") {
+				interpreter = path.Base(field)
+				break
+			}
+		}
+	}
+
+	if l, ok := byInterpreter[interpreter]; ok {
+		return l
+	}
+	// python3.12 is python
+	return byInterpreter[strings.TrimRight(interpreter, "0123456789.")]
+}
+
+// FromModeline returns the language set by a vim or emacs modeline in the first lines of the
+// content, such as "vim: set ft=python:" or "-*- mode: ruby -*-", or an empty string if there is none
+func FromModeline(head []byte) string {
+	lines := strings.SplitN(string(head), "\n", 6)
+	if len(lines) > 5 {
+		lines = lines[:5]
+	}
+
+	for _, line := range lines {
+		if mode := emacsMode(line); mode != "" {
+			return byMode[mode]
+		}
+		if mode := vimMode(line); mode != "" {
+			return byMode[mode]
+		}
+	}
+	return ""
+}
+
+// Detect returns the language of the file from its name, or if the name does not identify one from a
+// modeline or shebang in the head of its content, which should be its first HeadBytes bytes. Returns
+// an empty string if the language cannot be detected.
+func Detect(filename string, head []byte) string {
+	if l := FromFilename(filename); l != "" {
+		return l
+	}
+	if l := FromModeline(head); l != "" {
+		return l
+	}
+	return FromShebang(head)
+}
+
+// firstLine returns the first line of the content without any line ending
+func firstLine(head []byte) string {
+	line, _, _ := strings.Cut(string(head), "\n")
+	return strings.TrimSuffix(line, "\r")
+}
+
+// emacsMode returns the lowercase mode from an emacs -*- -*- line, which is either just
+// the mode such as -*- python -*- or variables such as -*- mode: python; tab-width: 4 -*-
+func emacsMode(line string) string {
+	_, rest, ok := strings.Cut(line, "-*-")
+	if !ok {
+		return ""
+	}
+	vars, _, ok := strings.Cut(rest, "-*-")
+	if !ok {
+		return ""
+	}
+
+	if !strings.Contains(vars, ":") {
+		return strings.ToLower(strings.TrimSpace(vars))
+	}
+	for _, v := range strings.Split(vars, ";") {
+		if key, value, ok := strings.Cut(v, ":"); ok && strings.EqualFold(strings.TrimSpace(key), "mode") {
+			return strings.ToLower(strings.TrimSpace(value))
+		}
+	}
+	return ""
+}
+
+// vimMode returns the lowercase filetype from a vim modeline such as vim: set ft=python:
+// or vi: syntax=sh, which must follow the start of the line or whitespace
+func vimMode(line string) string {
+	for _, marker := range []string{"vim:", "vi:", "ex:"} {
+		i := strings.Index(line, marker)
+		if i == -1 || (i > 0 && line[i-1] != ' ' && line[i-1] != '\t') {
+			continue
+		}
+
+		options := strings.FieldsFunc(line[i+len(marker):], func(r rune) bool {
+			return r == ' ' || r == '\t' || r == ':'
+		})
+		for _, option := range options {
+			key, value, ok := strings.Cut(option, "=")
+			if ok && (key == "ft" || key == "filetype" || key == "syntax") {
+				return strings.ToLower(value)
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: MIT
+
+package language
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLanguagesUnique(t *testing.T) {
+	seen := map[string]string{}
+	check := func(kind string, value string, name string) {
+		key := kind + " " + value
+		if other, ok := seen[key]; ok {
+			t.Errorf("%s is listed against both %s and %s", key, other, name)
+		}
+		seen[key] = name
+	}
+
+	for _, l := range Languages {
+		check("name", strings.ToLower(l.Name), l.Name)
+		for _, ext := range l.Extensions {
+			if ext != strings.ToLower(ext) || strings.HasPrefix(ext, ".") {
+				t.Errorf("extension %q of %s must be lowercase without a dot", ext, l.Name)
+			}
+			check("extension", ext, l.Name)
+		}
+		for _, name := range l.Filenames {
+			check("filename", strings.ToLower(name), l.Name)
+		}
+		for _, interpreter := range l.Interpreters {
+			check("interpreter", interpreter, l.Name)
+		}
+	}
+}
+
+func TestFromFilename(t *testing.T) {
+	cases := map[string]string{
+		"main.go":              "Go",
+		"pkg/lib.RS":           "Rust",
+		`src\app.tsx`:          "TypeScript",
+		"Makefile":             "Makefile",
+		"makefile":             "Makefile",
+		"GNUmakefile":          "Makefile",
+		"Dockerfile":           "Dockerfile",
+		"Dockerfile.dev":       "Dockerfile",
+		"build.dockerfile":     "Dockerfile",
+		"CMakeLists.txt":       "CMake",
+		"go.mod":               "Go Module",
+		"LICENSE":              "License",
+		"LICENSE.go":           "Go",
+		".bashrc":              "Shell",
+		"Gemfile":              "Ruby",
+		"script":               "",
+		".gitignore":           "",
+		"archive.unknown":      "",
+		"vendor/Jenkinsfile":   "Groovy",
+		"component.spec.ts":    "TypeScript",
+		"include/header.h":     "C",
+		"include/header.hpp":   "C++",
+		"docs/README.markdown": "Markdown",
+	}
+
+	for filename, expected := range cases {
+		if got := FromFilename(filename); got != expected {
+			t.Errorf("expected %s to be %q got %q", filename, expected, got)
+		}
+	}
+}
+
+func TestFromShebang(t *testing.T) {
+	cases := map[string]string{
+		"#!/bin/sh\necho hi":                    "Shell",
+		"#!/bin/bash -e\n":                      "Shell",
+		"#!/usr/bin/env python3\n":              "Python",
+		"#!/usr/bin/env python3.12\n":           "Python",
+		"#!/usr/bin/env -S node --harmony\n":    "JavaScript",
+		"#!/usr/bin/env PATH=/opt/bin ruby\r\n": "Ruby",
+		"#! /usr/local/bin/perl -w\n":           "Perl",
+		"#!/usr/bin/env Rscript\n":              "R",
+		"#!/usr/bin/env unknown\n":              "",
+		"#!\n":                                  "",
+		"#!/usr/bin/env\n":                      "",
+		"# !/bin/sh\n":                          "",
+		"echo hi\n#!/bin/sh\n":                  "",
+		"":                                      "",
+	}
+
+	for head, expected := range cases {
+		if got := FromShebang([]byte(head)); got != expected {
+			t.Errorf("expected %q to be %q got %q", head, expected, got)
+		}
+	}
+}
+
+func TestFromModeline(t *testing.T) {
+	cases := map[string]string{
+		"# vim: set ft=python:\n":                         "Python",
+		"/* vim: set filetype=cpp : */\n":                 "C++",
+		"// vi: syntax=go\n":                              "Go",
+		"#!/bin/sh\n# vim:ft=zsh:ts=4\n":                  "Shell",
+		";; -*- mode: emacs-lisp; lexical-binding: t -*-": "Lisp",
+		"# -*- ruby -*-\n":                                "Ruby",
+		"# -*- Mode: Python -*-\n":                        "Python",
+		"# -*- coding: utf-8 -*-\n":                       "",
+		"// navim: ft=go\n":                               "",
+		"# vim: ft=unknown\n":                             "",
+		"1\n2\n3\n4\n5\n# vim: ft=python\n":               "",
+	}
+
+	for head, expected := range cases {
+		if got := FromModeline([]byte(head)); got != expected {
+			t.Errorf("expected %q to be %q got %q", head, expected, got)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	cases := []struct {
+		filename string
+		head     string
+		expected string
+	}{
+		{"main.go", "#!/usr/bin/env python\n", "Go"},
+		{"script", "#!/usr/bin/env python\n", "Python"},
+		{"script", "#!/bin/sh\n# vim: ft=bash\n", "Shell"},
+		{"build", "#!/usr/bin/env bash\n# -*- mode: ruby -*-\n", "Ruby"},
+		{"notes", "just some text\n", ""},
+	}
+
+	for _, tc := range cases {
+		if got := Detect(tc.filename, []byte(tc.head)); got != tc.expected {
+			t.Errorf("expected %s with %q to be %q got %q", tc.filename, tc.head, tc.expected, got)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if name, ok := Lookup("golang"); ok {
+		t.Errorf("expected golang not to be a language name got %s", name)
+	}
+	if name, ok := Lookup("c++"); !ok || name != "C++" {
+		t.Errorf("expected c++ to be C++ got %q %v", name, ok)
+	}
+	if names := Names(); len(names) != len(Languages) || names[0] != "Assembly" {
+		t.Errorf("expected every language sorted got %v", names)
+	}
+}
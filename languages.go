@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"io/fs"
+	"slices"
+	"strings"
+
+	"github.com/boyter/gocodewalker/language"
+)
+
+// evaluateLanguage detects the language of the file when DetectLanguages or IncludeLanguages is
+// set, ignoring it if it is not one of the IncludeLanguages. The file is only opened when its name
+// does not identify the language, it is a regular file and it has not already been ignored, in which
+// case its first language.HeadBytes bytes are checked for a modeline or shebang
+func (f *FileWalker) evaluateLanguage(e *evaluation, file fs.DirEntry, joined string, head *fileHead) (string, error) {
+	if !f.detectsLanguages() {
+		return "", nil
+	}
+
+	lang := language.FromFilename(file.Name())
+	if lang == "" && !e.ignore && f.isRegularFile(file, joined) {
+		data, err := f.head(head, joined, language.HeadBytes)
+		if err != nil {
+			return "", err
+		}
+		lang = language.Detect(file.Name(), data)
+	}
+
+	if len(f.cfg.IncludeLanguages) != 0 {
+		included := lang != "" && slices.ContainsFunc(f.cfg.IncludeLanguages, func(allow string) bool {
+			return strings.EqualFold(allow, lang)
+		})
+		if !included {
+			pattern := lang
+			if pattern == "" {
+				pattern = "unknown"
+			}
+			e.decide(true, SkipReasonIncludeLanguage, pattern, "", 0)
+		}
+	}
+
+	return lang, nil
+}
+
+// detectsLanguages returns true if the language of files needs to be detected
+func (f *FileWalker) detectsLanguages() bool {
+	return f.cfg.DetectLanguages || len(f.cfg.IncludeLanguages) != 0
+}
@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"io/fs"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+var languageFS = mapFS(map[string]string{
+	"main.go":           "package main",
+	"lib.rs":            "fn main() {}",
+	"Makefile":          "all:",
+	"Dockerfile":        "FROM scratch",
+	"bin/deploy":        "#!/usr/bin/env bash\necho deploy",
+	"bin/tool":          "#!/usr/bin/env python3\nprint()",
+	"bin/notes":         "some notes",
+	"config/settings":   "# vim: set ft=yaml:\nkey: value",
+	"web/app.tsx":       "export {}",
+	"web/README.md":     "# readme",
+	"vendor/lib/lib.go": "package lib",
+})
+
+func TestDetectLanguages(t *testing.T) {
+	files, _ := walkFS(t, languageFS, func(f *FileWalker) { f.DetectLanguages = true })
+
+	expected := map[string]string{
+		"main.go":           "Go",
+		"lib.rs":            "Rust",
+		"Makefile":          "Makefile",
+		"Dockerfile":        "Dockerfile",
+		"bin/deploy":        "Shell",
+		"bin/tool":          "Python",
+		"bin/notes":         "",
+		"config/settings":   "YAML",
+		"web/app.tsx":       "TypeScript",
+		"web/README.md":     "Markdown",
+		"vendor/lib/lib.go": "Go",
+	}
+	if len(files) != len(expected) {
+		t.Errorf("expected %d files got %d", len(expected), len(files))
+	}
+	for _, f := range files {
+		if lang, ok := expected[f.Location]; !ok || f.Language != lang {
+			t.Errorf("expected %s to be %q got %q", f.Location, lang, f.Language)
+		}
+	}
+}
+
+func TestIncludeLanguages(t *testing.T) {
+	opts := []Option{WithIncludeLanguages("go", "Shell", "Python"), WithExcludeDirectory("vendor")}
+	files, skipped := walkFS(t, languageFS, opts...)
+
+	for _, f := range files {
+		if f.Language == "" {
+			t.Errorf("expected the language set for %s", f.Location)
+		}
+	}
+	if expected := []string{"bin/deploy", "bin/tool", "main.go"}; !slices.Equal(locations(files), expected) {
+		t.Errorf("expected %v got %v", expected, locations(files))
+	}
+	count := 0
+	for _, reason := range skipped {
+		if reason == SkipReasonIncludeLanguage {
+			count++
+		}
+	}
+	if count != 7 || skipped["vendor/lib/lib.go"] != "" {
+		t.Errorf("expected every other file skipped for its language other than those in vendor got %v", skipped)
+	}
+
+	walker := NewFileWalkerFS(languageFS, ".", nil, opts...)
+	explanation, err := walker.Explain("bin/notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !explanation.Ignored || explanation.Reason != SkipReasonIncludeLanguage || explanation.Decisions[0].Pattern != "unknown" {
+		t.Errorf("expected bin/notes ignored as its language is unknown got %v", explanation)
+	}
+}
+
+func TestIncludeLanguagesNotOpened(t *testing.T) {
+	fsys := mapFS(map[string]string{
+		"main.go":     "package main",
+		"script":      "#!/bin/sh\n",
+		"skipped/run": "#!/bin/sh\n",
+	})
+	opened := map[string]int{}
+	walkFS(t, openRecorder{fsys, opened},
+		WithIncludeLanguages("Shell"),
+		WithConcurrency(1),
+		func(f *FileWalker) { f.IncludeFilename = []string{"main.go", "script"} },
+	)
+
+	if opened["script"] != 1 || opened["main.go"] != 0 || opened["skipped/run"] != 0 {
+		t.Errorf("expected only script opened, as main.go is named and run already ignored, got %v", opened)
+	}
+}
+
+func TestLanguagesAndBinaryReadOnce(t *testing.T) {
+	fsys := mapFS(map[string]string{
+		"script": "#!/bin/sh\n",
+		"binary": "\x00\x01",
+	})
+	opened := map[string]int{}
+	files, skipped := walkFS(t, openRecorder{fsys, opened},
+		WithIgnoreBinaryFiles(true),
+		WithConcurrency(1),
+		func(f *FileWalker) { f.DetectLanguages = true },
+	)
+
+	if len(files) != 1 || files[0].Language != "Shell" || skipped["binary"] != SkipReasonBinary {
+		t.Errorf("expected script detected as Shell and binary skipped got %v %v", locations(files), skipped)
+	}
+	if opened["script"] != 1 || opened["binary"] != 1 {
+		t.Errorf("expected each file opened once for both its language and content got %v", opened)
+	}
+}
+
+// openRecorder is an fs.FS which counts every time each file other than a directory is opened
+type openRecorder struct {
+	fstest.MapFS
+	opened map[string]int
+}
+
+func (o openRecorder) Open(name string) (fs.File, error) {
+	if _, ok := o.MapFS[name]; ok {
+		o.opened[name]++
+	}
+	return o.MapFS.Open(name)
+}
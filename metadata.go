@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"io/fs"
+	"slices"
+	"strconv"
+)
+
+// hasMetadataFilters returns true if any filter needs the fs.FileInfo of each file
+func (c *WalkerConfig) hasMetadataFilters() bool {
+	return c.MinSize != 0 || c.MaxSize != 0 ||
+		!c.ModifiedAfter.IsZero() || !c.ModifiedBefore.IsZero() ||
+		c.IncludePermissions != 0 || c.ExcludePermissions != 0 ||
+		len(c.OwnerUIDs) != 0
+}
+
+// evaluateMetadata runs the size, time, permission and owner filters against the file, fetching
+// its info only if one of them is set. Returns the info if it was fetched. An error is only
+// returned when the errorsHandler asks for processing to stop, otherwise the filters are skipped.
+func (f *FileWalker) evaluateMetadata(e *evaluation, file fs.DirEntry) (fs.FileInfo, error) {
+	if !f.cfg.hasMetadataFilters() {
+		return nil, nil
+	}
+
+	info, err := file.Info()
+	if err != nil {
+		if !f.errorsHandler(err) {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	if f.cfg.MinSize != 0 && info.Size() < f.cfg.MinSize {
+		e.decide(true, SkipReasonMinSize, strconv.FormatInt(info.Size(), 10), "", 0)
+	}
+	if f.cfg.MaxSize != 0 && info.Size() > f.cfg.MaxSize {
+		e.decide(true, SkipReasonMaxSize, strconv.FormatInt(info.Size(), 10), "", 0)
+	}
+	if !f.cfg.ModifiedAfter.IsZero() && !info.ModTime().After(f.cfg.ModifiedAfter) {
+		e.decide(true, SkipReasonModifiedAfter, info.ModTime().String(), "", 0)
+	}
+	if !f.cfg.ModifiedBefore.IsZero() && !info.ModTime().Before(f.cfg.ModifiedBefore) {
+		e.decide(true, SkipReasonModifiedBefore, info.ModTime().String(), "", 0)
+	}
+
+	perm := info.Mode().Perm()
+	if f.cfg.IncludePermissions != 0 && perm&f.cfg.IncludePermissions != f.cfg.IncludePermissions {
+		e.decide(true, SkipReasonIncludePermissions, perm.String(), "", 0)
+	}
+	if perm&f.cfg.ExcludePermissions != 0 {
+		e.decide(true, SkipReasonExcludePermissions, perm.String(), "", 0)
+	}
+
+	if len(f.cfg.OwnerUIDs) != 0 {
+		// when the owner cannot be determined, such as on windows or
+		// for most fs.FS, it cannot be one of those allowed
+		uid, ok := fileOwner(info)
+		if !ok {
+			e.decide(true, SkipReasonOwner, "unknown", "", 0)
+		} else if !slices.Contains(f.cfg.OwnerUIDs, uid) {
+			e.decide(true, SkipReasonOwner, strconv.Itoa(uid), "", 0)
+		}
+	}
+
+	return info, nil
+}
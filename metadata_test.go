@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var metadataTime = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+var metadataFS = fstest.MapFS{
+	"small.go":  &fstest.MapFile{Data: make([]byte, 10), Mode: 0o644, ModTime: metadataTime.Add(-48 * time.Hour)},
+	"medium.go": &fstest.MapFile{Data: make([]byte, 100), Mode: 0o755, ModTime: metadataTime},
+	"large.go":  &fstest.MapFile{Data: make([]byte, 1000), Mode: 0o666, ModTime: metadataTime.Add(48 * time.Hour)},
+}
+
+func TestMetadataFilters(t *testing.T) {
+	cases := []struct {
+		name     string
+		opts     []Option
+		expected []string
+		reason   SkipReason
+	}{
+		{"none", nil, []string{"large.go", "medium.go", "small.go"}, ""},
+		{"min size", []Option{WithSizeRange(50, 0)}, []string{"large.go", "medium.go"}, SkipReasonMinSize},
+		{"max size", []Option{WithSizeRange(0, 100)}, []string{"medium.go", "small.go"}, SkipReasonMaxSize},
+		{"modified after", []Option{WithModifiedRange(metadataTime.Add(-time.Hour), time.Time{})}, []string{"large.go", "medium.go"}, SkipReasonModifiedAfter},
+		{"modified before", []Option{WithModifiedRange(time.Time{}, metadataTime.Add(time.Hour))}, []string{"medium.go", "small.go"}, SkipReasonModifiedBefore},
+		{"include permissions", []Option{func(f *FileWalker) { f.IncludePermissions = 0o100 }}, []string{"medium.go"}, SkipReasonIncludePermissions},
+		{"exclude permissions", []Option{func(f *FileWalker) { f.ExcludePermissions = 0o002 }}, []string{"medium.go", "small.go"}, SkipReasonExcludePermissions},
+		{"owner unknown", []Option{WithOwners(os.Getuid())}, nil, SkipReasonOwner},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			files, skipped := walkFS(t, metadataFS, tc.opts...)
+
+			for _, f := range files {
+				if tc.reason != "" && f.info == nil {
+					t.Errorf("expected the info fetched for the filters to be kept for %s", f.Filename)
+				}
+			}
+			if !slices.Equal(locations(files), tc.expected) {
+				t.Errorf("expected %v got %v", tc.expected, locations(files))
+			}
+			for _, reason := range skipped {
+				if reason != tc.reason {
+					t.Errorf("expected skipped with %s got %s", tc.reason, reason)
+				}
+			}
+		})
+	}
+}
+
+func TestMetadataOwner(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "main.go"), "")
+	if _, ok := fileOwner(mustStat(t, filepath.Join(root, "main.go"))); !ok {
+		t.Skip("file owners are not known on this platform")
+	}
+
+	got, _, _ := symlinkWalk(t, root, func(f *FileWalker) {
+		f.OwnerUIDs = []int{os.Getuid()}
+	})
+	if !got["main.go"] {
+		t.Errorf("expected main.go owned by the current user got %v", got)
+	}
+
+	got, skipped, _ := symlinkWalk(t, root, func(f *FileWalker) {
+		f.OwnerUIDs = []int{os.Getuid() + 1}
+	})
+	if len(got) != 0 || skipped["main.go"] != SkipReasonOwner {
+		t.Errorf("expected main.go skipped with %s got %v %v", SkipReasonOwner, got, skipped)
+	}
+}
+
+func mustStat(t *testing.T, location string) os.FileInfo {
+	t.Helper()
+	info, err := os.Stat(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
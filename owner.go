@@ -0,0 +1,18 @@
+// SPDX-License-Identifier: MIT
+//go:build unix
+
+package gocodewalker
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileOwner returns the uid of the user who owns the file, if known
+func fileOwner(info fs.FileInfo) (int, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return int(stat.Uid), true
+}
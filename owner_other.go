@@ -0,0 +1,13 @@
+// SPDX-License-Identifier: MIT
+//go:build !unix
+
+package gocodewalker
+
+import (
+	"io/fs"
+)
+
+// fileOwner returns false as there is no uid which owns a file on this platform
+func fileOwner(info fs.FileInfo) (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,254 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// walkTask is a directory waiting to be walked along with everything inherited from its parent
+type walkTask struct {
+	iteration int
+	root      *walkRootState
+	directory string
+	layers    ignoreLayers
+	ancestors []os.FileInfo
+	dir       *walkDirState // only set with EmitLeaveDirectories, nil at the root
+}
+
+// walkRootState is one of the directories supplied to the walker, which is
+// finished once every directory below it has been walked
+type walkRootState struct {
+	directory string
+	started   time.Time
+	pending   atomic.Int64
+}
+
+// walkDeque holds the directories found by a single worker. The owner takes from the back
+// so it works depth first, keeping the deque small, while other workers steal from the
+// front where the directories are closest to the root and so likely to be the largest.
+type walkDeque struct {
+	mutex sync.Mutex
+	tasks []*walkTask
+}
+
+func (d *walkDeque) push(task *walkTask) {
+	d.mutex.Lock()
+	d.tasks = append(d.tasks, task)
+	d.mutex.Unlock()
+}
+
+func (d *walkDeque) popBack() *walkTask {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if len(d.tasks) == 0 {
+		return nil
+	}
+	task := d.tasks[len(d.tasks)-1]
+	d.tasks[len(d.tasks)-1] = nil
+	d.tasks = d.tasks[:len(d.tasks)-1]
+	return task
+}
+
+func (d *walkDeque) popFront() *walkTask {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if len(d.tasks) == 0 {
+		return nil
+	}
+	task := d.tasks[0]
+	d.tasks[0] = nil
+	d.tasks = d.tasks[1:]
+	return task
+}
+
+// walkPool walks directories at any depth using a fixed number of workers, each with
+// its own deque of directories which the others steal from when they run out. This
+// means a tree with a single huge directory is walked as concurrently as a wide one.
+type walkPool struct {
+	f        *FileWalker
+	emit     func(files []*File) error
+	deques   []*walkDeque
+	queued   atomic.Int64 // directories in the deques
+	pending  atomic.Int64 // directories queued or being walked, the walk is over when it reaches zero
+	sleeping atomic.Int64 // workers waiting for directories, only incremented with the mutex held
+	failed   atomic.Bool
+	mutex    sync.Mutex // held by workers while checking if they should wait
+	cond     *sync.Cond
+	err      error
+}
+
+func newWalkPool(f *FileWalker, workers int, emit func(files []*File) error) *walkPool {
+	p := &walkPool{
+		f:      f,
+		emit:   emit,
+		deques: make([]*walkDeque, workers),
+	}
+	for i := range p.deques {
+		p.deques[i] = &walkDeque{}
+	}
+	p.cond = sync.NewCond(&p.mutex)
+	return p
+}
+
+// walkRootsPooled walks the directories using a pool of Concurrency workers shared between them
+func (f *FileWalker) walkRootsPooled(directories []string, emit func(files []*File) error) error {
+	p := newWalkPool(f, f.concurrency(), emit)
+
+	for i, directory := range directories {
+		root := &walkRootState{directory: directory, started: time.Now()}
+		layers, err := f.buildRootLayers(directory)
+		if err != nil {
+			return err
+		}
+		p.push(i%len(p.deques), &walkTask{root: root, directory: directory, layers: layers})
+	}
+
+	wg := sync.WaitGroup{}
+	for i := range p.deques {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(i)
+		}()
+	}
+	wg.Wait()
+
+	return p.err
+}
+
+// work walks directories until there are none left or walking fails
+func (p *walkPool) work(worker int) {
+	for {
+		task := p.next(worker)
+		if task == nil {
+			return
+		}
+		err := p.f.walkDirectoryPooled(p, worker, task)
+		if err == nil {
+			err = task.dir.finish(p.emit)
+		}
+		if err != nil {
+			p.fail(err)
+		}
+		p.done(task)
+	}
+}
+
+// push queues the directory to be walked on the deque of the worker
+func (p *walkPool) push(worker int, task *walkTask) {
+	task.root.pending.Add(1)
+	p.pending.Add(1)
+	p.deques[worker].push(task)
+	p.queued.Add(1)
+
+	if p.sleeping.Load() != 0 {
+		p.mutex.Lock()
+		p.cond.Signal()
+		p.mutex.Unlock()
+	}
+}
+
+// next returns the next directory for the worker, taking its own most recent or stealing
+// the oldest from another worker, waiting if there are none. Returns nil once every
+// directory has been walked or walking has failed.
+func (p *walkPool) next(worker int) *walkTask {
+	for {
+		if p.failed.Load() {
+			return nil
+		}
+
+		task := p.deques[worker].popBack()
+		for i := 1; task == nil && i < len(p.deques); i++ {
+			task = p.deques[(worker+i)%len(p.deques)].popFront()
+		}
+		if task != nil {
+			p.queued.Add(-1)
+			return task
+		}
+
+		p.mutex.Lock()
+		p.sleeping.Add(1)
+		for p.queued.Load() == 0 && p.pending.Load() != 0 && p.err == nil {
+			p.cond.Wait()
+		}
+		p.sleeping.Add(-1)
+		finished := p.pending.Load() == 0 || p.err != nil
+		p.mutex.Unlock()
+
+		if finished {
+			return nil
+		}
+	}
+}
+
+// done marks the directory as walked, which must be after any directories found in it are queued
+func (p *walkPool) done(task *walkTask) {
+	if task.root.pending.Add(-1) == 0 {
+		p.f.stats.root(task.root.directory, task.root.started)
+	}
+	if p.pending.Add(-1) == 0 {
+		p.mutex.Lock()
+		p.cond.Broadcast()
+		p.mutex.Unlock()
+	}
+}
+
+// fail stops every worker, keeping the first error to be returned
+func (p *walkPool) fail(err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.err == nil {
+		p.err = err
+	}
+	p.failed.Store(true)
+	p.cond.Broadcast()
+}
+
+// walkDirectoryPooled reads the directory sending the files found in it, then queues the
+// directories found in it on the deque of the worker along with the ignore layers they inherit
+func (f *FileWalker) walkDirectoryPooled(p *walkPool, worker int, task *walkTask) error {
+	// implement max depth option
+	if maxDepth := task.layers.config(f).MaxDepth; maxDepth != -1 && task.iteration >= maxDepth {
+		return nil
+	}
+
+	scan, err := f.readDirectory(task.iteration, task.root.directory, task.directory, task.layers, task.ancestors, p.emit)
+	if scan == nil || err != nil {
+		return err
+	}
+
+	if err := p.emit(scan.files); err != nil {
+		return err
+	}
+	dirFiles, err := f.emitDirectories(task.iteration, task.root.directory, task.directory, scan.dirs, p.emit)
+	if err != nil {
+		return err
+	}
+	if task.dir != nil {
+		task.dir.files.Add(scan.returned)
+	}
+
+	// pushed in reverse so the owner, taking from the back, walks them in the order found
+	for i, dir := range slices.Backward(scan.dirs) {
+		var state *walkDirState
+		if f.cfg.EmitLeaveDirectories {
+			state = newWalkDirState(dirFiles[i], task.dir)
+		}
+		p.push(worker, &walkTask{
+			iteration: task.iteration + 1,
+			root:      task.root,
+			directory: filepath.ToSlash(filepath.Join(task.directory, dir.Name())),
+			layers:    scan.layers,
+			ancestors: scan.ancestors,
+			dir:       state,
+		})
+	}
+
+	return f.recordDirectory(task.directory, scan.record)
+}
@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// slowFS records how many directories are being read at once, taking long enough
+// over each that a walk which reads them concurrently will overlap
+type slowFS struct {
+	fstest.MapFS
+	mutex   sync.Mutex
+	reading int
+	most    int
+	fail    string // directory which cannot be read
+}
+
+func (s *slowFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	s.mutex.Lock()
+	s.reading++
+	s.most = max(s.most, s.reading)
+	s.mutex.Unlock()
+
+	time.Sleep(2 * time.Millisecond)
+
+	s.mutex.Lock()
+	s.reading--
+	s.mutex.Unlock()
+	if name == s.fail {
+		return nil, fs.ErrPermission
+	}
+	return s.MapFS.ReadDir(name)
+}
+
+// makeDeepFS creates a tree where everything is below a single directory, so
+// it can only be walked concurrently by reading below the top level in parallel
+func makeDeepFS() *slowFS {
+	fsys := &slowFS{MapFS: fstest.MapFS{}}
+	fsys.MapFS["src/.gitignore"] = &fstest.MapFile{Data: []byte("*.log\n")}
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 4; j++ {
+			fsys.MapFS[fmt.Sprintf("src/pkg%d/sub%d/main.go", i, j)] = &fstest.MapFile{}
+			fsys.MapFS[fmt.Sprintf("src/pkg%d/sub%d/debug.log", i, j)] = &fstest.MapFile{}
+		}
+	}
+	fsys.MapFS["src/pkg0/.gitignore"] = &fstest.MapFile{Data: []byte("sub1/\n")}
+	return fsys
+}
+
+func walkDeepFS(t *testing.T, fsys *slowFS, concurrency int) []string {
+	t.Helper()
+	fileListQueue := make(chan *File, 1000)
+	walker := NewFileWalkerFS(fsys, ".", fileListQueue, WithConcurrency(concurrency))
+	if err := walker.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	var files []string
+	for f := range fileListQueue {
+		files = append(files, f.Location)
+	}
+	slices.Sort(files)
+	return files
+}
+
+func TestPoolWalksBelowTheRootConcurrently(t *testing.T) {
+	fsys := makeDeepFS()
+	files := walkDeepFS(t, fsys, 4)
+
+	if fsys.most < 2 || fsys.most > 4 {
+		t.Errorf("expected between 2 and 4 directories read at once got %d", fsys.most)
+	}
+
+	// ignore files are inherited however the directories are shared between workers
+	if len(files) != 31 || slices.Contains(files, "src/pkg0/sub1/main.go") || slices.ContainsFunc(files, func(f string) bool {
+		return GetExtension(f) == "log"
+	}) {
+		t.Errorf("expected 31 go files without src/pkg0/sub1 got %d %v", len(files), files)
+	}
+}
+
+func TestPoolRespectsConcurrency(t *testing.T) {
+	fsys := makeDeepFS()
+	single := walkDeepFS(t, fsys, 1)
+
+	if fsys.most != 1 {
+		t.Errorf("expected a single directory read at once got %d", fsys.most)
+	}
+	if !slices.Equal(single, walkDeepFS(t, makeDeepFS(), 8)) {
+		t.Errorf("expected the same files whatever the concurrency")
+	}
+}
+
+func TestPoolStopsOnError(t *testing.T) {
+	fsys := makeDeepFS()
+	fsys.fail = "src/pkg3/sub2"
+
+	fileListQueue := make(chan *File, 1000)
+	walker := NewFileWalkerFS(fsys, ".", fileListQueue, WithErrorHandler(func(error) bool { return false }))
+	err := walker.Start()
+	for range fileListQueue {
+	}
+
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("expected the read error to be returned got %v", err)
+	}
+}
+
+func TestNestedDirectoryError(t *testing.T) {
+	for _, sorted := range []bool{false, true} {
+		for _, carryOn := range []bool{true, false} {
+			t.Run(fmt.Sprintf("sorted %v continue %v", sorted, carryOn), func(t *testing.T) {
+				fsys := makeDeepFS()
+				fsys.fail = "src/pkg3/sub2"
+
+				var mutex sync.Mutex
+				var handled []error
+				fileListQueue := make(chan *File, 1000)
+				walker := NewFileWalkerFS(fsys, ".", fileListQueue, WithSorted(sorted), WithErrorHandler(func(err error) bool {
+					mutex.Lock()
+					defer mutex.Unlock()
+					handled = append(handled, err)
+					return carryOn
+				}))
+				err := walker.Start()
+
+				var files []string
+				for f := range fileListQueue {
+					files = append(files, f.Location)
+				}
+
+				if len(handled) != 1 || !errors.Is(handled[0], fs.ErrPermission) {
+					t.Errorf("expected the read error to be handled once got %v", handled)
+				}
+				if carryOn {
+					// only the directory which could not be read is missing
+					if err != nil || len(files) != 30 || slices.Contains(files, "src/pkg3/sub2/main.go") {
+						t.Errorf("expected 30 files without src/pkg3/sub2 got %v %d %v", err, len(files), files)
+					}
+				} else if !errors.Is(err, fs.ErrPermission) {
+					t.Errorf("expected the read error to be returned got %v", err)
+				} else if sorted && (len(files) != 13 || files[len(files)-1] != "src/pkg3/sub1/main.go") {
+					// the whole walk stops, not just the directory which could not be read
+					t.Errorf("expected nothing after src/pkg3/sub2 got %d %v", len(files), files)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strings"
+)
+
+// ProjectConfig is the name of the file which sets walker settings for the directory it is in and below
+const ProjectConfig = ".gocodewalker.json"
+
+// projectSettings is what a project config file sets, where nil means not set
+// and so inherited from the parent directory or the walker. The keys are the
+// same as those used when marshalling WalkerConfig
+type projectSettings struct {
+	AllowListExtensions  *[]string `json:"allow_list_extensions"`
+	ExcludeDirectory     *[]string `json:"exclude_directory"`
+	CustomIgnorePatterns []string  `json:"custom_ignore_patterns"`
+	MaxDepth             *int      `json:"max_depth"`
+	IncludeHidden        *bool     `json:"include_hidden"`
+	IgnoreBinaryFiles    *bool     `json:"ignore_binary_files"`
+}
+
+// apply returns the settings with those set in the project config file replacing them.
+// MaxDepth in the file counts from the directory it is in, which is at the supplied depth
+func (s projectSettings) apply(c WalkerConfig, depth int) WalkerConfig {
+	if s.AllowListExtensions != nil {
+		c.AllowListExtensions = *s.AllowListExtensions
+	}
+	if s.ExcludeDirectory != nil {
+		c.ExcludeDirectory = *s.ExcludeDirectory
+	}
+	if s.MaxDepth != nil {
+		c.MaxDepth = *s.MaxDepth
+		if c.MaxDepth != -1 {
+			c.MaxDepth += depth
+		}
+	}
+	if s.IncludeHidden != nil {
+		c.IncludeHidden = *s.IncludeHidden
+	}
+	if s.IgnoreBinaryFiles != nil {
+		c.IgnoreBinaryFiles = *s.IgnoreBinaryFiles
+	}
+	return c
+}
+
+// config returns the settings which apply in the directory the layers were built
+// for, which are those of the walker unless a project config file has changed them
+func (l ignoreLayers) config(f *FileWalker) *WalkerConfig {
+	if l.project != nil {
+		return l.project
+	}
+	return f.cfg
+}
+
+// loadProjectConfig reads the project config file at location, which is in the supplied directory at
+// the supplied depth, applying its settings on top of those in the layers for this directory and below.
+// Its CustomIgnorePatterns are added the same way an ignore file in the directory is, so unlike those in
+// WalkerConfig which are re-anchored at every directory, a pattern starting with / only matches in this one.
+func (f *FileWalker) loadProjectConfig(location string, directory string, depth int, layers ignoreLayers) (ignoreLayers, error) {
+	c, err := f.readFile(location)
+	if err != nil {
+		if f.errorsHandler(err) {
+			return layers, nil // if asked to ignore it lets continue
+		}
+		return layers, err
+	}
+
+	settings, err := parseProjectConfig(location, c)
+	if err != nil {
+		if f.errorsHandler(err) {
+			return layers, nil
+		}
+		return layers, err
+	}
+
+	abs, err := f.absDir(directory)
+	if err != nil {
+		if f.errorsHandler(err) {
+			return layers, nil
+		}
+		return layers, err
+	}
+
+	project := settings.apply(*layers.config(f), depth)
+	layers.project = &project
+
+	// added even without any patterns so that a change to the file changes the hash of the layers
+	ignore := f.newIgnoreFile([]byte(strings.Join(settings.CustomIgnorePatterns, "\n")), filepath.ToSlash(abs), location)
+	h := fnv.New64a()
+	_, _ = h.Write(c)
+	ignore.hash = h.Sum64()
+	layers.custom = append(layers.custom, ignore)
+
+	return layers, nil
+}
+
+// buildProjectLayers applies the project config at the root of the repository containing the
+// supplied directory when it is above the directory, as one in the directory itself is found when
+// walking. It is applied as if it were in the directory so MaxDepth counts from where walking starts.
+func (f *FileWalker) buildProjectLayers(directory string, layers ignoreLayers) (ignoreLayers, error) {
+	if !f.cfg.RespectProjectConfig || f.fsys != nil {
+		return layers, nil
+	}
+
+	abs, err := filepath.Abs(directory)
+	if err != nil {
+		return layers, nil
+	}
+	repoRoot, err := filepath.Abs(FindRepositoryRoot(directory))
+	if err != nil || repoRoot == abs {
+		return layers, nil
+	}
+
+	location := filepath.Join(repoRoot, ProjectConfig)
+	if _, err := f.stat(location); err != nil {
+		return layers, nil
+	}
+
+	return f.loadProjectConfig(location, repoRoot, 0, layers)
+}
+
+// parseProjectConfig parses a project config file, which is a JSON object holding any of the keys in
+// projectSettings. Unknown keys, values of the wrong type and anything after the object are errors
+func parseProjectConfig(name string, content []byte) (projectSettings, error) {
+	var settings projectSettings
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&settings); err != nil {
+		return settings, fmt.Errorf("%s: %w", name, err)
+	}
+	if decoder.More() {
+		return settings, fmt.Errorf("%s: unexpected content after the settings", name)
+	}
+
+	if settings.MaxDepth != nil && *settings.MaxDepth < -1 {
+		return settings, fmt.Errorf("%s: max_depth must be -1 for no limit or 0 and above got %d", name, *settings.MaxDepth)
+	}
+
+	return settings, nil
+}
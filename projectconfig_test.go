@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// projectWalk walks root with project config files respected returning the slash separated paths found
+func projectWalk(t *testing.T, root string) []string {
+	t.Helper()
+	fileListQueue := make(chan *File, 1000)
+	walker := NewFileWalker(root, fileListQueue)
+	walker.RespectProjectConfig = true
+	walker.SetErrorHandler(func(e error) bool {
+		t.Errorf("unexpected error %v", e)
+		return true
+	})
+
+	if err := walker.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	files := []string{}
+	for f := range fileListQueue {
+		rel, _ := filepath.Rel(root, f.Location)
+		files = append(files, filepath.ToSlash(rel))
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestParseProjectConfig(t *testing.T) {
+	settings, err := parseProjectConfig(ProjectConfig, []byte(`{
+  "allow_list_extensions": ["go", "md"],
+  "exclude_directory": ["vendor", "node_modules"],
+  "custom_ignore_patterns": ["*.gen#.go"],
+  "max_depth": 3,
+  "include_hidden": true,
+  "ignore_binary_files": false
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(*settings.AllowListExtensions, []string{"go", "md"}) {
+		t.Errorf("expected go md got %v", *settings.AllowListExtensions)
+	}
+	if !reflect.DeepEqual(*settings.ExcludeDirectory, []string{"vendor", "node_modules"}) {
+		t.Errorf("expected vendor node_modules got %v", *settings.ExcludeDirectory)
+	}
+	if !reflect.DeepEqual(settings.CustomIgnorePatterns, []string{"*.gen#.go"}) {
+		t.Errorf("expected *.gen#.go got %v", settings.CustomIgnorePatterns)
+	}
+	if *settings.MaxDepth != 3 || !*settings.IncludeHidden || *settings.IgnoreBinaryFiles {
+		t.Errorf("expected scalars to be parsed got %+v", settings)
+	}
+}
+
+func TestParseProjectConfigUnset(t *testing.T) {
+	settings, err := parseProjectConfig(ProjectConfig, []byte(`{"max_depth": -1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *settings.MaxDepth != -1 {
+		t.Errorf("expected -1 got %d", *settings.MaxDepth)
+	}
+	if settings.AllowListExtensions != nil || settings.ExcludeDirectory != nil || settings.IncludeHidden != nil {
+		t.Errorf("expected settings not in the file to be unset got %+v", settings)
+	}
+}
+
+func TestParseProjectConfigErrors(t *testing.T) {
+	cases := map[string]string{
+		"unknown":       `{"allow_list_extensions": ["go"], "exclude_dirs": ["vendor"]}`,
+		"depth":         `{"max_depth": -2}`,
+		"not int":       `{"max_depth": "deep"}`,
+		"quoted bool":   `{"include_hidden": "true"}`,
+		"list":          `{"max_depth": [1, 2]}`,
+		"not a list":    `{"exclude_directory": "vendor"}`,
+		"unclosed":      `{"allow_list_extensions": ["go"`,
+		"not json":      "allow_list_extensions: [go]",
+		"empty":         "",
+		"trailing":      `{"max_depth": 1} {"max_depth": 2}`,
+		"not an object": `["go"]`,
+	}
+
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseProjectConfig(ProjectConfig, []byte(content))
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.HasPrefix(err.Error(), ProjectConfig+":") {
+				t.Errorf("expected error to include the file got %v", err)
+			}
+		})
+	}
+}
+
+func TestProjectConfigOverrides(t *testing.T) {
+	root := makeRepo(t)
+	writeFile(t, filepath.Join(root, ProjectConfig), `{"allow_list_extensions": ["go"], "exclude_directory": ["vendor"]}`)
+	writeFile(t, filepath.Join(root, "main.go"), "package main")
+	writeFile(t, filepath.Join(root, "README.md"), "# readme")
+	writeFile(t, filepath.Join(root, "vendor", "lib.go"), "package lib")
+	writeFile(t, filepath.Join(root, "docs", ProjectConfig), `{"allow_list_extensions": ["md"], "max_depth": 1}`)
+	writeFile(t, filepath.Join(root, "docs", "guide.md"), "# guide")
+	writeFile(t, filepath.Join(root, "docs", "example.go"), "package example")
+	writeFile(t, filepath.Join(root, "docs", "deep", "nested.md"), "# nested")
+	writeFile(t, filepath.Join(root, "pkg", "pkg.go"), "package pkg")
+	writeFile(t, filepath.Join(root, "pkg", "notes.md"), "# notes")
+
+	got := projectWalk(t, root)
+	expected := []string{"docs/guide.md", "main.go", "pkg/pkg.go"}
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected %v got %v", expected, got)
+	}
+}
+
+func TestProjectConfigCustomIgnorePatternsAnchored(t *testing.T) {
+	root := makeRepo(t)
+	writeFile(t, filepath.Join(root, "a", ProjectConfig), `{"custom_ignore_patterns": ["/generated.go"]}`)
+	writeFile(t, filepath.Join(root, "a", "generated.go"), "package a")
+	writeFile(t, filepath.Join(root, "a", "b", "generated.go"), "package b")
+	writeFile(t, filepath.Join(root, "generated.go"), "package root")
+
+	got := projectWalk(t, root)
+	expected := []string{"a/b/generated.go", "generated.go"}
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected %v got %v", expected, got)
+	}
+}
+
+func TestProjectConfigFromRepositoryRoot(t *testing.T) {
+	root := makeRepo(t)
+	writeFile(t, filepath.Join(root, ProjectConfig), `{"allow_list_extensions": ["go"], "max_depth": 1}`)
+	writeFile(t, filepath.Join(root, "src", "main.go"), "package main")
+	writeFile(t, filepath.Join(root, "src", "README.md"), "# readme")
+	writeFile(t, filepath.Join(root, "src", "pkg", "pkg.go"), "package pkg")
+
+	// max_depth counts from where walking starts rather than the repository root
+	got := projectWalk(t, filepath.Join(root, "src"))
+	expected := []string{"main.go"}
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected %v got %v", expected, got)
+	}
+}
+
+func TestProjectConfigNotRespectedByDefault(t *testing.T) {
+	root := makeRepo(t)
+	writeFile(t, filepath.Join(root, ProjectConfig), `{"allow_list_extensions": ["go"]}`)
+	writeFile(t, filepath.Join(root, "main.go"), "package main")
+	writeFile(t, filepath.Join(root, "README.md"), "# readme")
+
+	fileListQueue := make(chan *File, 100)
+	walker := NewFileWalker(root, fileListQueue)
+	if err := walker.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	count := 0
+	for range fileListQueue {
+		count++
+	}
+	if count != 2 {
+		t.Errorf("expected 2 files got %d", count)
+	}
+}
+
+func TestProjectConfigInvalidPassedToErrorHandler(t *testing.T) {
+	root := makeRepo(t)
+	writeFile(t, filepath.Join(root, ProjectConfig), `{"max_depth": "deep"}`)
+	writeFile(t, filepath.Join(root, "main.go"), "package main")
+
+	fileListQueue := make(chan *File, 100)
+	walker := NewFileWalker(root, fileListQueue)
+	walker.RespectProjectConfig = true
+	walker.SetErrorHandler(func(e error) bool {
+		return false
+	})
+
+	err := walker.Start()
+	if err == nil || !strings.Contains(err.Error(), "max_depth") {
+		t.Errorf("expected max_depth error got %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"context"
+	"sync"
+)
+
+// Sink receives the files found while walking, which can be used in place of a channel to avoid
+// the cost of a send per file. Files are passed in batches which are always from a single
+// directory and hold at most BatchSize files, or every file found in the directory if it is 0.
+type Sink interface {
+	// Send receives files which passed every filter. It is called from every goroutine walking
+	// so must be safe for concurrent use, and should return ctx.Err() if the context is done
+	// while it is blocked. Returning an error stops walking with it returned from Start.
+	// The slice is never reused by the walker so can be kept.
+	Send(ctx context.Context, files []*File) error
+	// Close is called once walking has finished and nothing more will be sent
+	Close()
+}
+
+// chanSink sends each file to the channel one at a time, which is how the fileListQueue is fed
+type chanSink struct {
+	queue chan<- *File
+}
+
+// ChanSink returns a Sink which sends each file to the queue, closing it once walking has
+// finished. This is the Sink used for the fileListQueue supplied to NewFileWalker.
+func ChanSink(queue chan<- *File) Sink {
+	return chanSink{queue: queue}
+}
+
+func (s chanSink) Send(ctx context.Context, files []*File) error {
+	for _, file := range files {
+		select {
+		case s.queue <- file:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (s chanSink) Close() {
+	close(s.queue)
+}
+
+// batchChanSink sends each batch to the channel
+type batchChanSink struct {
+	queue chan<- []*File
+}
+
+// BatchChanSink returns a Sink which sends each batch to the queue as a single send,
+// closing it once walking has finished
+func BatchChanSink(queue chan<- []*File) Sink {
+	return batchChanSink{queue: queue}
+}
+
+func (s batchChanSink) Send(ctx context.Context, files []*File) error {
+	select {
+	case s.queue <- files:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s batchChanSink) Close() {
+	close(s.queue)
+}
+
+// funcSink passes each batch to a function one call at a time
+type funcSink struct {
+	mutex sync.Mutex
+	fn    func(files []*File) error
+}
+
+// FuncSink returns a Sink which passes each batch to the supplied function. Calls are never
+// made concurrently so the function needs no locking, although it is called from whichever
+// goroutine found the files. Returning an error stops walking with it returned from Start.
+func FuncSink(fn func(files []*File) error) Sink {
+	return &funcSink{fn: fn}
+}
+
+func (s *funcSink) Send(ctx context.Context, files []*File) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.fn(files)
+}
+
+func (s *funcSink) Close() {}
+
+// SliceSink is a Sink which collects every file into Files, which
+// should only be read once walking has finished
+type SliceSink struct {
+	Files []*File
+	mutex sync.Mutex
+}
+
+func (s *SliceSink) Send(ctx context.Context, files []*File) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Files = append(s.Files, files...)
+	return nil
+}
+
+func (s *SliceSink) Close() {}
+
+// discardSink drops every file, used when there is no fileListQueue or Sink
+type discardSink struct{}
+
+func (discardSink) Send(ctx context.Context, files []*File) error {
+	return nil
+}
+
+func (discardSink) Close() {}
+
+// NewFileWalkerSink constructs a filewalker, which will walk the supplied directory
+// and pass File results to the supplied Sink in batches as it finds them
+func NewFileWalkerSink(directory string, sink Sink, opts ...Option) *FileWalker {
+	f := NewFileWalker(directory, nil)
+	f.SetSink(sink)
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// NewParallelFileWalkerSink constructs a filewalker, which will walk the supplied directories
+// in parallel and pass File results to the supplied Sink in batches as it finds them
+func NewParallelFileWalkerSink(directories []string, sink Sink, opts ...Option) *FileWalker {
+	f := NewParallelFileWalker(directories, nil)
+	f.SetSink(sink)
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// SetSink sets where files are passed to while walking, replacing the fileListQueue
+// supplied to the constructor which is then never sent to or closed
+func (f *FileWalker) SetSink(sink Sink) {
+	f.walkMutex.Lock()
+	defer f.walkMutex.Unlock()
+	if sink != nil {
+		f.sink = sink
+	}
+}
+
+// WithSink sets where files are passed to while walking, see SetSink
+func WithSink(sink Sink) Option {
+	return func(f *FileWalker) {
+		f.SetSink(sink)
+	}
+}
+
+// emit passes the files to the sink in batches of at most BatchSize, giving
+// up if the walk is terminated or the context cancelled while waiting for it
+func (f *FileWalker) emit(files []*File) error {
+	for len(files) != 0 {
+		batch := files
+		if f.cfg.BatchSize > 0 && len(batch) > f.cfg.BatchSize {
+			batch = files[:f.cfg.BatchSize:f.cfg.BatchSize]
+		}
+		files = files[len(batch):]
+
+		if err := f.sink.Send(f.ctx, batch); err != nil {
+			if f.ctx.Err() != nil {
+				return f.interrupted()
+			}
+			return err
+		}
+		f.stats.filesReturned.Add(countType(batch, FileTypeFile))
+		f.stats.dirsReturned.Add(countType(batch, FileTypeDirectory))
+	}
+	return nil
+}
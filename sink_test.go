@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"testing"
+)
+
+// batchFS is a tree of two directories each holding five files
+var batchFS = mapFS(map[string]string{
+	"a/0.go": "", "a/1.go": "", "a/2.go": "", "a/3.go": "", "a/4.go": "",
+	"b/0.go": "", "b/1.go": "", "b/2.go": "", "b/3.go": "", "b/4.go": "",
+})
+
+func TestSinkBatchesPerDirectory(t *testing.T) {
+	for _, sorted := range []bool{false, true} {
+		t.Run(fmt.Sprintf("sorted %v", sorted), func(t *testing.T) {
+			var batches [][]*File
+			sink := FuncSink(func(files []*File) error {
+				batches = append(batches, files)
+				return nil
+			})
+
+			walker := NewFileWalkerFS(batchFS, ".", nil, WithSink(sink), WithSorted(sorted))
+			if err := walker.Start(); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(batches) != 2 {
+				t.Fatalf("expected 2 batches got %d", len(batches))
+			}
+			for _, batch := range batches {
+				if len(batch) != 5 {
+					t.Errorf("expected 5 files in the batch got %d", len(batch))
+				}
+				for _, file := range batch {
+					if path.Dir(file.Location) != path.Dir(batch[0].Location) {
+						t.Errorf("expected every file in the batch from %s got %s", path.Dir(batch[0].Location), file.Location)
+					}
+				}
+			}
+			if walker.Stats().FilesReturned != 10 {
+				t.Errorf("expected 10 files returned got %d", walker.Stats().FilesReturned)
+			}
+		})
+	}
+}
+
+func TestSinkBatchSize(t *testing.T) {
+	for _, sorted := range []bool{false, true} {
+		t.Run(fmt.Sprintf("sorted %v", sorted), func(t *testing.T) {
+			var sizes []int
+			sink := FuncSink(func(files []*File) error {
+				sizes = append(sizes, len(files))
+				return nil
+			})
+
+			walker := NewFileWalkerFS(batchFS, ".", nil, WithSink(sink), WithSorted(sorted), WithBatchSize(2))
+			if err := walker.Start(); err != nil {
+				t.Fatal(err)
+			}
+
+			slices.Sort(sizes)
+			if !slices.Equal(sizes, []int{1, 1, 2, 2, 2, 2}) {
+				t.Errorf("expected batches of at most 2 per directory got %v", sizes)
+			}
+		})
+	}
+}
+
+func TestBatchChanSink(t *testing.T) {
+	queue := make(chan []*File, 10)
+	walker := NewFileWalkerFS(batchFS, ".", nil, WithSink(BatchChanSink(queue)))
+	if err := walker.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	count := 0
+	for batch := range queue {
+		count += len(batch)
+	}
+	if count != 10 {
+		t.Errorf("expected 10 files got %d", count)
+	}
+}
+
+func TestBatchChanSinkCancelled(t *testing.T) {
+	queue := make(chan []*File) // never read so the walk blocks sending
+	walker := NewFileWalkerFS(batchFS, ".", nil, WithSink(BatchChanSink(queue)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- walker.StartContext(ctx)
+	}()
+	cancel()
+
+	err := <-errs
+	if !errors.Is(err, ErrTerminateWalk) || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected ErrTerminateWalk and context.Canceled got %v", err)
+	}
+	if _, ok := <-queue; ok {
+		t.Error("expected the queue to be closed")
+	}
+}
+
+func TestSliceSink(t *testing.T) {
+	sink := &SliceSink{}
+	walker := NewParallelFileWalkerSink([]string{"a", "b"}, sink)
+	walker.fsys = batchFS
+	if err := walker.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sink.Files) != 10 {
+		t.Errorf("expected 10 files got %d", len(sink.Files))
+	}
+}
+
+func TestFuncSinkErrorStopsWalking(t *testing.T) {
+	stop := errors.New("stop")
+	walker := NewFileWalkerSink(".", FuncSink(func(files []*File) error {
+		return stop
+	}))
+	walker.fsys = batchFS
+
+	if err := walker.Start(); !errors.Is(err, stop) {
+		t.Errorf("expected the sink error got %v", err)
+	}
+}
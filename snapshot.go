@@ -0,0 +1,513 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// snapshotVersion is bumped whenever the snapshot format or the meaning of
+// anything stored in it changes, so that old snapshots are never trusted
+const snapshotVersion = 3
+
+// ChangeType is the kind of change made to a file between two walks
+type ChangeType string
+
+const (
+	ChangeAdded    ChangeType = "added"
+	ChangeModified ChangeType = "modified"
+	ChangeRemoved  ChangeType = "removed"
+)
+
+// Change is a file which was added, modified or removed since the previous walk.
+// File is the result returned by this walk and is nil for removed files
+type Change struct {
+	Type     ChangeType
+	Location string
+	File     *File
+}
+
+// snapshot is everything recorded by a walk which allows the next walk to skip
+// reading directories which have not changed, and to work out what has changed
+type snapshot struct {
+	Version     int                           `json:"version"`
+	Settings    uint64                        `json:"settings"`
+	Started     time.Time                     `json:"started"`
+	Directories map[string]*snapshotDirectory `json:"directories"`
+}
+
+// snapshotDirectory is a single directory which was read while walking, keyed in the
+// snapshot by its location. The IgnoreHash covers every ignore file which applied to
+// the directory, including those inherited from its parents
+type snapshotDirectory struct {
+	ModTime     time.Time      `json:"modTime"`
+	IgnoreHash  uint64         `json:"ignoreHash"`
+	IgnoreFiles []string       `json:"ignoreFiles,omitempty"`
+	Files       []snapshotFile `json:"files,omitempty"`
+	Directories []string       `json:"directories,omitempty"`
+	Skipped     []snapshotSkip `json:"skipped,omitempty"`
+}
+
+// snapshotFile is a file which was returned by the walk
+type snapshotFile struct {
+	Name     string      `json:"name"`
+	Mode     fs.FileMode `json:"mode"`
+	Size     int64       `json:"size"`
+	ModTime  time.Time   `json:"modTime"`
+	Content  *Content    `json:"content,omitempty"`
+	Language string      `json:"language,omitempty"`
+	file     *File
+}
+
+// snapshotSkip is a file or directory which was skipped, so that the skip can be reported
+// again when the directory is reused. File is only set for files skipped because of what
+// they contain, which need to be checked for changes before the skip is reported again
+type snapshotSkip struct {
+	Name   string        `json:"name"`
+	Dir    bool          `json:"dir,omitempty"`
+	Reason SkipReason    `json:"reason"`
+	File   *snapshotFile `json:"file,omitempty"`
+}
+
+// snapshotState holds the snapshot loaded at the start of a walk and the one
+// being built as it progresses. Directories are walked concurrently, hence the mutex
+type snapshotState struct {
+	mutex    sync.Mutex
+	previous *snapshot
+	reusable bool // false when the previous snapshot was made with different settings or cannot be trusted with them
+	current  *snapshot
+}
+
+// snapshotEntry is a fs.DirEntry for something recorded in a snapshot, used in
+// place of the entries that would have been returned by reading the directory
+type snapshotEntry struct {
+	name     string
+	location string
+	mode     fs.FileMode
+	info     fs.FileInfo
+	lstat    func(name string) (fs.FileInfo, error)
+}
+
+func (s snapshotEntry) Name() string      { return s.name }
+func (s snapshotEntry) IsDir() bool       { return s.mode.IsDir() }
+func (s snapshotEntry) Type() fs.FileMode { return s.mode.Type() }
+func (s snapshotEntry) Info() (fs.FileInfo, error) {
+	if s.info != nil {
+		return s.info, nil
+	}
+	return s.lstat(s.location)
+}
+
+// SetChangeQueue sets a queue which receives every file added, modified or removed
+// since the walk which wrote the snapshot at SnapshotPath. The changes in a directory are
+// sent as soon as it has been walked, so the queue needs to be read while walking, sorted
+// by location within each directory. Files in directories which are no longer walked are
+// reported as removed once the walk has finished successfully, after which the change queue
+// is closed. Without a previous snapshot every file is reported as added. As the snapshot is
+// only written when a walk succeeds, a walk which fails may report changes which are
+// reported again by the next walk.
+func (f *FileWalker) SetChangeQueue(changeQueue chan<- *Change) {
+	f.walkMutex.Lock()
+	defer f.walkMutex.Unlock()
+	f.changeQueue = changeQueue
+}
+
+// hash combines the hashes of every ignore file in the layers, so that a change
+// to any of them, or to which of them apply, results in a different value
+func (l ignoreLayers) hash() uint64 {
+	h := fnv.New64a()
+	for _, group := range [][]ignoreFile{l.gitGlobal, l.global, l.gitignores, l.ignores, l.modules, l.custom} {
+		for _, ignore := range group {
+			_, _ = fmt.Fprintf(h, "%s\x00%d\x00", ignore.source, ignore.hash)
+		}
+		_, _ = h.Write([]byte{0xff})
+	}
+	return h.Sum64()
+}
+
+// snapshotSettings hashes every setting which changes what a walk returns.
+// A snapshot made with different settings is only used to work out changes
+// and never to skip reading a directory
+func (f *FileWalker) snapshotSettings() uint64 {
+	settings := []any{
+		f.directory, f.directories, f.fsys != nil,
+		f.cfg.LocationExcludePattern,
+		f.cfg.IncludeDirectory, f.cfg.ExcludeDirectory, f.cfg.IncludeFilename, f.cfg.ExcludeFilename,
+		joinRegexps(f.cfg.IncludeDirectoryRegex), joinRegexps(f.cfg.ExcludeDirectoryRegex),
+		joinRegexps(f.cfg.IncludeFilenameRegex), joinRegexps(f.cfg.ExcludeFilenameRegex),
+		f.cfg.IncludeGlobs, f.cfg.ExcludeGlobs, f.cfg.IgnoreCase, f.cfg.IncludeLanguages, f.cfg.DetectLanguages,
+		f.cfg.AllowListExtensions, f.cfg.ExcludeListExtensions,
+		f.cfg.IgnoreIgnoreFile, f.cfg.IgnoreGitIgnore, f.cfg.IgnoreGitModules, f.cfg.RespectGlobalGitIgnore,
+		f.cfg.CustomIgnore, f.cfg.CustomIgnorePatterns, f.cfg.CustomIgnoreFiles,
+		f.cfg.IncludeHidden, f.cfg.MaxDepth, f.cfg.IgnoreBinaryFiles, f.cfg.IgnoreBinaryFileBytes,
+		f.cfg.FollowSymlinks, f.cfg.ConfineSymlinks, f.cfg.RespectProjectConfig, f.cfg.FileTypes,
+		f.cfg.MinSize, f.cfg.MaxSize, f.cfg.ModifiedAfter, f.cfg.ModifiedBefore,
+		f.cfg.IncludePermissions, f.cfg.ExcludePermissions, f.cfg.OwnerUIDs,
+		// a detector is identified by its type and value, so a function by which function it is
+		fmt.Sprintf("%T %v", f.contentDetector, f.contentDetector),
+	}
+
+	h := fnv.New64a()
+	_, _ = fmt.Fprintf(h, "%q", settings)
+	return h.Sum64()
+}
+
+// loadSnapshot reads the snapshot at SnapshotPath, returning nil if there is no
+// SnapshotPath set. A missing snapshot is not an error, and one written by a
+// different version of the walker is treated as missing
+func (f *FileWalker) loadSnapshot() (*snapshotState, error) {
+	if f.cfg.SnapshotPath == "" {
+		return nil, nil
+	}
+
+	settings := f.snapshotSettings()
+	state := &snapshotState{
+		current: &snapshot{
+			Version:     snapshotVersion,
+			Settings:    settings,
+			Started:     time.Now(),
+			Directories: map[string]*snapshotDirectory{},
+		},
+	}
+
+	c, err := os.ReadFile(f.cfg.SnapshotPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || f.errorsHandler(err) {
+			return state, nil
+		}
+		return nil, err
+	}
+
+	var previous snapshot
+	if err := json.Unmarshal(c, &previous); err != nil {
+		err = fmt.Errorf("invalid snapshot %s: %w", f.cfg.SnapshotPath, err)
+		if f.errorsHandler(err) {
+			return state, nil
+		}
+		return nil, err
+	}
+
+	if previous.Version == snapshotVersion {
+		state.previous = &previous
+		// editing a file does not change the modification time of its directory, so with filters on
+		// the size or time of files a directory cannot be trusted to hold the same files as last time,
+		// and custom filters may decide differently from one walk to the next. The decision traces
+		// passed to the skip detail handler are not recorded so cannot be reported from the snapshot
+		state.reusable = previous.Settings == settings && !f.cfg.hasMetadataFilters() &&
+			len(f.fileFilters) == 0 && len(f.dirFilters) == 0 && f.skipDetailHandler == nil
+	}
+
+	return state, nil
+}
+
+// cachedDirectory stats the directory before it is read, returning its info if a
+// snapshot is being recorded, along with the directory from the previous snapshot if
+// it can be reused. A directory can only be reused if its modification time is unchanged
+// and was at least a second before the previous walk started. Otherwise a change made in
+// the same second as the previous walk could be missed where times are only stored to the second
+func (f *FileWalker) cachedDirectory(directory string) (fs.FileInfo, *snapshotDirectory) {
+	if f.snapshot == nil {
+		return nil, nil
+	}
+
+	info, err := f.stat(directory)
+	if err != nil {
+		return nil, nil
+	}
+
+	if !f.snapshot.reusable {
+		return info, nil
+	}
+
+	cached, ok := f.snapshot.previous.Directories[filepath.ToSlash(directory)]
+	if !ok || cached.ModTime.IsZero() || !cached.ModTime.Equal(info.ModTime()) ||
+		!cached.ModTime.Before(f.snapshot.previous.Started.Truncate(time.Second)) {
+		return info, nil
+	}
+
+	return info, cached
+}
+
+// recordDirectory adds the directory to the snapshot being recorded, sending
+// the changes to the files in it to the change queue if there is one
+func (f *FileWalker) recordDirectory(directory string, record *snapshotDirectory) error {
+	if record == nil {
+		return nil
+	}
+
+	location := filepath.ToSlash(directory)
+	f.snapshot.mutex.Lock()
+	f.snapshot.current.Directories[location] = record
+	f.snapshot.mutex.Unlock()
+
+	if f.changeQueue == nil {
+		return nil
+	}
+	return f.sendChanges(f.snapshot.directoryChanges(location, record))
+}
+
+// sendChanges sends the changes to the change queue, stopping if walking is interrupted
+func (f *FileWalker) sendChanges(changes []*Change) error {
+	for _, change := range changes {
+		select {
+		case f.changeQueue <- change:
+		case <-f.ctx.Done():
+			return f.interrupted()
+		}
+	}
+	return nil
+}
+
+// newSnapshotSkip records the skipped entry. Files skipped because of what they contain
+// have their info recorded, so that a change to them means the directory is read again
+func newSnapshotSkip(entry fs.DirEntry, isDir bool, reason SkipReason) snapshotSkip {
+	skip := snapshotSkip{Name: entry.Name(), Dir: isDir, Reason: reason}
+	if !isDir && (reason == SkipReasonBinary || reason == SkipReasonIncludeLanguage) {
+		info, _ := entry.Info()
+		file := newSnapshotFile(info)
+		skip.File = &file
+	}
+	return skip
+}
+
+// isIgnoreFileName returns true if files with the supplied name are loaded as ignore files
+func (f *FileWalker) isIgnoreFileName(name string) bool {
+	return name == GitIgnore || name == Ignore || name == GitModules || slices.Contains(f.cfg.CustomIgnore, name) ||
+		(f.cfg.RespectProjectConfig && name == ProjectConfig)
+}
+
+// cachedScan returns what was recorded for a directory in the previous snapshot rather than
+// reading it, reporting everything which was skipped in it again. It returns nil if the ignore
+// files which apply have changed, or when checking files and any have been modified, in which
+// case the directory needs to be read as normal
+func (f *FileWalker) cachedScan(iteration int,
+	root string,
+	directory string,
+	layers ignoreLayers,
+	ancestors []os.FileInfo,
+	cached *snapshotDirectory) (*directoryScan, error) {
+
+	ignoreFiles := make([]fs.DirEntry, 0, len(cached.IgnoreFiles))
+	for _, name := range cached.IgnoreFiles {
+		ignoreFiles = append(ignoreFiles, snapshotEntry{name: name})
+	}
+
+	layers, err := f.loadIgnoreFiles(directory, iteration, ignoreFiles, layers)
+	if err != nil {
+		return nil, err
+	}
+	if layers.hash() != cached.IgnoreHash {
+		return nil, nil
+	}
+
+	// files are only checked when we need to know if they were modified, or when something
+	// about them beyond their name is used, such as if they are binary or their language
+	check := f.changeQueue != nil || f.cfg.StatFiles || layers.config(f).IgnoreBinaryFiles || f.detectsLanguages()
+
+	record := &snapshotDirectory{
+		ModTime:     cached.ModTime,
+		IgnoreHash:  cached.IgnoreHash,
+		IgnoreFiles: cached.IgnoreFiles,
+		Files:       slices.Clone(cached.Files),
+		Directories: cached.Directories,
+		Skipped:     cached.Skipped,
+	}
+
+	for _, skipped := range record.Skipped {
+		if skipped.File == nil {
+			continue
+		}
+		info, err := f.lstat(filepath.ToSlash(filepath.Join(directory, skipped.Name)))
+		if err != nil || !sameSnapshotFile(*skipped.File, newSnapshotFile(info)) {
+			return nil, nil
+		}
+	}
+
+	for i, file := range record.Files {
+		joined := filepath.ToSlash(filepath.Join(directory, file.Name))
+		entry := snapshotEntry{name: file.Name, location: joined, mode: file.Mode, lstat: f.lstat}
+
+		if check {
+			info, err := f.lstat(joined)
+			if err != nil || !sameSnapshotFile(file, newSnapshotFile(info)) {
+				return nil, nil
+			}
+			entry.info = info
+		}
+
+		record.Files[i].file = &File{
+			Location: joined,
+			Filename: file.Name,
+			Root:     root,
+			Depth:    iteration,
+			DirEntry: entry,
+			Content:  file.Content,
+			Type:     FileTypeFile,
+			Language: file.Language,
+			info:     entry.info,
+		}
+	}
+
+	scan := &directoryScan{
+		files:     make([]*File, 0, len(record.Files)),
+		dirs:      make([]fs.DirEntry, 0, len(record.Directories)),
+		layers:    layers,
+		ancestors: f.ancestorsWith(ancestors, directory),
+		record:    record,
+	}
+	for _, file := range record.Files {
+		scan.files = append(scan.files, file.file)
+	}
+	scan.returned = int64(len(scan.files))
+	for _, name := range record.Directories {
+		scan.dirs = append(scan.dirs, snapshotEntry{name: name, mode: fs.ModeDir})
+	}
+
+	// only once the directory is known to be unchanged, as otherwise they are reported when it is read
+	for _, skipped := range record.Skipped {
+		f.skip(filepath.ToSlash(filepath.Join(directory, skipped.Name)), skipped.Name, skipped.Dir, skipped.Reason, nil, layers)
+	}
+
+	return scan, nil
+}
+
+// newSnapshotFile records the supplied info, which may be nil if it could not be fetched
+func newSnapshotFile(info fs.FileInfo) snapshotFile {
+	if info == nil {
+		return snapshotFile{}
+	}
+	return snapshotFile{
+		Name:    info.Name(),
+		Mode:    info.Mode(),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+}
+
+// sameSnapshotFile returns true if the file appears to be unmodified
+func sameSnapshotFile(a snapshotFile, b snapshotFile) bool {
+	return a.Mode == b.Mode && a.Size == b.Size && a.ModTime.Equal(b.ModTime)
+}
+
+// finishSnapshot writes the snapshot and sends the files in directories which were not
+// walked as removed to the change queue if the walk finished without error, then
+// closes the change queue
+func (f *FileWalker) finishSnapshot(err error) error {
+	if f.changeQueue != nil {
+		defer close(f.changeQueue)
+	}
+
+	if f.snapshot == nil || err != nil {
+		return err
+	}
+
+	if err := f.writeSnapshot(); err != nil {
+		if !f.errorsHandler(err) {
+			return err
+		}
+	}
+
+	if f.changeQueue == nil {
+		return nil
+	}
+
+	return f.sendChanges(f.snapshot.removed())
+}
+
+// writeSnapshot writes the snapshot to a temporary file which then replaces SnapshotPath,
+// so that a walk interrupted while writing never leaves a partially written snapshot
+func (f *FileWalker) writeSnapshot() error {
+	c, err := json.Marshal(f.snapshot.current)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.cfg.SnapshotPath), filepath.Base(f.cfg.SnapshotPath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(c); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.cfg.SnapshotPath)
+}
+
+// directoryChanges compares the files returned in the directory by this walk with those
+// in the previous snapshot, returning them sorted by location
+func (s *snapshotState) directoryChanges(directory string, record *snapshotDirectory) []*Change {
+	previous := map[string]snapshotFile{}
+	if s.previous != nil {
+		if d, ok := s.previous.Directories[directory]; ok {
+			for _, file := range d.Files {
+				previous[file.Name] = file
+			}
+		}
+	}
+
+	changes := []*Change{}
+	for _, file := range record.Files {
+		location := filepath.ToSlash(filepath.Join(directory, file.Name))
+		old, ok := previous[file.Name]
+		delete(previous, file.Name)
+
+		if !ok {
+			changes = append(changes, &Change{Type: ChangeAdded, Location: location, File: file.file})
+		} else if !sameSnapshotFile(old, file) {
+			changes = append(changes, &Change{Type: ChangeModified, Location: location, File: file.file})
+		}
+	}
+
+	for name := range previous {
+		changes = append(changes, &Change{Type: ChangeRemoved, Location: filepath.ToSlash(filepath.Join(directory, name))})
+	}
+
+	return sortChanges(changes)
+}
+
+// removed returns the files in directories in the previous snapshot which were not
+// walked this time, such as those deleted or now ignored, sorted by location
+func (s *snapshotState) removed() []*Change {
+	changes := []*Change{}
+	if s.previous == nil {
+		return changes
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for directory, d := range s.previous.Directories {
+		if _, ok := s.current.Directories[directory]; ok {
+			continue
+		}
+		for _, file := range d.Files {
+			changes = append(changes, &Change{Type: ChangeRemoved, Location: filepath.ToSlash(filepath.Join(directory, file.Name))})
+		}
+	}
+
+	return sortChanges(changes)
+}
+
+func sortChanges(changes []*Change) []*Change {
+	slices.SortFunc(changes, func(a, b *Change) int {
+		return strings.Compare(a.Location, b.Location)
+	})
+	return changes
+}
@@ -0,0 +1,428 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// makeSnapshotTree creates a small tree with a nested .gitignore and sets every
+// directory back in time, so that they are old enough to be reused from a snapshot
+func makeSnapshotTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "main.go"), "package main")
+	writeFile(t, filepath.Join(root, "pkg", "a.go"), "package pkg")
+	writeFile(t, filepath.Join(root, "pkg", "b.go"), "package pkg")
+	writeFile(t, filepath.Join(root, "pkg", ".gitignore"), "")
+	writeFile(t, filepath.Join(root, "pkg", "deep", "c.go"), "package deep")
+	ageDirectories(t, root)
+	return root
+}
+
+// ageDirectories sets the modification time of every directory in root an hour back
+func ageDirectories(t *testing.T, root string) {
+	t.Helper()
+	old := time.Now().Add(-time.Hour)
+	for _, dir := range []string{root, filepath.Join(root, "pkg"), filepath.Join(root, "pkg", "deep")} {
+		if err := os.Chtimes(dir, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+type snapshotResult struct {
+	files   []string
+	changes map[string]ChangeType
+	reads   int
+	err     error
+}
+
+// snapshotWalk walks root recording the snapshot to snapshotPath, counting
+// how many directories had to be read
+func snapshotWalk(t *testing.T, root string, snapshotPath string, configure func(walker *FileWalker)) snapshotResult {
+	t.Helper()
+
+	fileListQueue := make(chan *File, 100)
+	changeQueue := make(chan *Change, 100)
+	walker := NewFileWalker(root, fileListQueue)
+	walker.SnapshotPath = snapshotPath
+	walker.SetChangeQueue(changeQueue)
+
+	result := snapshotResult{changes: map[string]ChangeType{}}
+	walker.osOpen = func(name string) (*os.File, error) {
+		result.reads++
+		return os.Open(name)
+	}
+	if configure != nil {
+		configure(walker)
+	}
+
+	result.err = walker.Start()
+
+	for f := range fileListQueue {
+		rel, _ := filepath.Rel(root, f.Location)
+		result.files = append(result.files, filepath.ToSlash(rel))
+	}
+	for c := range changeQueue {
+		rel, _ := filepath.Rel(root, c.Location)
+		result.changes[filepath.ToSlash(rel)] = c.Type
+		if (c.File == nil) != (c.Type == ChangeRemoved) {
+			t.Errorf("expected File only for added and modified changes got %v for %s", c.File, c.Type)
+		}
+	}
+	sort.Strings(result.files)
+
+	return result
+}
+
+func TestSnapshotFirstWalkReportsAdded(t *testing.T) {
+	root := makeSnapshotTree(t)
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.json")
+
+	result := snapshotWalk(t, root, snapshotPath, nil)
+	if result.err != nil {
+		t.Fatal(result.err)
+	}
+
+	expected := []string{"main.go", "pkg/a.go", "pkg/b.go", "pkg/deep/c.go"}
+	if !slices.Equal(result.files, expected) {
+		t.Errorf("expected %v got %v", expected, result.files)
+	}
+	if len(result.changes) != len(expected) {
+		t.Errorf("expected %d changes got %v", len(expected), result.changes)
+	}
+	for _, location := range expected {
+		if result.changes[location] != ChangeAdded {
+			t.Errorf("expected %s to be added got %s", location, result.changes[location])
+		}
+	}
+	if _, err := os.Stat(snapshotPath); err != nil {
+		t.Errorf("expected snapshot to be written got %v", err)
+	}
+}
+
+func TestSnapshotUnchangedSkipsReading(t *testing.T) {
+	root := makeSnapshotTree(t)
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.json")
+
+	first := snapshotWalk(t, root, snapshotPath, nil)
+	second := snapshotWalk(t, root, snapshotPath, nil)
+
+	if second.err != nil {
+		t.Fatal(second.err)
+	}
+	if !slices.Equal(first.files, second.files) {
+		t.Errorf("expected %v got %v", first.files, second.files)
+	}
+	if first.reads != 3 {
+		t.Errorf("expected 3 directories read on first walk got %d", first.reads)
+	}
+	if second.reads != 0 {
+		t.Errorf("expected no directories read on second walk got %d", second.reads)
+	}
+	if len(second.changes) != 0 {
+		t.Errorf("expected no changes got %v", second.changes)
+	}
+}
+
+func TestSnapshotReportsChanges(t *testing.T) {
+	root := makeSnapshotTree(t)
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.json")
+	snapshotWalk(t, root, snapshotPath, nil)
+
+	writeFile(t, filepath.Join(root, "pkg", "new.go"), "package pkg")
+	writeFile(t, filepath.Join(root, "pkg", "a.go"), "package pkg // modified")
+	if err := os.Remove(filepath.Join(root, "pkg", "deep", "c.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	result := snapshotWalk(t, root, snapshotPath, nil)
+	if result.err != nil {
+		t.Fatal(result.err)
+	}
+
+	expected := map[string]ChangeType{
+		"pkg/new.go":    ChangeAdded,
+		"pkg/a.go":      ChangeModified,
+		"pkg/deep/c.go": ChangeRemoved,
+	}
+	if len(result.changes) != len(expected) {
+		t.Errorf("expected %v got %v", expected, result.changes)
+	}
+	for location, change := range expected {
+		if result.changes[location] != change {
+			t.Errorf("expected %s to be %s got %s", location, change, result.changes[location])
+		}
+	}
+	// the root is unchanged so should not be read
+	if result.reads != 2 {
+		t.Errorf("expected 2 directories read got %d", result.reads)
+	}
+}
+
+func TestSnapshotIgnoreFileChangeRereads(t *testing.T) {
+	root := makeSnapshotTree(t)
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.json")
+	snapshotWalk(t, root, snapshotPath, nil)
+
+	// rewriting an existing file does not change the directory modification time
+	writeFile(t, filepath.Join(root, "pkg", ".gitignore"), "b.go\ndeep/\n")
+	ageDirectories(t, root)
+
+	result := snapshotWalk(t, root, snapshotPath, nil)
+	if result.err != nil {
+		t.Fatal(result.err)
+	}
+
+	expected := []string{"main.go", "pkg/a.go"}
+	if !slices.Equal(result.files, expected) {
+		t.Errorf("expected %v got %v", expected, result.files)
+	}
+	if result.changes["pkg/b.go"] != ChangeRemoved || result.changes["pkg/deep/c.go"] != ChangeRemoved {
+		t.Errorf("expected ignored files to be removed got %v", result.changes)
+	}
+}
+
+func TestSnapshotSettingsChangeRereads(t *testing.T) {
+	root := makeSnapshotTree(t)
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.json")
+	snapshotWalk(t, root, snapshotPath, nil)
+
+	result := snapshotWalk(t, root, snapshotPath, func(walker *FileWalker) {
+		walker.ExcludeDirectory = []string{"deep"}
+	})
+	if result.err != nil {
+		t.Fatal(result.err)
+	}
+
+	if result.reads != 2 {
+		t.Errorf("expected 2 directories read got %d", result.reads)
+	}
+	if len(result.changes) != 1 || result.changes["pkg/deep/c.go"] != ChangeRemoved {
+		t.Errorf("expected pkg/deep/c.go to be removed got %v", result.changes)
+	}
+}
+
+func TestSnapshotLanguageChangeRereads(t *testing.T) {
+	root := makeSnapshotTree(t)
+	writeFile(t, filepath.Join(root, "pkg", "tool"), "#!/usr/bin/env python3\nprint()")
+	ageDirectories(t, root)
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.json")
+
+	languages := func() map[string]string {
+		sink := &SliceSink{}
+		walker := NewFileWalkerSink(root, sink, func(f *FileWalker) {
+			f.SnapshotPath = snapshotPath
+			f.DetectLanguages = true
+		})
+		if err := walker.Start(); err != nil {
+			t.Fatal(err)
+		}
+		found := map[string]string{}
+		for _, file := range sink.Files {
+			found[file.Filename] = file.Language
+		}
+		return found
+	}
+
+	if found := languages(); found["tool"] != "Python" {
+		t.Fatalf("expected tool to be Python got %v", found)
+	}
+
+	// rewriting an existing file does not change the directory modification time
+	writeFile(t, filepath.Join(root, "pkg", "tool"), "#!/usr/bin/env bash\necho tool")
+
+	if found := languages(); found["tool"] != "Shell" {
+		t.Errorf("expected tool to be Shell once its shebang changed got %v", found)
+	}
+}
+
+func TestSnapshotReplaysSkips(t *testing.T) {
+	root := makeSnapshotTree(t)
+	writeFile(t, filepath.Join(root, "pkg", ".gitignore"), "b.go\n")
+	writeFile(t, filepath.Join(root, ".hidden"), "")
+	ageDirectories(t, root)
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.json")
+
+	walk := func() (snapshotResult, map[string]SkipReason, Stats) {
+		var mutex sync.Mutex
+		skipped := map[string]SkipReason{}
+		var walker *FileWalker
+		result := snapshotWalk(t, root, snapshotPath, func(w *FileWalker) {
+			walker = w
+			w.ExcludeDirectory = []string{"deep"}
+			w.SetSkipHandler(func(path string, name string, isDir bool, reason SkipReason) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				rel, _ := filepath.Rel(root, filepath.FromSlash(path))
+				skipped[filepath.ToSlash(rel)] = reason
+			})
+		})
+		if result.err != nil {
+			t.Fatal(result.err)
+		}
+		return result, skipped, walker.Stats()
+	}
+
+	_, coldSkipped, coldStats := walk()
+	warm, warmSkipped, warmStats := walk()
+
+	if warm.reads != 0 {
+		t.Errorf("expected no directories read got %d", warm.reads)
+	}
+	expected := map[string]SkipReason{
+		".hidden":        SkipReasonHidden,
+		"pkg/.gitignore": SkipReasonHidden,
+		"pkg/b.go":       SkipReasonGitignore,
+		"pkg/deep":       SkipReasonExcludeDirectory,
+	}
+	if !maps.Equal(coldSkipped, expected) {
+		t.Errorf("expected %v got %v", expected, coldSkipped)
+	}
+	if !maps.Equal(warmSkipped, coldSkipped) {
+		t.Errorf("expected cached directories to report %v got %v", coldSkipped, warmSkipped)
+	}
+	if !maps.Equal(warmStats.Skipped, coldStats.Skipped) || warmStats.FilesReturned != coldStats.FilesReturned {
+		t.Errorf("expected cached directories to count %v got %v", coldStats, warmStats)
+	}
+}
+
+func TestSnapshotSkippedBinaryChangeRereads(t *testing.T) {
+	root := makeSnapshotTree(t)
+	writeFile(t, filepath.Join(root, "pkg", "data"), "\x00\x01\x02\x03")
+	ageDirectories(t, root)
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.json")
+
+	binary := func(walker *FileWalker) {
+		walker.IgnoreBinaryFiles = true
+	}
+	if first := snapshotWalk(t, root, snapshotPath, binary); slices.Contains(first.files, "pkg/data") {
+		t.Fatalf("expected pkg/data to be skipped as binary got %v", first.files)
+	}
+
+	// rewriting an existing file does not change the directory modification time
+	writeFile(t, filepath.Join(root, "pkg", "data"), "now some text")
+
+	result := snapshotWalk(t, root, snapshotPath, binary)
+	if !slices.Contains(result.files, "pkg/data") || result.changes["pkg/data"] != ChangeAdded {
+		t.Errorf("expected pkg/data to be added once it is text got %v %v", result.files, result.changes)
+	}
+}
+
+func TestSnapshotContentDetectorChangeRereads(t *testing.T) {
+	root := makeSnapshotTree(t)
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.json")
+	snapshotWalk(t, root, snapshotPath, func(walker *FileWalker) {
+		walker.IgnoreBinaryFiles = true
+	})
+
+	result := snapshotWalk(t, root, snapshotPath, func(walker *FileWalker) {
+		walker.IgnoreBinaryFiles = true
+		walker.SetContentDetector(ContentDetectorFunc(func(head []byte) Content {
+			return Content{Binary: true}
+		}))
+	})
+	if result.err != nil {
+		t.Fatal(result.err)
+	}
+
+	if result.reads != 3 {
+		t.Errorf("expected 3 directories read got %d", result.reads)
+	}
+	if len(result.files) != 0 {
+		t.Errorf("expected every file to be binary got %v", result.files)
+	}
+}
+
+func TestSnapshotChangesSentWhileWalking(t *testing.T) {
+	root := makeSnapshotTree(t)
+
+	fileListQueue := make(chan *File, 100)
+	changeQueue := make(chan *Change)
+	walker := NewFileWalker(root, fileListQueue)
+	walker.SnapshotPath = filepath.Join(t.TempDir(), "snapshot.json")
+	walker.SetChangeQueue(changeQueue)
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- walker.Start()
+	}()
+
+	changes := []*Change{<-changeQueue}
+	// with the change queue unread the walk cannot finish, so the file queue is still open
+	for open := true; open; {
+		select {
+		case _, ok := <-fileListQueue:
+			if !ok {
+				t.Fatal("expected changes to be sent before the walk finished")
+			}
+		default:
+			open = false
+		}
+	}
+
+	for c := range changeQueue {
+		changes = append(changes, c)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 4 {
+		t.Errorf("expected 4 changes got %d", len(changes))
+	}
+}
+
+func TestSnapshotInvalidIsReported(t *testing.T) {
+	root := makeSnapshotTree(t)
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.json")
+	writeFile(t, snapshotPath, "not json")
+
+	var handled []error
+	result := snapshotWalk(t, root, snapshotPath, func(walker *FileWalker) {
+		walker.SetErrorHandler(func(err error) bool {
+			handled = append(handled, err)
+			return true
+		})
+	})
+	if result.err != nil {
+		t.Fatal(result.err)
+	}
+	if len(handled) != 1 {
+		t.Errorf("expected invalid snapshot to be passed to the error handler got %v", handled)
+	}
+	if len(result.files) != 4 {
+		t.Errorf("expected 4 files got %v", result.files)
+	}
+}
+
+func TestSnapshotNotWrittenOnError(t *testing.T) {
+	root := makeSnapshotTree(t)
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.json")
+
+	result := snapshotWalk(t, root, snapshotPath, func(walker *FileWalker) {
+		walker.osOpen = func(name string) (*os.File, error) {
+			return nil, os.ErrPermission
+		}
+		walker.SetErrorHandler(func(err error) bool {
+			return false
+		})
+	})
+	if !errors.Is(result.err, os.ErrPermission) {
+		t.Errorf("expected permission error got %v", result.err)
+	}
+	if len(result.changes) != 0 {
+		t.Errorf("expected no changes got %v", result.changes)
+	}
+	if _, err := os.Stat(snapshotPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected no snapshot to be written got %v", err)
+	}
+}
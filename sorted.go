@@ -0,0 +1,239 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// CompareByName orders entries lexically by name, which is the default when Sorted is set
+func CompareByName(a fs.DirEntry, b fs.DirEntry) int {
+	return strings.Compare(a.Name(), b.Name())
+}
+
+// SetComparator sets the function used to order the entries in each directory when Sorted
+// is set, which should return a negative number when a comes before b, a positive number when
+// it comes after and zero when they are equal. Files and directories are ordered together so
+// returning directories last for example will return all files in a directory before any below it
+func (f *FileWalker) SetComparator(compare func(a fs.DirEntry, b fs.DirEntry) int) {
+	f.walkMutex.Lock()
+	defer f.walkMutex.Unlock()
+	f.compare = compare
+}
+
+// walkSorted sends the files and walks the directories found in a directory in the order of
+// the comparator, making the output depth-first and the same for every walk. At the root the
+// directories are still walked in parallel, each into its own buffer which is sent in order.
+// Only subtrees which finish ahead of their turn are held in memory, the one being sent is
+// passed straight through as it is found.
+func (f *FileWalker) walkSorted(iteration int, root string, directory string, scan *directoryScan, emit func(files []*File) error) error {
+	compare := f.compare
+	if compare == nil {
+		compare = CompareByName
+	}
+
+	files := slices.Clone(scan.files)
+	slices.SortStableFunc(files, func(a, b *File) int {
+		return compare(a.DirEntry, b.DirEntry)
+	})
+	dirs := slices.Clone(scan.dirs)
+	slices.SortStableFunc(dirs, compare)
+
+	var buffers []*subtreeBuffer
+	if iteration == 0 {
+		buffers = make([]*subtreeBuffer, 0, len(dirs))
+		for _, dir := range dirs {
+			joined := filepath.ToSlash(filepath.Join(directory, dir.Name()))
+			buffer := newSubtreeBuffer()
+			buffers = append(buffers, buffer)
+			go func() {
+				buffer.finish(f.walkDirectoryRecursive(iteration+1, root, joined, scan.layers, scan.ancestors, buffer.add))
+			}()
+		}
+	}
+
+	err := f.mergeSorted(iteration, root, directory, scan, files, dirs, buffers, compare, emit)
+
+	// as with walking unsorted every subtree is waited for even if one failed
+	for _, buffer := range buffers {
+		if berr := buffer.wait(); err == nil {
+			err = berr
+		}
+	}
+
+	return err
+}
+
+// mergeSorted sends the already ordered files and walks the already ordered directories taking
+// whichever comes first each time, so that files and directories are interleaved
+func (f *FileWalker) mergeSorted(iteration int,
+	root string,
+	directory string,
+	scan *directoryScan,
+	files []*File,
+	dirs []fs.DirEntry,
+	buffers []*subtreeBuffer,
+	compare func(a fs.DirEntry, b fs.DirEntry) int,
+	emit func(files []*File) error) error {
+
+	i, j := 0, 0
+	for i < len(files) || j < len(dirs) {
+		// every file which comes before the next directory is sent as one batch
+		start := i
+		for i < len(files) && (j == len(dirs) || compare(files[i].DirEntry, dirs[j]) <= 0) {
+			i++
+		}
+		if i != start {
+			if err := emit(files[start:i:i]); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := f.mergeDirectory(iteration, root, directory, scan, dirs[j], buffers, j, emit); err != nil {
+			return err
+		}
+		j++
+	}
+
+	return nil
+}
+
+// mergeDirectory sends everything below the directory, either from its buffer or by walking it,
+// surrounded by the directory and leave directory events when EmitDirectories is set
+func (f *FileWalker) mergeDirectory(iteration int,
+	root string,
+	directory string,
+	scan *directoryScan,
+	dir fs.DirEntry,
+	buffers []*subtreeBuffer,
+	j int,
+	emit func(files []*File) error) error {
+
+	var dirFile *File
+	if f.cfg.EmitDirectories {
+		dirFile = f.directoryFile(iteration, root, directory, dir)
+		if err := emit([]*File{dirFile}); err != nil {
+			return err
+		}
+	}
+
+	count := int64(0)
+	below := emit
+	if f.cfg.EmitLeaveDirectories {
+		below = countingEmit(&count, emit)
+	}
+
+	var err error
+	if buffers != nil {
+		err = buffers[j].drain(below)
+	} else {
+		joined := filepath.ToSlash(filepath.Join(directory, dir.Name()))
+		err = f.walkDirectoryRecursive(iteration+1, root, joined, scan.layers, scan.ancestors, below)
+	}
+	if err != nil {
+		return err
+	}
+
+	if f.cfg.EmitLeaveDirectories {
+		return emit([]*File{leaveFile(dirFile, count)})
+	}
+	return nil
+}
+
+// walkRootsSorted walks each of the directories in parallel sending the files
+// from each in the order the directories were supplied
+func (f *FileWalker) walkRootsSorted() error {
+	buffers := make([]*subtreeBuffer, 0, len(f.directories))
+	for _, directory := range f.directories {
+		d := directory // capture var
+		buffer := newSubtreeBuffer()
+		buffers = append(buffers, buffer)
+		go func() {
+			buffer.finish(f.walkRoot(d, buffer.add))
+		}()
+	}
+
+	var err error
+	for _, buffer := range buffers {
+		if err == nil {
+			err = buffer.drain(f.emit)
+		} else if berr := buffer.wait(); err == nil {
+			err = berr
+		}
+	}
+
+	return err
+}
+
+// subtreeBuffer holds the batches of files found while walking a subtree until it is its turn to be sent
+type subtreeBuffer struct {
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	batches [][]*File
+	done    bool
+	err     error
+}
+
+func newSubtreeBuffer() *subtreeBuffer {
+	b := &subtreeBuffer{}
+	b.cond = sync.NewCond(&b.mutex)
+	return b
+}
+
+// add buffers the batch, and never blocks so that walking the subtree is not held up
+func (b *subtreeBuffer) add(files []*File) error {
+	b.mutex.Lock()
+	b.batches = append(b.batches, files)
+	b.mutex.Unlock()
+	b.cond.Signal()
+	return nil
+}
+
+// finish marks the subtree as walked with the error if any
+func (b *subtreeBuffer) finish(err error) {
+	b.mutex.Lock()
+	b.done = true
+	b.err = err
+	b.mutex.Unlock()
+	b.cond.Broadcast()
+}
+
+// drain sends every batch in the buffer as they arrive until the subtree has been walked
+func (b *subtreeBuffer) drain(emit func(files []*File) error) error {
+	for {
+		b.mutex.Lock()
+		for len(b.batches) == 0 && !b.done {
+			b.cond.Wait()
+		}
+		batches := b.batches
+		b.batches = nil
+		done := b.done
+		b.mutex.Unlock()
+
+		for _, files := range batches {
+			if err := emit(files); err != nil {
+				return err
+			}
+		}
+
+		if done && len(batches) == 0 {
+			return b.wait()
+		}
+	}
+}
+
+// wait waits for the subtree to be walked discarding anything buffered
+func (b *subtreeBuffer) wait() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for !b.done {
+		b.cond.Wait()
+	}
+	b.batches = nil
+	return b.err
+}
@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// makeSortedTree creates files and directories with interleaving names
+// across enough directories that they are walked concurrently
+func makeSortedTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, name := range []string{"a.go", "b/x.go", "b/a/y.go", "b/z.go", "c.go", "d/e/f/g.go", "e.go", "f/a.go", "g/a.go", "h/a.go", "i/a.go", "j.go"} {
+		writeFile(t, filepath.Join(root, name), "")
+	}
+	return root
+}
+
+func sortedWalk(t *testing.T, walker *FileWalker, fileListQueue chan *File, root string) []string {
+	t.Helper()
+	go func() {
+		if err := walker.Start(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	files := []string{}
+	for f := range fileListQueue {
+		rel, _ := filepath.Rel(root, f.Location)
+		files = append(files, filepath.ToSlash(rel))
+	}
+	return files
+}
+
+func TestSortedIsLexicalDepthFirst(t *testing.T) {
+	root := makeSortedTree(t)
+	expected := []string{"a.go", "b/a/y.go", "b/x.go", "b/z.go", "c.go", "d/e/f/g.go", "e.go", "f/a.go", "g/a.go", "h/a.go", "i/a.go", "j.go"}
+
+	for i := 0; i < 20; i++ {
+		fileListQueue := make(chan *File, 100)
+		walker := NewFileWalker(root, fileListQueue)
+		walker.Sorted = true
+
+		files := sortedWalk(t, walker, fileListQueue, root)
+		if !slices.Equal(files, expected) {
+			t.Fatalf("expected %v got %v", expected, files)
+		}
+	}
+}
+
+func TestSortedComparator(t *testing.T) {
+	root := makeSortedTree(t)
+
+	fileListQueue := make(chan *File, 100)
+	walker := NewFileWalker(root, fileListQueue)
+	walker.Sorted = true
+	walker.SetComparator(func(a fs.DirEntry, b fs.DirEntry) int {
+		if a.IsDir() != b.IsDir() {
+			if a.IsDir() {
+				return 1
+			}
+			return -1
+		}
+		return -CompareByName(a, b)
+	})
+
+	expected := []string{"j.go", "e.go", "c.go", "a.go", "i/a.go", "h/a.go", "g/a.go", "f/a.go", "d/e/f/g.go", "b/z.go", "b/x.go", "b/a/y.go"}
+	files := sortedWalk(t, walker, fileListQueue, root)
+	if !slices.Equal(files, expected) {
+		t.Errorf("expected %v got %v", expected, files)
+	}
+}
+
+func TestSortedParallelRootsInOrder(t *testing.T) {
+	first := makeSortedTree(t)
+	second := t.TempDir()
+	writeFile(t, filepath.Join(second, "only.go"), "")
+
+	for i := 0; i < 10; i++ {
+		fileListQueue := make(chan *File, 100)
+		walker := NewParallelFileWalker([]string{second, first}, fileListQueue)
+		walker.Sorted = true
+
+		go func() {
+			_ = walker.Start()
+		}()
+
+		files := []string{}
+		for f := range fileListQueue {
+			files = append(files, f.Location)
+		}
+		if len(files) != 13 || files[0] != filepath.ToSlash(filepath.Join(second, "only.go")) {
+			t.Fatalf("expected only.go from the first root first got %v", files)
+		}
+	}
+}
+
+func TestSortedCancelDoesNotHang(t *testing.T) {
+	root := makeContextTree(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fileListQueue := make(chan *File)
+	walker := NewFileWalker(root, fileListQueue)
+	walker.Sorted = true
+
+	done := make(chan error, 1)
+	go func() {
+		done <- walker.StartContext(ctx)
+	}()
+
+	<-fileListQueue
+	cancel()
+	for range fileListQueue {
+	}
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled got %v", err)
+	}
+}
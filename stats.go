@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProgressInterval is how often the progress handler is called by default
+var ProgressInterval = time.Second
+
+// Stats is what the current or last walk has done, returned by FileWalker.Stats
+// and passed to the progress handler
+type Stats struct {
+	Walking             bool                 `json:"walking"`              // Is the walk still running?
+	Started             time.Time            `json:"started"`              // When the walk started, zero if there has not been one
+	Elapsed             time.Duration        `json:"elapsed"`              // How long the walk has been running, or took if it has finished
+	DirectoriesRead     int64                `json:"directories_read"`     // Directories read from the file system
+	DirectoriesCached   int64                `json:"directories_cached"`   // Directories not read because they were unchanged since the snapshot was recorded
+	IgnoreFilesParsed   int64                `json:"ignore_files_parsed"`  // .gitignore, .ignore, .gitmodules, custom ignore, git excludes and project config files read
+	FilesReturned       int64                `json:"files_returned"`       // Files sent to the fileListQueue or Sink
+	DirectoriesReturned int64                `json:"directories_returned"` // Directories sent to the fileListQueue or Sink when EmitDirectories is set
+	BinaryBytesRead     int64                `json:"binary_bytes_read"`    // Bytes read from files to determine if they are binary
+	Skipped             map[SkipReason]int64 `json:"skipped"`              // Files and directories skipped for each reason
+	Roots               []RootStats          `json:"roots"`                // The directories walked which have finished, in the order they finished
+}
+
+// SkippedTotal returns how many files and directories were skipped for any reason
+func (s Stats) SkippedTotal() int64 {
+	total := int64(0)
+	for _, count := range s.Skipped {
+		total += count
+	}
+	return total
+}
+
+// RootStats is how long walking one of the directories supplied to the walker took
+type RootStats struct {
+	Root    string        `json:"root"`
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// walkStats collects Stats while walking. The counters are updated by every
+// goroutine walking so are atomic, with the rest protected by the mutex.
+type walkStats struct {
+	directoriesRead   atomic.Int64
+	directoriesCached atomic.Int64
+	ignoreFilesParsed atomic.Int64
+	filesReturned     atomic.Int64
+	dirsReturned      atomic.Int64
+	binaryBytesRead   atomic.Int64
+
+	mutex    sync.Mutex
+	started  time.Time
+	finished time.Time
+	skipped  map[SkipReason]int64
+	roots    []RootStats
+}
+
+// reset clears everything collected ready for a new walk starting now
+func (s *walkStats) reset() {
+	s.directoriesRead.Store(0)
+	s.directoriesCached.Store(0)
+	s.ignoreFilesParsed.Store(0)
+	s.filesReturned.Store(0)
+	s.dirsReturned.Store(0)
+	s.binaryBytesRead.Store(0)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.started = time.Now()
+	s.finished = time.Time{}
+	s.skipped = map[SkipReason]int64{}
+	s.roots = nil
+}
+
+// finish records that the walk is over, so Elapsed stops increasing
+func (s *walkStats) finish() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.finished = time.Now()
+}
+
+func (s *walkStats) skip(reason SkipReason) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.skipped == nil {
+		s.skipped = map[SkipReason]int64{}
+	}
+	s.skipped[reason]++
+}
+
+// root records how long the root took to walk, given when it started
+func (s *walkStats) root(root string, started time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.roots = append(s.roots, RootStats{Root: root, Elapsed: time.Since(started)})
+}
+
+func (s *walkStats) snapshot() Stats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stats := Stats{
+		Started:             s.started,
+		DirectoriesRead:     s.directoriesRead.Load(),
+		DirectoriesCached:   s.directoriesCached.Load(),
+		IgnoreFilesParsed:   s.ignoreFilesParsed.Load(),
+		FilesReturned:       s.filesReturned.Load(),
+		DirectoriesReturned: s.dirsReturned.Load(),
+		BinaryBytesRead:     s.binaryBytesRead.Load(),
+		Skipped:             maps.Clone(s.skipped),
+		Roots:               slices.Clone(s.roots),
+	}
+	if stats.Skipped == nil {
+		stats.Skipped = map[SkipReason]int64{}
+	}
+
+	switch {
+	case s.started.IsZero():
+	case s.finished.IsZero():
+		stats.Walking = true
+		stats.Elapsed = time.Since(s.started)
+	default:
+		stats.Elapsed = s.finished.Sub(s.started)
+	}
+	return stats
+}
+
+// Stats returns what the current walk has done so far, or what the last walk did once it has
+// finished. It is safe to call from any goroutine while walking, including the handlers.
+func (f *FileWalker) Stats() Stats {
+	return f.stats.snapshot()
+}
+
+// SetProgressHandler sets the function that is called with the Stats every ProgressInterval
+// while walking, and once more with the final Stats when walking finishes. It is called from
+// its own goroutine so a slow handler does not slow the walk. By default there is none.
+func (f *FileWalker) SetProgressHandler(handler func(stats Stats)) {
+	f.progressHandler = handler
+}
+
+// WithProgressHandler sets the progress handler, see SetProgressHandler
+func WithProgressHandler(handler func(stats Stats)) Option {
+	return func(f *FileWalker) {
+		f.SetProgressHandler(handler)
+	}
+}
+
+// startStats resets the stats and starts calling the progress handler if set,
+// returning the function to call when walking finishes. Must be called with the
+// walkMutex held after the config has been frozen.
+func (f *FileWalker) startStats() func() {
+	f.stats.reset()
+
+	handler := f.progressHandler
+	if handler == nil {
+		return f.stats.finish
+	}
+
+	interval := ProgressInterval
+	if f.cfg != nil && f.cfg.ProgressInterval > 0 {
+		interval = f.cfg.ProgressInterval
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				handler(f.stats.snapshot())
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+		f.stats.finish()
+		handler(f.stats.snapshot())
+	}
+}
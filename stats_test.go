@@ -0,0 +1,186 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStatsCounts(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ".gitignore"), "*.log\n")
+	writeFile(t, filepath.Join(root, "main.go"), "package main\n")
+	writeFile(t, filepath.Join(root, "debug.log"), "")
+	writeFile(t, filepath.Join(root, "notes.md"), "")
+	writeFile(t, filepath.Join(root, "pkg", ".ignore"), "generated.go\n")
+	writeFile(t, filepath.Join(root, "pkg", "pkg.go"), "package pkg\n")
+	writeFile(t, filepath.Join(root, "pkg", "generated.go"), "")
+	writeFile(t, filepath.Join(root, "pkg", "image.go"), "\x00\x01\x02")
+
+	fileListQueue := make(chan *File, 100)
+	walker := NewFileWalker(root, fileListQueue, WithAllowListExtensions("go", "log"), WithIgnoreBinaryFiles(true))
+	if stats := walker.Stats(); stats.Walking || !stats.Started.IsZero() || stats.Skipped == nil {
+		t.Errorf("expected empty stats before walking got %+v", stats)
+	}
+
+	if err := walker.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for range fileListQueue {
+	}
+
+	stats := walker.Stats()
+	if stats.Walking || stats.Started.IsZero() || stats.Elapsed <= 0 {
+		t.Errorf("expected a finished walk got %+v", stats)
+	}
+	if stats.DirectoriesRead != 2 || stats.DirectoriesCached != 0 {
+		t.Errorf("expected 2 directories read got %d read %d cached", stats.DirectoriesRead, stats.DirectoriesCached)
+	}
+	if stats.IgnoreFilesParsed != 2 {
+		t.Errorf("expected 2 ignore files parsed got %d", stats.IgnoreFilesParsed)
+	}
+	if stats.FilesReturned != 2 {
+		t.Errorf("expected 2 files returned got %d", stats.FilesReturned)
+	}
+	// every file is sniffed, including those already skipped, as every filter is evaluated
+	if stats.BinaryBytesRead != int64(len("*.log\n")+len("generated.go\n")+len("package main\n")+len("package pkg\n")+3) {
+		t.Errorf("expected the files to be sniffed got %d bytes", stats.BinaryBytesRead)
+	}
+
+	expected := map[SkipReason]int64{
+		SkipReasonGitignore:          1,
+		SkipReasonIgnoreFile:         1,
+		SkipReasonAllowListExtension: 3,
+		SkipReasonBinary:             1,
+	}
+	for reason, count := range expected {
+		if stats.Skipped[reason] != count {
+			t.Errorf("expected %d skipped for %s got %d", count, reason, stats.Skipped[reason])
+		}
+	}
+	if stats.SkippedTotal() != 6 {
+		t.Errorf("expected 6 skipped got %d %v", stats.SkippedTotal(), stats.Skipped)
+	}
+	if len(stats.Roots) != 1 || stats.Roots[0].Root != root {
+		t.Errorf("expected the root to be timed got %+v", stats.Roots)
+	}
+}
+
+func TestStatsResetEachWalk(t *testing.T) {
+	root := makeContextTree(t)
+
+	for i := 0; i < 2; i++ {
+		fileListQueue := make(chan *File, 1000)
+		walker := NewFileWalker(root, fileListQueue)
+		if err := walker.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		if stats := walker.Stats(); stats.FilesReturned != 100 || stats.DirectoriesRead != 6 {
+			t.Errorf("expected 100 files from 6 directories got %d from %d", stats.FilesReturned, stats.DirectoriesRead)
+		}
+	}
+}
+
+func TestStatsCustomIgnoreFiles(t *testing.T) {
+	root := t.TempDir()
+	ignores := t.TempDir()
+	writeFile(t, filepath.Join(root, ".gitignore"), "*.log\n")
+	writeFile(t, filepath.Join(root, "main.go"), "package main\n")
+	writeFile(t, filepath.Join(ignores, "custom"), "*.md\n")
+
+	fileListQueue := make(chan *File, 100)
+	walker := NewFileWalker(root, fileListQueue)
+	walker.CustomIgnoreFiles = []string{filepath.Join(ignores, "custom")}
+	if err := walker.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := walker.Stats(); stats.IgnoreFilesParsed != 2 {
+		t.Errorf("expected the .gitignore and custom ignore file parsed once each got %d", stats.IgnoreFilesParsed)
+	}
+}
+
+func TestStatsParallelRoots(t *testing.T) {
+	first := makeContextTree(t)
+	second := makeContextTree(t)
+
+	fileListQueue := make(chan *File, 1000)
+	walker := NewParallelFileWalker([]string{first, second}, fileListQueue, WithSorted(true))
+	if err := walker.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := walker.Stats()
+	if stats.FilesReturned != 200 || len(stats.Roots) != 2 {
+		t.Fatalf("expected 200 files from 2 roots got %d from %+v", stats.FilesReturned, stats.Roots)
+	}
+	for _, r := range stats.Roots {
+		if r.Root != first && r.Root != second {
+			t.Errorf("unexpected root %+v", r)
+		}
+	}
+}
+
+func TestStatsDuringWalk(t *testing.T) {
+	root := makeContextTree(t)
+
+	// the queue is unbuffered so the walk blocks until each file is read
+	fileListQueue := make(chan *File)
+	walker := NewFileWalker(root, fileListQueue)
+	go func() {
+		_ = walker.Start()
+	}()
+
+	<-fileListQueue
+	stats := walker.Stats()
+	if !stats.Walking || stats.DirectoriesRead == 0 {
+		t.Errorf("expected stats while walking got %+v", stats)
+	}
+	for range fileListQueue {
+	}
+}
+
+func TestProgressHandler(t *testing.T) {
+	root := makeContextTree(t)
+
+	var mutex sync.Mutex
+	var calls []Stats
+	fileListQueue := make(chan *File)
+	walker := NewFileWalker(root, fileListQueue, WithProgressHandler(func(stats Stats) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		calls = append(calls, stats)
+	}))
+	walker.ProgressInterval = time.Millisecond
+
+	go func() {
+		_ = walker.Start()
+	}()
+	for range fileListQueue {
+		time.Sleep(100 * time.Microsecond)
+	}
+
+	// the queue is closed before the final call is made
+	for walker.Walking() {
+		time.Sleep(time.Millisecond)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(calls) < 2 {
+		t.Fatalf("expected progress while walking and once at the end got %d calls", len(calls))
+	}
+	last := calls[len(calls)-1]
+	if last.Walking || last.FilesReturned != 100 {
+		t.Errorf("expected the final stats last got %+v", last)
+	}
+	for _, stats := range calls[:len(calls)-1] {
+		if !stats.Walking {
+			t.Errorf("expected the walk to be running for every call but the last got %+v", stats)
+		}
+	}
+}
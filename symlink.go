@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrSymlinkLoop error which indicates a followed symlink points at a directory already being walked
+var ErrSymlinkLoop = errors.New("symlink loop")
+
+// ErrSymlinkEscape error which indicates a followed symlink resolves outside the walked root
+var ErrSymlinkEscape = errors.New("symlink escapes root")
+
+// symlinkDirEntry is a symlink which resolves to a directory, and as such
+// reports itself as a directory so it is walked like any other
+type symlinkDirEntry struct {
+	fs.DirEntry
+	target fs.FileInfo
+}
+
+func (s symlinkDirEntry) IsDir() bool {
+	return true
+}
+
+// followsSymlinks returns true if symlinks should be resolved. This only
+// applies to the operating system as fs.FS has no concept of symlinks
+func (f *FileWalker) followsSymlinks() bool {
+	return f.cfg.FollowSymlinks && f.fsys == nil
+}
+
+// resolveSymlinkDir returns an entry which reports as a directory if following symlinks
+// and the supplied entry is a symlink to a directory, otherwise it returns nil. Broken links are
+// left to be treated as files, which is how they were handled before following
+func (f *FileWalker) resolveSymlinkDir(entry fs.DirEntry, directory string) fs.DirEntry {
+	if !f.followsSymlinks() || entry.Type()&fs.ModeSymlink == 0 {
+		return nil
+	}
+
+	target, err := os.Stat(filepath.Join(directory, entry.Name()))
+	if err != nil || !target.IsDir() {
+		return nil
+	}
+
+	return symlinkDirEntry{DirEntry: entry, target: target}
+}
+
+// ancestorsWith returns a new slice of the ancestors with the supplied directory appended,
+// never sharing a backing array with the slice passed in as directories are walked concurrently
+func (f *FileWalker) ancestorsWith(ancestors []os.FileInfo, directory string) []os.FileInfo {
+	if !f.followsSymlinks() {
+		return ancestors
+	}
+
+	info, err := os.Stat(directory)
+	if err != nil {
+		return ancestors
+	}
+	return append(slices.Clip(ancestors), info)
+}
+
+// checkSymlink determines if a followed symlink should be skipped because it
+// loops back to a directory currently being walked, which is determined through
+// device and inode, or because it escapes the root when ConfineSymlinks is set
+func (f *FileWalker) checkSymlink(entry fs.DirEntry, root string, joined string, ancestors []os.FileInfo) (SkipReason, error) {
+	if !f.followsSymlinks() || entry.Type()&fs.ModeSymlink == 0 {
+		return "", nil
+	}
+
+	if link, ok := entry.(symlinkDirEntry); ok {
+		for _, ancestor := range ancestors {
+			if os.SameFile(ancestor, link.target) {
+				return SkipReasonSymlinkLoop, fmt.Errorf("%w: %s", ErrSymlinkLoop, joined)
+			}
+		}
+	}
+
+	if f.cfg.ConfineSymlinks {
+		resolvedRoot, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			return "", err
+		}
+		resolved, err := filepath.EvalSymlinks(joined)
+		if err != nil {
+			return "", err
+		}
+		rel, err := filepath.Rel(resolvedRoot, resolved)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return SkipReasonSymlinkEscape, fmt.Errorf("%w: %s", ErrSymlinkEscape, joined)
+		}
+	}
+
+	return "", nil
+}
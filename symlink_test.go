@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// symlinkWalk walks root returning the emitted locations relative to root, the
+// skip reasons keyed the same way and any errors passed to the error handler
+func symlinkWalk(t *testing.T, root string, configure func(*FileWalker)) (map[string]bool, map[string]SkipReason, []error) {
+	t.Helper()
+	fileListQueue := make(chan *File, 1000)
+	walker := NewFileWalker(root, fileListQueue)
+	configure(walker)
+
+	// the handlers are called from every goroutine walking
+	var mutex sync.Mutex
+	skipped := map[string]SkipReason{}
+	walker.SetSkipHandler(func(path string, name string, isDir bool, reason SkipReason) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		rel, _ := filepath.Rel(root, filepath.FromSlash(path))
+		skipped[filepath.ToSlash(rel)] = reason
+	})
+	var errs []error
+	walker.SetErrorHandler(func(e error) bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		errs = append(errs, e)
+		return true
+	})
+
+	if err := walker.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]bool{}
+	for f := range fileListQueue {
+		rel, _ := filepath.Rel(root, filepath.FromSlash(f.Location))
+		got[filepath.ToSlash(rel)] = true
+	}
+	return got, skipped, errs
+}
+
+func makeSymlinkTree(t *testing.T) (string, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks requires elevated privileges on windows")
+	}
+
+	root := t.TempDir()
+	outside := t.TempDir()
+	writeFile(t, filepath.Join(root, "real", "a.go"), "")
+	writeFile(t, filepath.Join(outside, "b.go"), "")
+
+	links := map[string]string{
+		filepath.Join(root, "linked"):          filepath.Join(root, "real"),
+		filepath.Join(root, "real", "loop"):    root,
+		filepath.Join(root, "escape"):          outside,
+		filepath.Join(root, "escape_file.go"):  filepath.Join(outside, "b.go"),
+		filepath.Join(root, "broken_link.txt"): filepath.Join(root, "does-not-exist"),
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root, outside
+}
+
+func TestSymlinksNotFollowedByDefault(t *testing.T) {
+	root, _ := makeSymlinkTree(t)
+
+	got, _, _ := symlinkWalk(t, root, func(w *FileWalker) {})
+
+	if !got["real/a.go"] {
+		t.Errorf("expected real/a.go got %v", got)
+	}
+	if got["linked/a.go"] || got["escape/b.go"] {
+		t.Errorf("expected symlinked directories not to be walked got %v", got)
+	}
+}
+
+func TestSymlinksFollowed(t *testing.T) {
+	root, _ := makeSymlinkTree(t)
+
+	got, skipped, errs := symlinkWalk(t, root, func(w *FileWalker) {
+		w.FollowSymlinks = true
+	})
+
+	for _, expected := range []string{"real/a.go", "linked/a.go", "escape/b.go", "escape_file.go", "broken_link.txt"} {
+		if !got[expected] {
+			t.Errorf("expected %s to be emitted got %v", expected, got)
+		}
+	}
+
+	if skipped["real/loop"] != SkipReasonSymlinkLoop {
+		t.Errorf("expected real/loop to be skipped as a loop got %v", skipped)
+	}
+	if skipped["linked/loop"] != SkipReasonSymlinkLoop {
+		t.Errorf("expected linked/loop to be skipped as a loop got %v", skipped)
+	}
+
+	loops := 0
+	for _, e := range errs {
+		if errors.Is(e, ErrSymlinkLoop) {
+			loops++
+		}
+	}
+	if loops != 2 {
+		t.Errorf("expected 2 loop errors got %v", errs)
+	}
+}
+
+func TestSymlinksConfined(t *testing.T) {
+	root, _ := makeSymlinkTree(t)
+
+	got, skipped, errs := symlinkWalk(t, root, func(w *FileWalker) {
+		w.FollowSymlinks = true
+		w.ConfineSymlinks = true
+	})
+
+	if !got["linked/a.go"] {
+		t.Errorf("expected linked/a.go to be walked as it stays inside the root got %v", got)
+	}
+	if got["escape/b.go"] || got["escape_file.go"] {
+		t.Errorf("expected links outside the root to be skipped got %v", got)
+	}
+	if skipped["escape"] != SkipReasonSymlinkEscape || skipped["escape_file.go"] != SkipReasonSymlinkEscape {
+		t.Errorf("expected escape skip reasons got %v", skipped)
+	}
+
+	escapes := 0
+	for _, e := range errs {
+		if errors.Is(e, ErrSymlinkEscape) {
+			escapes++
+		}
+	}
+	if escapes != 2 {
+		t.Errorf("expected 2 escape errors got %v", errs)
+	}
+}
+
+func TestSymlinkLoopErrorHandlerStops(t *testing.T) {
+	root, _ := makeSymlinkTree(t)
+	if err := os.Remove(filepath.Join(root, "linked")); err != nil {
+		t.Fatal(err)
+	}
+
+	fileListQueue := make(chan *File, 1000)
+	walker := NewFileWalker(filepath.Join(root, "real"), fileListQueue)
+	walker.FollowSymlinks = true
+	walker.SetErrorHandler(func(e error) bool {
+		return !errors.Is(e, ErrSymlinkLoop)
+	})
+
+	err := walker.Start()
+	if !errors.Is(err, ErrSymlinkLoop) {
+		t.Errorf("expected ErrSymlinkLoop got %v", err)
+	}
+}
@@ -0,0 +1,446 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// WatchPollInterval is the default time between polls when Watch is polling for changes
+var WatchPollInterval = time.Second
+
+// watchDebounce is how long to wait for further events once one is received, so that
+// a burst of changes to a directory such as a checkout results in a single rescan
+const watchDebounce = 50 * time.Millisecond
+
+// watchBackend reports directories which may have changed while watching
+type watchBackend interface {
+	add(directory string) error
+	remove(directory string)
+	wait(ctx context.Context) ([]string, error) // blocks until at least one directory may have changed
+	close() error
+}
+
+// watchedDirectory is a directory being watched along with everything needed
+// to run the filter pipeline against it again when it changes
+type watchedDirectory struct {
+	root        string
+	depth       int
+	layers      ignoreLayers  // inherited from the parent directory
+	ancestors   []os.FileInfo // inherited from the parent directory
+	record      *snapshotDirectory
+	ignoreFiles []snapshotFile // only used when polling to spot changes to ignore files
+}
+
+// watchState is every directory being watched, which is only
+// accessed by the goroutine running Watch so needs no locking
+type watchState struct {
+	f           *FileWalker
+	backend     watchBackend
+	changeQueue chan<- *Change
+	directories map[string]*watchedDirectory
+}
+
+// Watch walks the same as Start, sending files to the fileListQueue which is closed once the
+// initial walk has finished. It then watches for changes until the context is cancelled or
+// Terminate is called, sending a Change to the changeQueue for every file added to, modified in or
+// removed from what Start would return. The same filter pipeline is used, so changes to ignored
+// files are never sent, and when a .gitignore, .ignore or custom ignore file changes the rules are
+// reloaded with files sent as added or removed as they become included or ignored.
+//
+// On Linux inotify is used, otherwise or when WatchPolling is set every directory and file is
+// checked each WatchPollInterval. The changeQueue is closed when Watch returns, which is always with
+// an error, wrapping both ErrTerminateWalk and ctx.Err() when stopped through the context.
+func (f *FileWalker) Watch(ctx context.Context, changeQueue chan<- *Change) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer close(changeQueue)
+
+	f.walkMutex.Lock()
+	f.isWalking = true
+	f.ctx = ctx
+	f.cancel = cancel
+	err := f.freezeConfig()
+	finishStats := f.startStats()
+	f.walkMutex.Unlock()
+
+	defer func() {
+		finishStats()
+		f.walkMutex.Lock()
+		f.isWalking = false
+		f.cancel = nil
+		f.walkMutex.Unlock()
+	}()
+
+	w := &watchState{
+		f:           f,
+		changeQueue: changeQueue,
+		directories: map[string]*watchedDirectory{},
+	}
+
+	if err != nil {
+		f.sink.Close()
+		return err
+	}
+
+	w.backend, err = w.newBackend()
+	if err != nil {
+		f.sink.Close()
+		return err
+	}
+	defer func() {
+		_ = w.backend.close()
+	}()
+
+	err = w.walkRoots()
+	f.sink.Close()
+	if err != nil {
+		return err
+	}
+
+	for {
+		dirty, err := w.backend.wait(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return f.interrupted()
+			}
+			if !f.errorsHandler(err) {
+				return err
+			}
+			continue
+		}
+
+		// sorted so that parents are rescanned before their children, which
+		// may have been removed or already rescanned along with the parent
+		slices.Sort(dirty)
+		for _, directory := range slices.Compact(dirty) {
+			if err := w.rescan(directory); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// newBackend returns inotify where available, otherwise a poller
+func (w *watchState) newBackend() (watchBackend, error) {
+	if !w.f.cfg.WatchPolling && w.f.fsys == nil {
+		backend, err := newInotifyBackend()
+		if err == nil {
+			return backend, nil
+		}
+		if !errors.Is(err, errors.ErrUnsupported) && !w.f.errorsHandler(err) {
+			return nil, err
+		}
+	}
+
+	interval := w.f.cfg.WatchPollInterval
+	if interval <= 0 {
+		interval = WatchPollInterval
+	}
+	return &pollBackend{w: w, interval: interval}, nil
+}
+
+// walkRoots walks the directory, or each of the directories, sending files to the fileListQueue or Sink
+func (w *watchState) walkRoots() error {
+	roots := w.f.directories
+	if len(roots) == 0 && w.f.directory != "" {
+		roots = []string{w.f.directory}
+	}
+
+	for _, root := range roots {
+		started := time.Now()
+		layers, err := w.f.buildRootLayers(root)
+		if err != nil {
+			return err
+		}
+		if err := w.addTree(0, root, root, layers, nil, w.f.emit); err != nil {
+			return err
+		}
+		w.f.stats.root(root, started)
+	}
+
+	return nil
+}
+
+// addTree walks the directory and everything below it, watching each directory
+// and passing the files which should be returned to emit in batches
+func (w *watchState) addTree(depth int,
+	root string,
+	directory string,
+	layers ignoreLayers,
+	ancestors []os.FileInfo,
+	emit func(files []*File) error) error {
+
+	f := w.f
+	if maxDepth := layers.config(f).MaxDepth; maxDepth != -1 && depth >= maxDepth {
+		return nil
+	}
+
+	if err := f.interrupted(); err != nil {
+		return err
+	}
+
+	// watched before reading so that nothing changed while reading is missed
+	if err := w.backend.add(directory); err != nil {
+		if !f.errorsHandler(err) {
+			return err
+		}
+	}
+
+	info, err := f.stat(directory)
+	if err != nil {
+		w.backend.remove(directory)
+		if f.errorsHandler(err) {
+			return nil
+		}
+		return err
+	}
+
+	scan, err := f.scanDirectory(depth, root, directory, layers, ancestors, info, emit)
+	if scan == nil || err != nil {
+		w.backend.remove(directory)
+		return err
+	}
+
+	w.directories[directory] = &watchedDirectory{
+		root:        root,
+		depth:       depth,
+		layers:      layers,
+		ancestors:   ancestors,
+		record:      scan.record,
+		ignoreFiles: w.statIgnoreFiles(directory, scan.record),
+	}
+
+	dirFiles, err := f.emitDirectories(depth, root, directory, scan.dirs, emit)
+	if err != nil {
+		return err
+	}
+
+	for i, dir := range scan.dirs {
+		joined := filepath.ToSlash(filepath.Join(directory, dir.Name()))
+
+		count := int64(0)
+		below := emit
+		if f.cfg.EmitLeaveDirectories {
+			below = countingEmit(&count, emit)
+		}
+		if err := w.addTree(depth+1, root, joined, scan.layers, scan.ancestors, below); err != nil {
+			return err
+		}
+		if f.cfg.EmitLeaveDirectories {
+			if err := emit([]*File{leaveFile(dirFiles[i], count)}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// rescan runs the filter pipeline against the directory again, sending a change for
+// any difference from last time. If the ignore rules which apply have changed then
+// every directory below is rescanned as well, as the same rules apply to them
+func (w *watchState) rescan(directory string) error {
+	d, ok := w.directories[directory]
+	if !ok {
+		return nil // removed along with its parent
+	}
+
+	f := w.f
+	info, err := f.stat(directory)
+	if err != nil {
+		return w.removeTree(directory)
+	}
+
+	scan, err := f.scanDirectory(d.depth, d.root, directory, d.layers, d.ancestors, info, func(files []*File) error {
+		return nil
+	})
+	if scan == nil || err != nil {
+		return err
+	}
+
+	previous := d.record
+	d.record = scan.record
+	d.ignoreFiles = w.statIgnoreFiles(directory, scan.record)
+
+	files := map[string]snapshotFile{}
+	for _, file := range previous.Files {
+		files[file.Name] = file
+	}
+
+	for _, file := range scan.record.Files {
+		old, ok := files[file.Name]
+		delete(files, file.Name)
+
+		if !ok {
+			err = w.send(&Change{Type: ChangeAdded, Location: file.file.Location, File: file.file})
+		} else if !sameSnapshotFile(old, file) {
+			err = w.send(&Change{Type: ChangeModified, Location: file.file.Location, File: file.file})
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, file := range previous.Files {
+		if _, ok := files[file.Name]; ok {
+			location := filepath.ToSlash(filepath.Join(directory, file.Name))
+			if err := w.send(&Change{Type: ChangeRemoved, Location: location}); err != nil {
+				return err
+			}
+		}
+	}
+
+	relayer := scan.record.IgnoreHash != previous.IgnoreHash
+	for _, dir := range scan.dirs {
+		joined := filepath.ToSlash(filepath.Join(directory, dir.Name()))
+
+		child, ok := w.directories[joined]
+		if !ok {
+			if err := w.addTree(d.depth+1, d.root, joined, scan.layers, scan.ancestors, w.added); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if relayer {
+			child.layers = scan.layers
+			child.ancestors = scan.ancestors
+			if err := w.rescan(joined); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, name := range previous.Directories {
+		if !slices.Contains(scan.record.Directories, name) {
+			if err := w.removeTree(filepath.ToSlash(filepath.Join(directory, name))); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// removeTree stops watching the directory and everything below
+// it, sending a removed change for every file that was in them
+func (w *watchState) removeTree(directory string) error {
+	d, ok := w.directories[directory]
+	if !ok {
+		return nil
+	}
+
+	delete(w.directories, directory)
+	w.backend.remove(directory)
+
+	for _, file := range d.record.Files {
+		location := filepath.ToSlash(filepath.Join(directory, file.Name))
+		if err := w.send(&Change{Type: ChangeRemoved, Location: location}); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range d.record.Directories {
+		if err := w.removeTree(filepath.ToSlash(filepath.Join(directory, name))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// added sends an added change for each file found in a new directory
+func (w *watchState) added(files []*File) error {
+	for _, file := range files {
+		if file.Type != FileTypeFile {
+			continue // directories are only sent by the initial walk
+		}
+		if err := w.send(&Change{Type: ChangeAdded, Location: file.Location, File: file}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send sends the change to the changeQueue, giving up if the
+// watch is terminated or the context cancelled while waiting
+func (w *watchState) send(change *Change) error {
+	select {
+	case w.changeQueue <- change:
+		return nil
+	case <-w.f.ctx.Done():
+		return w.f.interrupted()
+	}
+}
+
+// statIgnoreFiles records the ignore files in the directory when polling, as unlike the
+// files returned they are not in the record, and a change to them needs to be spotted
+func (w *watchState) statIgnoreFiles(directory string, record *snapshotDirectory) []snapshotFile {
+	if _, ok := w.backend.(*pollBackend); !ok {
+		return nil
+	}
+
+	ignoreFiles := make([]snapshotFile, 0, len(record.IgnoreFiles))
+	for _, name := range record.IgnoreFiles {
+		info, _ := w.f.lstat(filepath.Join(directory, name))
+		file := newSnapshotFile(info)
+		file.Name = name
+		ignoreFiles = append(ignoreFiles, file)
+	}
+	return ignoreFiles
+}
+
+// changed returns every watched directory which has been modified, or has a
+// file or ignore file in it which has been modified, since it was last scanned
+func (w *watchState) changed() []string {
+	changed := []string{}
+	for directory, d := range w.directories {
+		info, err := w.f.stat(directory)
+		if err != nil || !info.ModTime().Equal(d.record.ModTime) {
+			changed = append(changed, directory)
+			continue
+		}
+
+		for _, file := range slices.Concat(d.record.Files, d.ignoreFiles) {
+			info, err := w.f.lstat(filepath.Join(directory, file.Name))
+			if err != nil || !sameSnapshotFile(file, newSnapshotFile(info)) {
+				changed = append(changed, directory)
+				break
+			}
+		}
+	}
+	return changed
+}
+
+// pollBackend checks every watched directory for changes each interval
+type pollBackend struct {
+	w        *watchState
+	interval time.Duration
+}
+
+func (p *pollBackend) add(directory string) error { return nil }
+func (p *pollBackend) remove(directory string)    {}
+func (p *pollBackend) close() error               { return nil }
+
+func (p *pollBackend) wait(ctx context.Context) ([]string, error) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		if changed := p.w.changed(); len(changed) != 0 {
+			return changed, nil
+		}
+	}
+}
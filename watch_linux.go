@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: MIT
+//go:build linux
+
+package gocodewalker
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"os"
+	"syscall"
+	"time"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY | syscall.IN_ATTRIB |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF | syscall.IN_ONLYDIR
+
+// inotifyBackend watches every directory with inotify, reporting the directory
+// an event was for. The descriptor is non-blocking so that reads go through the
+// runtime poller, which allows them to be interrupted using a deadline
+type inotifyBackend struct {
+	file        *os.File
+	fd          int
+	watches     map[int]string // watch descriptor to directory
+	descriptors map[string]int // directory to watch descriptor
+	buffer      []byte
+}
+
+func newInotifyBackend() (watchBackend, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	return &inotifyBackend{
+		file:        os.NewFile(uintptr(fd), "inotify"),
+		fd:          fd,
+		watches:     map[int]string{},
+		descriptors: map[string]int{},
+		buffer:      make([]byte, 64*1024),
+	}, nil
+}
+
+func (b *inotifyBackend) add(directory string) error {
+	wd, err := syscall.InotifyAddWatch(b.fd, directory, inotifyMask)
+	if err != nil {
+		return &fs.PathError{Op: "inotify_add_watch", Path: directory, Err: err}
+	}
+
+	b.watches[wd] = directory
+	b.descriptors[directory] = wd
+	return nil
+}
+
+func (b *inotifyBackend) remove(directory string) {
+	wd, ok := b.descriptors[directory]
+	if !ok {
+		return
+	}
+
+	// fails if the directory was deleted as the watch is already gone, which is fine
+	_, _ = syscall.InotifyRmWatch(b.fd, uint32(wd))
+	delete(b.descriptors, directory)
+	if b.watches[wd] == directory {
+		delete(b.watches, wd)
+	}
+}
+
+func (b *inotifyBackend) close() error {
+	return b.file.Close()
+}
+
+func (b *inotifyBackend) wait(ctx context.Context) ([]string, error) {
+	if err := b.file.SetReadDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		_ = b.file.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	changed := []string{}
+	for {
+		n, err := b.file.Read(b.buffer)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if errors.Is(err, os.ErrDeadlineExceeded) && len(changed) != 0 {
+				return changed, nil
+			}
+			return nil, err
+		}
+
+		changed = b.parse(b.buffer[:n], changed)
+		if len(changed) != 0 {
+			// keep reading until things go quiet
+			if err := b.file.SetReadDeadline(time.Now().Add(watchDebounce)); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// parse appends the directory of every event in the buffer to changed
+func (b *inotifyBackend) parse(buffer []byte, changed []string) []string {
+	for offset := 0; offset+syscall.SizeofInotifyEvent <= len(buffer); {
+		wd := int(int32(binary.NativeEndian.Uint32(buffer[offset:])))
+		mask := binary.NativeEndian.Uint32(buffer[offset+4:])
+		length := binary.NativeEndian.Uint32(buffer[offset+12:])
+		offset += syscall.SizeofInotifyEvent + int(length)
+
+		// events were dropped so anything could have changed
+		if mask&syscall.IN_Q_OVERFLOW != 0 {
+			for directory := range b.descriptors {
+				changed = append(changed, directory)
+			}
+			continue
+		}
+
+		directory, ok := b.watches[wd]
+		if !ok {
+			continue
+		}
+		changed = append(changed, directory)
+
+		if mask&syscall.IN_IGNORED != 0 {
+			delete(b.watches, wd)
+			if b.descriptors[directory] == wd {
+				delete(b.descriptors, directory)
+			}
+		}
+	}
+
+	return changed
+}
@@ -0,0 +1,11 @@
+// SPDX-License-Identifier: MIT
+//go:build !linux
+
+package gocodewalker
+
+import "errors"
+
+// newInotifyBackend is only available on Linux, elsewhere Watch polls
+func newInotifyBackend() (watchBackend, error) {
+	return nil, errors.ErrUnsupported
+}
@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: MIT
+
+package gocodewalker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+)
+
+// startWatch watches root until the test ends, returning the files
+// found by the initial walk and the queue changes are sent to
+func startWatch(t *testing.T, root string, polling bool) ([]string, <-chan *Change) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fileListQueue := make(chan *File, 100)
+	changeQueue := make(chan *Change, 100)
+	walker := NewFileWalker(root, fileListQueue)
+	walker.WatchPolling = polling
+	walker.WatchPollInterval = 10 * time.Millisecond
+
+	done := make(chan error, 1)
+	go func() {
+		done <- walker.Watch(ctx, changeQueue)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled got %v", err)
+		}
+	})
+
+	files := []string{}
+	for f := range fileListQueue {
+		rel, _ := filepath.Rel(root, f.Location)
+		files = append(files, filepath.ToSlash(rel))
+	}
+	sort.Strings(files)
+
+	return files, changeQueue
+}
+
+// expectChanges waits for every expected change failing on any that were not expected
+func expectChanges(t *testing.T, root string, changeQueue <-chan *Change, expected map[string]ChangeType) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for len(expected) != 0 {
+		select {
+		case c := <-changeQueue:
+			rel, _ := filepath.Rel(root, c.Location)
+			rel = filepath.ToSlash(rel)
+			if expected[rel] != c.Type {
+				t.Fatalf("unexpected change %s %s", c.Type, rel)
+			}
+			delete(expected, rel)
+		case <-timeout:
+			t.Fatalf("timed out waiting for %v", expected)
+		}
+	}
+}
+
+func forEachWatchBackend(t *testing.T, test func(t *testing.T, polling bool)) {
+	t.Run("inotify", func(t *testing.T) {
+		test(t, false)
+	})
+	t.Run("polling", func(t *testing.T) {
+		test(t, true)
+	})
+}
+
+func TestWatchFileChanges(t *testing.T) {
+	forEachWatchBackend(t, func(t *testing.T, polling bool) {
+		root := t.TempDir()
+		writeFile(t, filepath.Join(root, "a.go"), "package a")
+		writeFile(t, filepath.Join(root, "b.go"), "package b")
+
+		files, changeQueue := startWatch(t, root, polling)
+		if !slices.Equal(files, []string{"a.go", "b.go"}) {
+			t.Errorf("expected initial walk of a.go and b.go got %v", files)
+		}
+
+		writeFile(t, filepath.Join(root, "c.go"), "package c")
+		expectChanges(t, root, changeQueue, map[string]ChangeType{"c.go": ChangeAdded})
+
+		writeFile(t, filepath.Join(root, "a.go"), "package a // modified")
+		expectChanges(t, root, changeQueue, map[string]ChangeType{"a.go": ChangeModified})
+
+		if err := os.Remove(filepath.Join(root, "b.go")); err != nil {
+			t.Fatal(err)
+		}
+		expectChanges(t, root, changeQueue, map[string]ChangeType{"b.go": ChangeRemoved})
+	})
+}
+
+func TestWatchIgnoredFilesNotSent(t *testing.T) {
+	forEachWatchBackend(t, func(t *testing.T, polling bool) {
+		root := t.TempDir()
+		writeFile(t, filepath.Join(root, ".gitignore"), "*.log\n")
+
+		_, changeQueue := startWatch(t, root, polling)
+
+		writeFile(t, filepath.Join(root, "debug.log"), "ignored")
+		writeFile(t, filepath.Join(root, ".hidden"), "ignored")
+		writeFile(t, filepath.Join(root, "main.go"), "package main")
+		expectChanges(t, root, changeQueue, map[string]ChangeType{"main.go": ChangeAdded})
+	})
+}
+
+func TestWatchIgnoreFileReloaded(t *testing.T) {
+	forEachWatchBackend(t, func(t *testing.T, polling bool) {
+		root := t.TempDir()
+		writeFile(t, filepath.Join(root, ".gitignore"), "")
+		writeFile(t, filepath.Join(root, "a.go"), "package a")
+		writeFile(t, filepath.Join(root, "pkg", "b.go"), "package pkg")
+
+		_, changeQueue := startWatch(t, root, polling)
+
+		writeFile(t, filepath.Join(root, ".gitignore"), "b.go\n")
+		expectChanges(t, root, changeQueue, map[string]ChangeType{"pkg/b.go": ChangeRemoved})
+
+		writeFile(t, filepath.Join(root, ".gitignore"), "a.go\n")
+		expectChanges(t, root, changeQueue, map[string]ChangeType{"a.go": ChangeRemoved, "pkg/b.go": ChangeAdded})
+	})
+}
+
+func TestWatchDirectories(t *testing.T) {
+	forEachWatchBackend(t, func(t *testing.T, polling bool) {
+		root := t.TempDir()
+		writeFile(t, filepath.Join(root, "a.go"), "package a")
+
+		_, changeQueue := startWatch(t, root, polling)
+
+		writeFile(t, filepath.Join(root, "pkg", "deep", "b.go"), "package deep")
+		expectChanges(t, root, changeQueue, map[string]ChangeType{"pkg/deep/b.go": ChangeAdded})
+
+		writeFile(t, filepath.Join(root, "pkg", "deep", "c.go"), "package deep")
+		expectChanges(t, root, changeQueue, map[string]ChangeType{"pkg/deep/c.go": ChangeAdded})
+
+		if err := os.RemoveAll(filepath.Join(root, "pkg")); err != nil {
+			t.Fatal(err)
+		}
+		expectChanges(t, root, changeQueue, map[string]ChangeType{"pkg/deep/b.go": ChangeRemoved, "pkg/deep/c.go": ChangeRemoved})
+	})
+}
+
+func TestWatchTerminate(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a.go"), "package a")
+
+	fileListQueue := make(chan *File, 100)
+	changeQueue := make(chan *Change, 100)
+	walker := NewFileWalker(root, fileListQueue)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- walker.Watch(context.Background(), changeQueue)
+	}()
+
+	for range fileListQueue {
+	}
+	walker.Terminate()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrTerminateWalk) {
+			t.Errorf("expected ErrTerminateWalk got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for watch to stop")
+	}
+
+	if _, ok := <-changeQueue; ok {
+		t.Error("expected change queue to be closed")
+	}
+}